@@ -0,0 +1,168 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// Archive formats
+const (
+	ArchiveZip   = "zip"
+	ArchiveTarGz = "tar.gz"
+)
+
+const (
+	ContentZip  = "application/zip"
+	ContentGzip = "application/gzip"
+)
+
+// ArchiveOptions is a struct for specifying the download of an Archive call
+type ArchiveOptions struct {
+	// Name the archive is saved under. Defaults to "archive.zip" or "archive.tar.gz".
+	Filename string
+	// Modification time of the files. Defaults to the time of the call.
+	ModTime time.Time
+	// Headers set on the response.
+	Header http.Header
+}
+
+// ArchiveWriter adds the files of an archive streamed by Archive
+type ArchiveWriter struct {
+	zip     *zip.Writer
+	tar     *tar.Writer
+	modTime time.Time
+}
+
+// Add adds the file name with size bytes read from content. size may be -1 when unknown, the file is then read
+// in memory first for a tar.gz archive, whose entries start with their size.
+func (a *ArchiveWriter) Add(name string, content io.Reader, size int64) error {
+	name, err := archiveName(name)
+	if err != nil {
+		return err
+	}
+
+	if a.zip != nil {
+		w, err := a.zip.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: a.modTime})
+		if err != nil {
+			return err
+		}
+		_, err = copyBody(w, content)
+		return err
+	}
+
+	if size < 0 {
+		var buf bytes.Buffer
+		if _, err := copyBody(&buf, content); err != nil {
+			return err
+		}
+		content, size = &buf, int64(buf.Len())
+	}
+	header := &tar.Header{Typeflag: tar.TypeReg, Name: name, Size: size, Mode: 0644, ModTime: a.modTime}
+	if err := a.tar.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = copyBody(a.tar, io.LimitReader(content, size))
+
+	return err
+}
+
+// AddBytes adds the file name holding b
+func (a *ArchiveWriter) AddBytes(name string, b []byte) error {
+	return a.Add(name, bytes.NewReader(b), int64(len(b)))
+}
+
+// archiveName returns the slash separated relative path of a file of an archive, failing for the paths which
+// would be extracted outside of the directory of the archive
+func archiveName(name string) (string, error) {
+	clean := strings.TrimLeft(path.Clean("/"+strings.Replace(name, `\`, "/", -1)), "/")
+	if len(clean) == 0 || clean != strings.TrimLeft(strings.Replace(name, `\`, "/", -1), "/") {
+		return "", fmt.Errorf("render: invalid archive file name %q", name)
+	}
+
+	return clean, nil
+}
+
+// Archive streams a zip or tar.gz archive, format being ArchiveZip or ArchiveTarGz, as a download. add adds the
+// files as the archive is written: the headers are sent by then, so an error of add leaves the archive truncated
+// for the client to fail on, and is logged.
+//
+//	render.Archive(w, http.StatusOK, render.ArchiveZip, func(a *render.ArchiveWriter) error {
+//		return a.AddBytes("report.csv", csv)
+//	}, render.ArchiveOptions{Filename: "reports.zip"})
+func Archive(w http.ResponseWriter, status int, format string, add func(*ArchiveWriter) error, archiveOptions ...ArchiveOptions) {
+	var option ArchiveOptions
+	if len(archiveOptions) > 0 {
+		option = archiveOptions[0]
+	}
+	if option.ModTime.IsZero() {
+		option.ModTime = time.Now()
+	}
+
+	contentType := ContentZip
+	switch format {
+	case ArchiveZip:
+	case ArchiveTarGz:
+		contentType = ContentGzip
+	default:
+		renderError(w, nil, fmt.Errorf("render: unknown archive format %q", format))
+		return
+	}
+	if len(option.Filename) == 0 {
+		option.Filename = "archive." + format
+	}
+
+	stream, err := startStream()
+	if err != nil {
+		renderError(w, nil, err)
+		return
+	}
+	defer endStream(stream)
+
+	setHeader(w, option.Header)
+	w.Header().Set(ContentType, contentType)
+	w.Header().Set(ContentDisposition, contentDisposition("attachment", path.Base(option.Filename)))
+	w.WriteHeader(status)
+
+	out := streamOutput{w, stream}
+	archive := &ArchiveWriter{modTime: option.ModTime}
+	var closers []io.Closer
+	if format == ArchiveZip {
+		archive.zip = zip.NewWriter(out)
+		closers = append(closers, archive.zip)
+	} else {
+		gz := gzip.NewWriter(out)
+		archive.tar = tar.NewWriter(gz)
+		closers = append(closers, archive.tar, gz)
+	}
+
+	if err := add(archive); err != nil {
+		logError("render: archive truncated", "filename", option.Filename, "err", err)
+		return
+	}
+	for _, c := range closers {
+		if err := c.Close(); err != nil {
+			logError("render: archive", "filename", option.Filename, "err", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,173 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Length of the fingerprints of the assets, in hexadecimal digits
+const fingerprintLength = 10
+
+// Cache-Control of the fingerprinted assets, whose content never changes under the same path
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// Assets is a struct for specifying the static files the asset template func fingerprints
+type Assets struct {
+	// Directory of the static files, fingerprinted when Init loads the templates if Manifest is not set
+	Directory string `yaml:"Directory"`
+	// JSON file mapping the slash separated paths of the assets to their fingerprinted paths, such as the one
+	// WriteAssetManifest writes at build time. Defaults to "", the files of Directory are fingerprinted.
+	Manifest string `yaml:"Manifest"`
+	// URL prefix of the assets, such as "/static/" or the one of a CDN. Defaults to "/".
+	Prefix string `yaml:"Prefix"`
+}
+
+var assetFuncs = template.FuncMap{
+	"asset": asset,
+}
+
+// asset returns the URL of the fingerprinted asset at the slash separated path, such as "/static/app.3f2a1b9c0d.css"
+// for "app.css", instead of concatenating a version by hand to bust the caches:
+//
+//	<link rel="stylesheet" href="{{ asset "app.css" }}">
+//
+// The path is left as is when the asset is not in the manifest.
+func asset(p string) string {
+	p = strings.TrimPrefix(p, "/")
+	if fingerprinted, ok := loaded().assets[p]; ok {
+		p = fingerprinted
+	}
+
+	prefix := render.options.Assets.Prefix
+	if len(prefix) == 0 {
+		prefix = "/"
+	}
+
+	return strings.TrimSuffix(prefix, "/") + "/" + p
+}
+
+// BuildAssetManifest returns the paths of the files of dir mapped to their fingerprinted paths, the SHA-256 of
+// their content inserted before their extension: "css/app.css" to "css/app.3f2a1b9c0d.css"
+func BuildAssetManifest(dir string) (map[string]string, error) {
+	manifest := map[string]string{}
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relativePath, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(buf)
+		relativePath = filepath.ToSlash(relativePath)
+		ext := path.Ext(relativePath)
+		manifest[relativePath] = strings.TrimSuffix(relativePath, ext) + "." + hex.EncodeToString(sum[:])[:fingerprintLength] + ext
+
+		return nil
+	})
+
+	return manifest, err
+}
+
+// WriteAssetManifest writes the manifest of the files of dir to file as JSON, for Assets.Manifest, so that the
+// production servers do not hash the files when they start
+func WriteAssetManifest(dir, file string) error {
+	manifest, err := BuildAssetManifest(dir)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, append(b, '\n'), 0644)
+}
+
+// loadAssets reads the asset manifest of Options.Assets, or builds it from the files of its directory
+func loadAssets() (map[string]string, ParseErrors) {
+	assets := render.options.Assets
+	if len(assets.Manifest) == 0 {
+		if len(assets.Directory) == 0 {
+			return nil, nil
+		}
+		manifest, err := BuildAssetManifest(assets.Directory)
+		if err != nil {
+			return nil, ParseErrors{&ParseError{File: assets.Directory, Message: err.Error()}}
+		}
+		return manifest, nil
+	}
+
+	var manifest map[string]string
+	buf, err := ioutil.ReadFile(assets.Manifest)
+	if err == nil {
+		err = json.Unmarshal(buf, &manifest)
+	}
+	if err != nil {
+		return nil, ParseErrors{&ParseError{File: assets.Manifest, Message: err.Error()}}
+	}
+
+	return manifest, nil
+}
+
+// assetFiles maps the fingerprinted paths of the asset manifest back to the paths of the files
+func assetFiles(manifest map[string]string) map[string]string {
+	files := make(map[string]string, len(manifest))
+	for p, fingerprinted := range manifest {
+		if fingerprinted != p {
+			files[fingerprinted] = p
+		}
+	}
+
+	return files
+}
+
+// AssetHandler serves the files of Assets.Directory, the fingerprinted paths of the manifest with far-future cache
+// headers since their content never changes, and the other paths as http.FileServer does. It is mounted under
+// Assets.Prefix:
+//
+//	http.Handle("/static/", http.StripPrefix("/static/", render.AssetHandler()))
+func AssetHandler() http.Handler {
+	files := http.FileServer(http.Dir(render.options.Assets.Directory))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, ok := loaded().assetFiles[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			files.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Cache-Control", immutableCacheControl)
+		u := *r.URL
+		u.Path = "/" + file
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = &u
+		files.ServeHTTP(w, r2)
+	})
+}
@@ -0,0 +1,91 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Attachment streams content as a download saved under filename. The content type is inferred from the extension of
+// filename.
+func Attachment(w http.ResponseWriter, r *http.Request, status int, filename string, content io.Reader) {
+	disposition(w, r, status, "attachment", filename, content)
+}
+
+// Inline streams content to be displayed by the browser, with filename as the default name when saved
+func Inline(w http.ResponseWriter, r *http.Request, status int, filename string, content io.Reader) {
+	disposition(w, r, status, "inline", filename, content)
+}
+
+func disposition(w http.ResponseWriter, r *http.Request, status int, kind, filename string, content io.Reader) {
+	filename = path.Base(filepath.ToSlash(filename))
+
+	contentType := mime.TypeByExtension(path.Ext(filename))
+	if len(contentType) == 0 {
+		contentType = ContentBinary
+	}
+
+	size := int64(-1)
+	if l, ok := content.(interface{ Len() int }); ok {
+		size = int64(l.Len())
+	}
+
+	w.Header().Set(ContentDisposition, contentDisposition(kind, filename))
+	if r != nil && r.Method == http.MethodHead {
+		content = strings.NewReader("")
+	}
+	Stream(w, status, contentType, content, size)
+}
+
+// contentDisposition formats the header value as of RFC 6266, with an ASCII filename for old clients and the UTF-8
+// filename* parameter when the name is not plain ASCII
+func contentDisposition(kind, filename string) string {
+	var fallback strings.Builder
+	for _, c := range filename {
+		if c < 0x20 || c > 0x7e || c == '"' || c == '\\' || c == '%' {
+			fallback.WriteByte('_')
+		} else {
+			fallback.WriteRune(c)
+		}
+	}
+
+	value := kind + `; filename="` + fallback.String() + `"`
+	if fallback.String() != filename {
+		value += "; filename*=UTF-8''" + encodeRFC5987(filename)
+	}
+
+	return value
+}
+
+func encodeRFC5987(s string) string {
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0xf])
+		}
+	}
+
+	return b.String()
+}
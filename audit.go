@@ -0,0 +1,141 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AuditSchema is the schema of the documents Audit writes
+const AuditSchema = "render.audit/v1"
+
+// AuditEvent is an audited action
+type AuditEvent struct {
+	ID      string
+	Time    time.Time
+	Actor   string
+	Action  string
+	Target  string
+	Outcome string
+	Details map[string]interface{}
+}
+
+// auditDocument is the canonical form of an AuditEvent, its fields in a fixed order and its time in UTC
+type auditDocument struct {
+	Schema       string                 `json:"schema"`
+	ID           string                 `json:"id"`
+	Time         string                 `json:"time"`
+	Actor        string                 `json:"actor"`
+	Action       string                 `json:"action"`
+	Target       string                 `json:"target,omitempty"`
+	Outcome      string                 `json:"outcome,omitempty"`
+	Details      map[string]interface{} `json:"details,omitempty"`
+	PreviousHash string                 `json:"previous_hash,omitempty"`
+	Hash         string                 `json:"hash,omitempty"`
+}
+
+// AuditChain links the documents of Audit: each one holds the hash of the one before, so that removing or altering
+// a document breaks the chain
+type AuditChain struct {
+	mu   sync.Mutex
+	last string
+}
+
+// NewAuditChain returns a chain following the document of hash last, "" to start a new chain
+func NewAuditChain(last string) *AuditChain {
+	return &AuditChain{last: last}
+}
+
+// Last returns the hash of the last document of the chain
+func (c *AuditChain) Last() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.last
+}
+
+// AuditOptions is a struct for specifying the chain of an Audit call
+type AuditOptions struct {
+	// Chain the document is appended to, none by default.
+	Chain *AuditChain
+}
+
+// Audit writes the event as a canonical JSON document of AuditSchema, with its SHA-256 hash. The document is the
+// same whatever the JSON Options are: no indentation, prefix, custom encoder nor redaction apply.
+func Audit(w http.ResponseWriter, status int, event AuditEvent, auditOptions ...AuditOptions) {
+	var option AuditOptions
+	if len(auditOptions) > 0 {
+		option = auditOptions[0]
+	}
+
+	document := auditDocument{
+		Schema:  AuditSchema,
+		ID:      event.ID,
+		Time:    event.Time.UTC().Format(time.RFC3339Nano),
+		Actor:   event.Actor,
+		Action:  event.Action,
+		Target:  event.Target,
+		Outcome: event.Outcome,
+		Details: event.Details,
+	}
+
+	if option.Chain != nil {
+		option.Chain.mu.Lock()
+		defer option.Chain.mu.Unlock()
+		document.PreviousHash = option.Chain.last
+	}
+
+	result, err := auditJSON(&document)
+	if err != nil {
+		renderError(w, nil, err)
+		return
+	}
+	if option.Chain != nil {
+		option.Chain.last = document.Hash
+	}
+
+	if err := writeResponse(w, status, ContentJSON+prepareCharset(""), callOptions{}, result); err != nil {
+		renderError(w, nil, err)
+	}
+}
+
+// auditJSON hashes the canonical JSON of the document and returns the JSON of the document with its hash
+func auditJSON(document *auditDocument) ([]byte, error) {
+	unhashed, err := canonicalJSON(document)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(unhashed)
+	document.Hash = hex.EncodeToString(sum[:])
+
+	return canonicalJSON(document)
+}
+
+// canonicalJSON marshals v with encoding/json, the keys of the maps sorted, without escaping HTML nor trailing
+// newline
+func canonicalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
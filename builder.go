@@ -0,0 +1,148 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"net/http"
+)
+
+// Response composes the status, the headers and the layout of a response, written by one of its render methods:
+//
+//	render.For(w, r).Status(http.StatusCreated).Header("X-ID", id).JSONOr(order, err)
+//
+// The request is given to the renders, for the compression, the ETag and the locale, and an error is answered like
+// HTML answers its errors, through Options.ErrorHandler.
+type Response struct {
+	w      http.ResponseWriter
+	r      *http.Request
+	status int
+	header http.Header
+	html   HTMLOptions
+}
+
+// For starts the response to r
+func For(w http.ResponseWriter, r *http.Request) *Response {
+	return &Response{
+		w:      w,
+		r:      r,
+		status: http.StatusOK,
+		header: http.Header{},
+		html:   prepareHTMLOptions(nil),
+	}
+}
+
+// Status sets the status of the response, 200 OK by default
+func (res *Response) Status(status int) *Response {
+	res.status = status
+	return res
+}
+
+// Header adds a header to the response
+func (res *Response) Header(key, value string) *Response {
+	res.header.Add(key, value)
+	return res
+}
+
+// Layout sets the layout of the HTML renders, "" for none
+func (res *Response) Layout(name string) *Response {
+	res.html.Layout = name
+	res.html.Layouts = nil
+	res.html.NoLayout = len(name) == 0
+	return res
+}
+
+// Locale sets the locale of the HTML renders instead of the negotiated one
+func (res *Response) Locale(locale string) *Response {
+	res.html.Locale = locale
+	return res
+}
+
+// JSON writes v as JSON
+func (res *Response) JSON(v interface{}) {
+	JSON(res.w, res.status, v, JSONOptions{Request: res.r, Header: res.header})
+}
+
+// JSONOr writes v as JSON, or answers err when it is not nil
+func (res *Response) JSONOr(v interface{}, err error) {
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	res.JSON(v)
+}
+
+// XML writes v as XML
+func (res *Response) XML(v interface{}) {
+	XML(res.w, res.status, v, XMLOptions{Request: res.r, Header: res.header})
+}
+
+// XMLOr writes v as XML, or answers err when it is not nil
+func (res *Response) XMLOr(v interface{}, err error) {
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	res.XML(v)
+}
+
+// HTML renders the template name with binding
+func (res *Response) HTML(name string, binding interface{}) {
+	HTML(res.w, res.status, name, binding, res.htmlOptions())
+}
+
+// HTMLOr renders the template name with binding, or answers err when it is not nil
+func (res *Response) HTMLOr(name string, binding interface{}, err error) {
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	res.HTML(name, binding)
+}
+
+// Text writes v as text/plain
+func (res *Response) Text(v string) {
+	Text(res.w, res.status, v, TextOptions{Header: res.header})
+}
+
+// Negotiate renders the template name with v as HTML, or v as JSON or XML, as the Accept header prefers, JSON when
+// it is empty
+func (res *Response) Negotiate(name string, v interface{}) {
+	Negotiate(res.w, res.r,
+		Offer{Type: ContentJSON, Render: func(http.ResponseWriter, *http.Request) { res.JSON(v) }},
+		Offer{Type: ContentHTML, Render: func(http.ResponseWriter, *http.Request) { res.HTML(name, v) }},
+		Offer{Type: ContentXML, Render: func(http.ResponseWriter, *http.Request) { res.XML(v) }},
+	)
+}
+
+// NegotiateOr is Negotiate answering err when it is not nil
+func (res *Response) NegotiateOr(name string, v interface{}, err error) {
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	res.Negotiate(name, v)
+}
+
+// Error answers err like HTML answers its errors, with the headers of the response
+func (res *Response) Error(err error) {
+	setHeader(res.w, res.header)
+	renderError(res.w, res.r, err)
+}
+
+func (res *Response) htmlOptions() HTMLOptions {
+	option := res.html
+	option.Request = res.r
+	option.Header = res.header
+
+	return option
+}
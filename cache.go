@@ -0,0 +1,138 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// CacheStats is the occupancy of a cache bounded in entries and bytes, such as the one of TenantCacheStats
+type CacheStats struct {
+	// Number of entries and their size
+	Entries int   `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+	// Limits of the cache, 0 when there is none
+	MaxEntries int   `json:"maxEntries,omitempty"`
+	MaxBytes   int64 `json:"maxBytes,omitempty"`
+	// Lookups which found an entry, and which did not
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	// Entries dropped to stay within the limits, or once idle
+	Evictions uint64 `json:"evictions"`
+}
+
+// lruCache is a least recently used cache bounded in entries and bytes. Its owner locks it.
+type lruCache struct {
+	items     map[string]*list.Element
+	lru       *list.List
+	size      int64
+	hits      uint64
+	misses    uint64
+	evictions uint64
+	// Pending drop of the idle entries, see dropIdleLater
+	shrink *time.Timer
+}
+
+type lruEntry struct {
+	key   string
+	value interface{}
+	size  int64
+	// Time of the last get or add
+	used time.Time
+}
+
+// get returns the value of key, marking it as the most recently used
+func (c *lruCache) get(key string) (interface{}, bool) {
+	e, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.lru.MoveToFront(e)
+	e.Value.(*lruEntry).used = time.Now()
+
+	return e.Value.(*lruEntry).value, true
+}
+
+// add sets the value of key, of the given size, then drops the least recently used entries beyond maxEntries and
+// maxBytes, 0 for no limit. The value added is kept, even when it exceeds the limits alone.
+func (c *lruCache) add(key string, value interface{}, size int64, maxEntries int, maxBytes int64) {
+	if c.items == nil {
+		c.items = map[string]*list.Element{}
+		c.lru = list.New()
+	}
+	c.remove(key)
+	c.items[key] = c.lru.PushFront(&lruEntry{key: key, value: value, size: size, used: time.Now()})
+	c.size += size
+
+	for c.lru.Len() > 1 && (maxEntries > 0 && c.lru.Len() > maxEntries || maxBytes > 0 && c.size > maxBytes) {
+		c.remove(c.lru.Back().Value.(*lruEntry).key)
+		c.evictions++
+	}
+}
+
+// dropIdle drops the entries not used for idle, the least recently used being the oldest
+func (c *lruCache) dropIdle(idle time.Duration) {
+	for c.lru != nil && c.lru.Len() > 0 {
+		entry := c.lru.Back().Value.(*lruEntry)
+		if time.Since(entry.used) < idle {
+			return
+		}
+		c.remove(entry.key)
+		c.evictions++
+	}
+}
+
+// dropIdleLater drops the entries not used for idle once the least recently used is, then again for the next one,
+// as long as the cache has entries. The owner calls it after an add, holding mu, which the drops lock. Nothing is
+// scheduled when idle is 0, nor once the cache is empty, so that a cache no longer used can be collected.
+func (c *lruCache) dropIdleLater(mu sync.Locker, idle time.Duration) {
+	if idle <= 0 || c.shrink != nil || c.lru == nil || c.lru.Len() == 0 {
+		return
+	}
+
+	wait := idle - time.Since(c.lru.Back().Value.(*lruEntry).used)
+	c.shrink = time.AfterFunc(wait, func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		c.shrink = nil
+		c.dropIdle(idle)
+		c.dropIdleLater(mu, idle)
+	})
+}
+
+func (c *lruCache) remove(key string) {
+	e, ok := c.items[key]
+	if !ok {
+		return
+	}
+	entry := c.lru.Remove(e).(*lruEntry)
+	delete(c.items, key)
+	c.size -= entry.size
+}
+
+func (c *lruCache) stats(maxEntries int, maxBytes int64) CacheStats {
+	return CacheStats{
+		Entries:    len(c.items),
+		Bytes:      c.size,
+		MaxEntries: maxEntries,
+		MaxBytes:   maxBytes,
+		Hits:       c.hits,
+		Misses:     c.misses,
+		Evictions:  c.evictions,
+	}
+}
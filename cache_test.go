@@ -0,0 +1,152 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryResponseStoreLimits(t *testing.T) {
+	store := &MemoryResponseStore{MaxEntries: 2, MaxBytes: 10}
+	store.Set("a", &CapturedResponse{Body: []byte("1234")})
+	store.Set("b", &CapturedResponse{Body: []byte("1234")})
+	store.Get("a")
+	store.Set("c", &CapturedResponse{Body: []byte("1234")})
+
+	if _, ok := store.Get("b"); ok {
+		t.Error("the least recently used response was kept")
+	}
+	if _, ok := store.Get("a"); !ok {
+		t.Error("the recently used response was dropped")
+	}
+	store.Set("d", &CapturedResponse{Body: []byte("123456789")})
+
+	want := CacheStats{Entries: 1, Bytes: 9, MaxEntries: 2, MaxBytes: 10, Hits: 2, Misses: 1, Evictions: 3}
+	if got := store.Stats(); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestMemoryResponseStoreMaxIdle(t *testing.T) {
+	store := &MemoryResponseStore{MaxIdle: 50 * time.Millisecond}
+	store.Set("a", &CapturedResponse{Body: []byte("1234")})
+	store.Set("b", &CapturedResponse{Body: []byte("1234")})
+
+	// a keeps being replayed while b goes idle
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := store.Get("a"); !ok {
+			t.Fatal("the response in use was dropped")
+		}
+		if store.Stats().Entries == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the idle response was kept: %+v", store.Stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	deadline = time.Now().Add(5 * time.Second)
+	for store.Stats().Entries > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("the store was not emptied: %+v", store.Stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stats := store.Stats(); stats.Evictions != 2 || stats.Bytes != 0 {
+		t.Errorf("got %+v", stats)
+	}
+}
+
+func TestHTTPLoaderMaxBytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat("x", 100))
+	}))
+	defer server.Close()
+
+	loader := &HTTPLoader{BaseURL: server.URL, MaxBytes: 250}
+	for _, path := range []string{"a.tmpl", "b.tmpl", "c.tmpl", "a.tmpl"} {
+		if _, _, err := loader.Load(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := CacheStats{Entries: 2, Bytes: 200, MaxBytes: 250, Misses: 4, Evictions: 2}
+	if got := loader.Stats(); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestTenantCacheStats(t *testing.T) {
+	tenants := t.TempDir()
+	for _, tenant := range []string{"acme", "globex", "initech"} {
+		if err := os.MkdirAll(filepath.Join(tenants, tenant), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(tenants, tenant, "page.tmpl"), []byte(tenant), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	initTemplates(t, map[string]string{"page.tmpl": "shared"}, Options{
+		TenantDirectory: tenants,
+		// one set per shard
+		TenantCache: TenantCache{MaxSets: 1},
+	})
+
+	for _, tenant := range []string{"acme", "acme", "globex", "initech"} {
+		w := httptest.NewRecorder()
+		if err := HTMLE(w, 200, "page", nil, HTMLOptions{Tenant: tenant}); err != nil {
+			t.Fatal(err)
+		}
+		if w.Body.String() != tenant {
+			t.Errorf("%s: got %q", tenant, w.Body.String())
+		}
+	}
+
+	stats := TenantCacheStats()
+	if stats.Hits != 1 || stats.Misses != 3 || stats.Entries+int(stats.Evictions) != 3 || stats.Bytes == 0 {
+		t.Errorf("got %+v", stats)
+	}
+}
+
+func TestTenantCacheCountsTheSharedTemplates(t *testing.T) {
+	tenants := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tenants, "acme"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tenants, "acme", "page.tmpl"), []byte("acme"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	shared := strings.Repeat("x", 1000)
+	initTemplates(t, map[string]string{"page.tmpl": shared}, Options{TenantDirectory: tenants})
+
+	// globex has no templates of its own and renders the shared ones
+	for _, tenant := range []string{"acme", "globex"} {
+		if err := HTMLE(httptest.NewRecorder(), 200, "page", nil, HTMLOptions{Tenant: tenant}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if stats := TenantCacheStats(); stats.Entries != 2 || stats.Bytes != int64(len(shared)+len("acme")) {
+		t.Errorf("got %+v", stats)
+	}
+}
@@ -0,0 +1,351 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CapturedResponse is a rendered response: its status, headers and body
+type CapturedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// ResponseStore keeps captured responses by key
+type ResponseStore interface {
+	Get(key string) (*CapturedResponse, bool)
+	Set(key string, response *CapturedResponse)
+}
+
+// Default maximum number of responses of a MemoryResponseStore
+const defaultResponseStoreMaxEntries = 10000
+
+// MemoryResponseStore is a ResponseStore keeping the responses in memory, dropping the least recently used ones
+// beyond its limits
+type MemoryResponseStore struct {
+	// Maximum number of responses kept. Defaults to 10000.
+	MaxEntries int
+	// Maximum size of the bodies kept. Defaults to 0, no limit.
+	MaxBytes int64
+	// Time after which a response neither saved nor replayed is dropped, in the background, freeing the memory of a
+	// store no longer used. Defaults to 0, kept until the limits drop it.
+	MaxIdle time.Duration
+
+	mu        sync.Mutex
+	responses lruCache
+}
+
+func (s *MemoryResponseStore) Get(key string) (*CapturedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	response, ok := s.responses.get(key)
+	if !ok {
+		return nil, false
+	}
+	return response.(*CapturedResponse), true
+}
+
+func (s *MemoryResponseStore) Set(key string, response *CapturedResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses.add(key, response, int64(len(response.Body)), s.maxEntries(), s.MaxBytes)
+	s.responses.dropIdleLater(&s.mu, s.MaxIdle)
+}
+
+// Stats returns the occupancy of the store
+func (s *MemoryResponseStore) Stats() CacheStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.responses.stats(s.maxEntries(), s.MaxBytes)
+}
+
+func (s *MemoryResponseStore) maxEntries() int {
+	if s.MaxEntries <= 0 {
+		return defaultResponseStoreMaxEntries
+	}
+
+	return s.MaxEntries
+}
+
+// CaptureWriter writes a response through and records it, to save it into a ResponseStore once complete
+type CaptureWriter struct {
+	http.ResponseWriter
+	store  ResponseStore
+	key    string
+	status int
+	body   bytes.Buffer
+}
+
+// CaptureTo wraps w to record the response written into it, which Close saves into store under key:
+//
+//	cw := render.CaptureTo(store, key, w)
+//	render.JSON(cw, http.StatusCreated, order)
+//	cw.Close()
+func CaptureTo(store ResponseStore, key string, w http.ResponseWriter) *CaptureWriter {
+	return &CaptureWriter{ResponseWriter: w, store: store, key: key}
+}
+
+func (c *CaptureWriter) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *CaptureWriter) Write(p []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	c.body.Write(p)
+
+	return c.ResponseWriter.Write(p)
+}
+
+func (c *CaptureWriter) Flush() {
+	flushResponse(c.ResponseWriter)
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer
+func (c *CaptureWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// Response returns the response recorded so far. Its status is 0 while nothing was written.
+func (c *CaptureWriter) Response() *CapturedResponse {
+	return &CapturedResponse{
+		Status: c.status,
+		Header: c.Header().Clone(),
+		Body:   append([]byte(nil), c.body.Bytes()...),
+	}
+}
+
+// Close saves the recorded response into the store, unless nothing was written
+func (c *CaptureWriter) Close() error {
+	if c.status == 0 {
+		return nil
+	}
+	c.store.Set(c.key, c.Response())
+
+	return nil
+}
+
+// Replay writes the captured response verbatim, with an Idempotent-Replayed header
+func Replay(w http.ResponseWriter, response *CapturedResponse) {
+	header := w.Header()
+	for key, values := range response.Header {
+		header[key] = append([]string(nil), values...)
+	}
+	header.Set("Idempotent-Replayed", "true")
+
+	status := response.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(response.Body)
+}
+
+// ErrIdempotencyKeyInUse answers a request whose Idempotency-Key is the one of a request still in progress, with 409
+// Conflict
+var ErrIdempotencyKeyInUse = errors.New("render: a request with the same Idempotency-Key is in progress")
+
+// IdempotentOptions is a struct for specifying how Idempotent answers the requests of a key in progress
+type IdempotentOptions struct {
+	// Time a request waits for the one in progress with the same key, to replay its response. Defaults to 0, the
+	// request is answered with ErrIdempotencyKeyInUse right away.
+	Wait time.Duration
+}
+
+// Idempotent is a middleware replaying the response saved for the Idempotency-Key header of the request, or else
+// saving the response of next under it. Server errors, and handlers which wrote nothing, are not saved, so that the
+// request can be retried. While a request is in progress, the ones with the same key wait for its response up to
+// IdempotentOptions.Wait, or are answered with ErrIdempotencyKeyInUse, 409 Conflict. Keys are used as they are: a
+// store shared by several clients should scope them, such as by user. Requests are only tracked within the process,
+// several instances sharing a store need a lock of their own.
+func Idempotent(store ResponseStore, idempotentOptions ...IdempotentOptions) func(next http.Handler) http.Handler {
+	var option IdempotentOptions
+	if len(idempotentOptions) > 0 {
+		option = idempotentOptions[0]
+	}
+
+	var (
+		mu      sync.Mutex
+		pending = map[string]chan struct{}{}
+	)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			if len(key) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			for {
+				mu.Lock()
+				done, inProgress := pending[key]
+				if !inProgress {
+					pending[key] = make(chan struct{})
+				}
+				mu.Unlock()
+				if !inProgress {
+					break
+				}
+
+				if err := waitIdempotent(r, done, option.Wait); err != nil {
+					renderError(w, r, err)
+					return
+				}
+			}
+			defer func() {
+				mu.Lock()
+				close(pending[key])
+				delete(pending, key)
+				mu.Unlock()
+			}()
+
+			// the response is saved before the key is released, a request which did not find it in progress finds it
+			// saved
+			if response, ok := store.Get(key); ok {
+				Replay(w, response)
+				return
+			}
+
+			cw := CaptureTo(store, key, w)
+			next.ServeHTTP(cw, r)
+			if cw.status < http.StatusInternalServerError {
+				cw.Close()
+			}
+		})
+	}
+}
+
+// waitIdempotent waits up to wait for the request in progress with the same key to be done
+func waitIdempotent(r *http.Request, done chan struct{}, wait time.Duration) error {
+	if wait <= 0 {
+		return ErrIdempotencyKeyInUse
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		return ErrIdempotencyKeyInUse
+	case <-r.Context().Done():
+		return r.Context().Err()
+	}
+}
+
+// responseRecorder is an in-memory http.ResponseWriter
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+
+	return r.body.Write(p)
+}
+
+// Capture runs fn against an in-memory writer and returns the response it wrote, for webhooks and background jobs
+// to reuse the renderers without an http.ResponseWriter:
+//
+//	resp, err := render.Capture(func(w http.ResponseWriter) error {
+//		return render.HTMLE(w, http.StatusOK, "emails/welcome", user)
+//	})
+func Capture(fn func(w http.ResponseWriter) error) (*CapturedResponse, error) {
+	recorder := &responseRecorder{header: http.Header{}}
+	if err := fn(recorder); err != nil {
+		return nil, err
+	}
+
+	status := recorder.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	return &CapturedResponse{Status: status, Header: recorder.header, Body: recorder.body.Bytes()}, nil
+}
+
+// CaptureJSON returns the response JSON renders
+func CaptureJSON(status int, v interface{}, jsonOptions ...JSONOptions) (*CapturedResponse, error) {
+	return Capture(func(w http.ResponseWriter) error {
+		return JSONE(w, status, v, jsonOptions...)
+	})
+}
+
+// CaptureXML returns the response XML renders
+func CaptureXML(status int, v interface{}, xmlOptions ...XMLOptions) (*CapturedResponse, error) {
+	return Capture(func(w http.ResponseWriter) error {
+		return XMLE(w, status, v, xmlOptions...)
+	})
+}
+
+// CaptureHTML returns the response HTML renders
+func CaptureHTML(status int, name string, binding interface{}, htmlOptions ...HTMLOptions) (*CapturedResponse, error) {
+	return Capture(func(w http.ResponseWriter) error {
+		return HTMLE(w, status, name, binding, htmlOptions...)
+	})
+}
+
+// HTMLString returns the page HTML renders, with its layouts, for emails, PDF generation or caching a rendered
+// fragment. HTMLOptions.Request, when set, still selects the locale and the scope of the template funcs. The flash of
+// the request is cleared on HTMLOptions.ResponseWriter, and left in place without it.
+func HTMLString(name string, binding interface{}, htmlOptions ...HTMLOptions) (string, error) {
+	recorder := &responseRecorder{header: http.Header{}}
+	buf, err := renderHTML(recorder, name, binding, prepareHTMLOptions(htmlOptions))
+	if buf != nil {
+		defer buf.release()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return buf.contents()
+}
+
+// HTMLBytes is HTMLString returning a []byte
+func HTMLBytes(name string, binding interface{}, htmlOptions ...HTMLOptions) ([]byte, error) {
+	s, err := HTMLString(name, binding, htmlOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(s), nil
+}
@@ -0,0 +1,92 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// idempotentRequest serves a POST with the Idempotency-Key key through handler
+func idempotentRequest(handler http.Handler, key string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/orders", nil)
+	r.Header.Set("Idempotency-Key", key)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestIdempotentInProgress(t *testing.T) {
+	initTemplates(t, nil, Options{})
+
+	for _, wait := range []time.Duration{0, time.Second} {
+		var calls int32
+		started, release := make(chan struct{}), make(chan struct{})
+		handler := Idempotent(&MemoryResponseStore{}, IdempotentOptions{Wait: wait})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&calls, 1)
+			close(started)
+			<-release
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, "order %d", n)
+		}))
+
+		first := make(chan *httptest.ResponseRecorder)
+		go func() {
+			first <- idempotentRequest(handler, "k")
+		}()
+		<-started
+
+		if wait == 0 {
+			if w := idempotentRequest(handler, "k"); w.Code != http.StatusConflict {
+				t.Errorf("concurrent request: got %d, want 409", w.Code)
+			}
+			close(release)
+		} else {
+			time.AfterFunc(20*time.Millisecond, func() {
+				close(release)
+			})
+			if w := idempotentRequest(handler, "k"); w.Code != http.StatusCreated || w.Body.String() != "order 1" || w.Header().Get("Idempotent-Replayed") != "true" {
+				t.Errorf("waiting request: got %d %q", w.Code, w.Body.String())
+			}
+		}
+
+		if w := <-first; w.Code != http.StatusCreated || w.Body.String() != "order 1" {
+			t.Errorf("first request: got %d %q", w.Code, w.Body.String())
+		}
+		if w := idempotentRequest(handler, "k"); w.Code != http.StatusCreated || w.Body.String() != "order 1" {
+			t.Errorf("replay: got %d %q", w.Code, w.Body.String())
+		}
+		if calls != 1 {
+			t.Errorf("handler called %d times", calls)
+		}
+	}
+}
+
+func TestIdempotentDoesNotSaveEmptyResponses(t *testing.T) {
+	store := &MemoryResponseStore{}
+	var calls int32
+	handler := Idempotent(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+
+	idempotentRequest(handler, "k")
+	idempotentRequest(handler, "k")
+	if _, ok := store.Get("k"); ok || calls != 2 {
+		t.Errorf("saved the empty response, handler called %d times", calls)
+	}
+}
@@ -0,0 +1,168 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"unicode/utf8"
+)
+
+// TextEncoder encodes Text output for clients which do not take UTF-8, such as SMS or USSD gateways
+type TextEncoder interface {
+	// Charset of the Content-Type header
+	Charset() string
+	// EncodeRune returns the bytes of r in the charset
+	EncodeRune(r rune) []byte
+}
+
+// Encoders of the ISO-8859-1, US-ASCII and GSM 03.38 default alphabet charsets. The GSM-7 encoder writes one
+// unpacked septet per byte, characters of the extension table being escaped with 0x1B.
+var (
+	Latin1 = NewTextEncoder("ISO-8859-1", encodeLatin1)
+	ASCII  = NewTextEncoder("US-ASCII", encodeASCII)
+	GSM7   = NewTextEncoder("x-gsm-7", encodeGSM7)
+)
+
+// NewTextEncoder returns a TextEncoder encoding runes with encode. The runes encode rejects are transliterated, e.g.
+// "é" to "e" and "€" to "EUR", or replaced by "?".
+func NewTextEncoder(charset string, encode func(r rune) ([]byte, bool)) TextEncoder {
+	return &textEncoder{charset: charset, encode: encode}
+}
+
+type textEncoder struct {
+	charset string
+	encode  func(r rune) ([]byte, bool)
+}
+
+func (e *textEncoder) Charset() string {
+	return e.charset
+}
+
+func (e *textEncoder) EncodeRune(r rune) []byte {
+	if b, ok := e.encode(r); ok {
+		return b
+	}
+
+	transliteration, ok := transliterations[r]
+	if !ok {
+		return []byte{'?'}
+	}
+
+	var result []byte
+	for _, t := range transliteration {
+		b, ok := e.encode(t)
+		if !ok {
+			return []byte{'?'}
+		}
+		result = append(result, b...)
+	}
+
+	return result
+}
+
+// encodeText encodes s, truncated to maxLength bytes when it is positive. Truncation does not split the encoding of a
+// rune. A nil encoder keeps UTF-8.
+func encodeText(s string, encoder TextEncoder, maxLength int) []byte {
+	var result []byte
+	for _, r := range s {
+		var b []byte
+		if encoder == nil {
+			b = make([]byte, utf8.RuneLen(r))
+			utf8.EncodeRune(b, r)
+		} else {
+			b = encoder.EncodeRune(r)
+		}
+
+		if maxLength > 0 && len(result)+len(b) > maxLength {
+			break
+		}
+		result = append(result, b...)
+	}
+
+	return result
+}
+
+func encodeLatin1(r rune) ([]byte, bool) {
+	if r > 0xFF {
+		return nil, false
+	}
+
+	return []byte{byte(r)}, true
+}
+
+func encodeASCII(r rune) ([]byte, bool) {
+	if r > 0x7F {
+		return nil, false
+	}
+
+	return []byte{byte(r)}, true
+}
+
+// GSM 03.38 default alphabet, by septet. 0x1B escapes to the extension table.
+const gsm7Alphabet = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞ\x1bÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+var (
+	gsm7Septets = map[rune]byte{}
+	// GSM 03.38 extension table
+	gsm7Extension = map[rune]byte{
+		'\f': 0x0A, '^': 0x14, '{': 0x28, '}': 0x29, '\\': 0x2F, '[': 0x3C, '~': 0x3D, ']': 0x3E, '|': 0x40, '€': 0x65,
+	}
+)
+
+func init() {
+	septet := byte(0)
+	for _, r := range gsm7Alphabet {
+		if r != 0x1B {
+			gsm7Septets[r] = septet
+		}
+		septet++
+	}
+}
+
+func encodeGSM7(r rune) ([]byte, bool) {
+	if septet, ok := gsm7Septets[r]; ok {
+		return []byte{septet}, true
+	}
+	if septet, ok := gsm7Extension[r]; ok {
+		return []byte{0x1B, septet}, true
+	}
+
+	return nil, false
+}
+
+// Fallbacks of the runes a charset lacks, from the typographic punctuation and the accented Latin letters
+var transliterations = map[rune]string{}
+
+func init() {
+	for r, t := range map[rune]string{
+		'‘': "'", '’': "'", '‚': "'", '′': "'", '“': "\"", '”': "\"", '„': "\"", '″': "\"", '«': "\"", '»': "\"",
+		'‐': "-", '‒': "-", '–': "-", '—': "-", '…': "...", '•': "*", '\u00a0': " ", '\u202f': " ",
+		'€': "EUR", '£': "GBP", '¥': "JPY", '©': "(c)", '®': "(R)", '™': "TM", '×': "x", '÷': "/",
+		'Æ': "AE", 'æ': "ae", 'Œ': "OE", 'œ': "oe", 'ß': "ss", 'Ø': "O", 'ø': "o", 'Ł': "L", 'ł': "l",
+		'Ð': "D", 'ð': "d", 'Þ': "Th", 'þ': "th",
+	} {
+		transliterations[r] = t
+	}
+
+	for base, accented := range map[string]string{
+		"A": "ÀÁÂÃÄÅĀĂĄ", "a": "àáâãäåāăą", "C": "ÇĆĈĊČ", "c": "çćĉċč", "D": "Ď", "d": "ď",
+		"E": "ÈÉÊËĒĔĖĘĚ", "e": "èéêëēĕėęě", "G": "ĜĞĠĢ", "g": "ĝğġģ", "I": "ÌÍÎÏĨĪĬĮİ", "i": "ìíîïĩīĭįı",
+		"N": "ÑŃŅŇ", "n": "ñńņň", "O": "ÒÓÔÕÖŌŎŐ", "o": "òóôõöōŏő", "R": "ŔŖŘ", "r": "ŕŗř",
+		"S": "ŚŜŞŠ", "s": "śŝşš", "T": "ŢŤ", "t": "ţť", "U": "ÙÚÛÜŨŪŬŮŰŲ", "u": "ùúûüũūŭůűų",
+		"Y": "ÝŸ", "y": "ýÿ", "Z": "ŹŻŽ", "z": "źżž",
+	} {
+		for _, r := range accented {
+			transliterations[r] = base
+		}
+	}
+}
@@ -0,0 +1,47 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// chunks splits the slice items into slices of size items, which share the array of items. With HTMLStream, a
+// large list is sent chunk by chunk instead of being rendered in memory first:
+//
+//	{{range chunks .Items 100}}{{range .}}<tr>...</tr>{{end}}{{flush}}{{end}}
+func chunks(items interface{}, size int) ([]interface{}, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("render: chunks of size %d", size)
+	}
+	if items == nil {
+		return nil, nil
+	}
+
+	v := reflect.ValueOf(items)
+	if v.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("render: chunks of %T, which is not a slice", items)
+	}
+
+	result := make([]interface{}, 0, (v.Len()+size-1)/size)
+	for i := 0; i < v.Len(); i += size {
+		end := i + size
+		if end > v.Len() {
+			end = v.Len()
+		}
+		result = append(result, v.Slice(i, end).Interface())
+	}
+
+	return result, nil
+}
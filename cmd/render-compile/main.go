@@ -0,0 +1,141 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+// Command render-compile compiles a template directory into a Go source file registering its files with
+// render.RegisterTemplates, so that the directory is not needed at run time. The templates are checked for syntax
+// errors, with their funcs left unchecked, and fail the generation. Meant for go:generate:
+//
+//	//go:generate render-compile -dir templates -o templates.go
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template/parse"
+)
+
+var (
+	directory  = flag.String("dir", "templates", "template directory")
+	extensions = flag.String("ext", ".tmpl", "comma separated extensions of the files to compile")
+	output     = flag.String("o", "templates.go", "output file")
+	pkg        = flag.String("pkg", "", "package of the output file, defaults to $GOPACKAGE or else main")
+	left       = flag.String("left", "{{", "left delimiter")
+	right      = flag.String("right", "}}", "right delimiter")
+)
+
+func main() {
+	flag.Parse()
+
+	if len(*pkg) == 0 {
+		*pkg = os.Getenv("GOPACKAGE")
+	}
+	if len(*pkg) == 0 {
+		*pkg = "main"
+	}
+
+	files, err := readFiles()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	source, err := generate(files)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(*output, source, 0644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("render-compile: %d templates of %s written to %s", len(files), *directory, *output)
+}
+
+// readFiles returns the sources of the template files by slash separated path relative to the directory, checked
+// for syntax errors
+func readFiles() (map[string]string, error) {
+	exts := strings.Split(*extensions, ",")
+	files := map[string]string{}
+	err := filepath.Walk(*directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !hasExtension(filepath.Base(path), exts) {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(*directory, path)
+		if err != nil {
+			return err
+		}
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		// the funcs are those of the application, which are not known here
+		tree := parse.New(relativePath)
+		tree.Mode = parse.SkipFuncCheck
+		if _, err := tree.Parse(string(buf), *left, *right, map[string]*parse.Tree{}); err != nil {
+			return err
+		}
+
+		files[filepath.ToSlash(relativePath)] = string(buf)
+		return nil
+	})
+
+	return files, err
+}
+
+// hasExtension tells whether the file name has one of the extensions, which are matched as render matches them,
+// from the first dot of the name
+func hasExtension(name string, exts []string) bool {
+	i := strings.IndexByte(name, '.')
+	if i < 0 {
+		return false
+	}
+	for _, ext := range exts {
+		if name[i:] == strings.TrimSpace(ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// generate returns the formatted source registering the files
+func generate(files map[string]string) ([]byte, error) {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by render-compile from %s. DO NOT EDIT.\n\n", filepath.ToSlash(*directory))
+	fmt.Fprintf(&buf, "package %s\n\n", *pkg)
+	fmt.Fprintf(&buf, "import \"github.com/ronzxy/go-render\"\n\n")
+	fmt.Fprintf(&buf, "func init() {\n\trender.RegisterTemplates(map[string]string{\n")
+	for _, path := range paths {
+		fmt.Fprintf(&buf, "\t\t%s: %s,\n", strconv.Quote(path), strconv.Quote(files[path]))
+	}
+	fmt.Fprintf(&buf, "\t})\n}\n")
+
+	return format.Source(buf.Bytes())
+}
@@ -0,0 +1,198 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+// Command render-preview serves a template directory through render, for working on templates without the host
+// application. Each page is re-parsed on request and reloaded in the browser when a template file changes. A page
+// is rendered with the JSON binding found next to its template, e.g. "index.json" for "index.tmpl".
+//
+//	render-preview -dir templates -layout layout -addr :8080
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ronzxy/go-render"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	directory  = flag.String("dir", "templates", "template directory")
+	layout     = flag.String("layout", "", "layout template name")
+	extensions = flag.String("ext", ".tmpl", "comma separated template file extensions")
+	addr       = flag.String("addr", ":8080", "listen address")
+	options    render.Options
+)
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!doctype html>
+<html>
+<head><title>render-preview</title></head>
+<body>
+<h1>{{.Directory}}</h1>
+<ul>
+{{range .Names}}<li><a href="/{{.}}">{{.}}</a></li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+var errorTemplate = template.Must(template.New("error").Parse(`<!doctype html>
+<html>
+<head><title>render-preview: error</title></head>
+<body>
+{{with .ParseErrors}}<h1>Parse errors</h1>
+<ul>
+{{range .}}<li><code>{{.File}}{{if .Line}}:{{.Line}}{{if .Column}}:{{.Column}}{{end}}{{end}}</code> {{.Message}}</li>
+{{end}}</ul>
+{{else}}<h1>Error</h1>
+<pre>{{.Error}}</pre>
+{{end}}</body>
+</html>
+`))
+
+// Polls the modification time of the template tree and reloads the page when it changes
+const reloadScript = `<script>
+(function() {
+	var since = "%d";
+	setInterval(function() {
+		fetch("/_preview/changes").then(function(r) { return r.text(); }).then(function(t) {
+			if (t !== since) { location.reload(); }
+		});
+	}, 1000);
+})();
+</script>
+`
+
+func main() {
+	flag.Parse()
+
+	options = render.Options{
+		Directory:  *directory,
+		Layout:     *layout,
+		Extensions: strings.Split(*extensions, ","),
+		DebugMode:  true,
+	}
+	if err := render.InitE(options); err != nil {
+		log.Print(err)
+	}
+
+	http.HandleFunc("/_preview/changes", func(w http.ResponseWriter, r *http.Request) {
+		render.Text(w, http.StatusOK, fmt.Sprint(lastModified().UnixNano()))
+	})
+	http.HandleFunc("/", preview)
+
+	log.Printf("render-preview: serving %s on %s", *directory, *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+func preview(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(r.URL.Path, "/")
+	if len(name) == 0 {
+		// pick up added and removed templates
+		if err := render.InitE(options); err != nil {
+			previewError(w, err, lastModified())
+			return
+		}
+		w.Header().Set(render.ContentType, render.ContentHTML)
+		indexTemplate.Execute(w, map[string]interface{}{
+			"Directory": *directory,
+			"Names":     templateNames(),
+		})
+		return
+	}
+
+	binding, err := loadBinding(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	modified := lastModified()
+	option := render.HTMLOptions{Layout: r.URL.Query().Get("layout")}
+	if _, ok := r.URL.Query()["layout"]; !ok {
+		option.Layout = *layout
+	}
+	option.NoLayout = len(option.Layout) == 0
+	if err := render.HTMLE(w, http.StatusOK, name, binding, option); err != nil {
+		previewError(w, err, modified)
+		return
+	}
+
+	if strings.HasPrefix(w.Header().Get(render.ContentType), render.ContentHTML) {
+		fmt.Fprintf(w, reloadScript, modified.UnixNano())
+	}
+}
+
+// previewError shows err, listing the files in error for ParseErrors, and reloads when a template changes
+func previewError(w http.ResponseWriter, err error, modified time.Time) {
+	var parseErrors render.ParseErrors
+	errors.As(err, &parseErrors)
+
+	w.Header().Set(render.ContentType, render.ContentHTML)
+	w.WriteHeader(http.StatusInternalServerError)
+	errorTemplate.Execute(w, map[string]interface{}{
+		"ParseErrors": parseErrors,
+		"Error":       err.Error(),
+	})
+	fmt.Fprintf(w, reloadScript, modified.UnixNano())
+}
+
+func templateNames() []string {
+	var names []string
+	for _, t := range render.Template().Templates() {
+		if t.Tree != nil {
+			names = append(names, t.Name())
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// loadBinding decodes the JSON file next to the template, a missing file is a nil binding
+func loadBinding(name string) (interface{}, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(*directory, filepath.FromSlash(name)+".json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var binding interface{}
+	if err := json.Unmarshal(buf, &binding); err != nil {
+		return nil, fmt.Errorf("%s.json: %s", name, err.Error())
+	}
+
+	return binding, nil
+}
+
+func lastModified() time.Time {
+	var modified time.Time
+	filepath.Walk(*directory, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+		return nil
+	})
+
+	return modified
+}
@@ -0,0 +1,151 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FuncCollision is a template func name defined by a FuncMap, FuncFactories or ScopedFuncMaps entry and by another
+// source of funcs, whose func is ignored
+type FuncCollision struct {
+	Name string
+	// Source of the func templates call, such as "FuncMap"
+	Used string
+	// Source of the ignored func, such as "helper library"
+	Shadowed string
+}
+
+func (c FuncCollision) String() string {
+	return fmt.Sprintf("name=%s used=%q shadowed=%q", c.Name, c.Used, c.Shadowed)
+}
+
+// FuncCollisions is the error InitE returns for the func collisions when Options.StrictFuncs is set
+type FuncCollisions []FuncCollision
+
+func (e FuncCollisions) Error() string {
+	messages := make([]string, len(e))
+	for i, c := range e {
+		messages[i] = c.String()
+	}
+
+	return "render: template func collisions: " + strings.Join(messages, ", ")
+}
+
+// funcSource is a source of template funcs, by precedence
+type funcSource struct {
+	name  string
+	funcs map[string]bool
+	// Defined by the application
+	user bool
+}
+
+// funcCollisions returns the collisions of the funcs the application defines in options, factories being the
+// FuncFactories it gave
+func funcCollisions(options Options, factories map[string]FuncFactory) FuncCollisions {
+	builtinFactories := map[string]bool{}
+	if len(options.MessagesDirectory) > 0 || len(options.Catalogs) > 0 {
+		for name := range translationFactories {
+			builtinFactories[name] = true
+		}
+	}
+	if options.EnableFormatFuncs {
+		for name := range formatFactories {
+			builtinFactories[name] = true
+		}
+	}
+	if len(options.FlashSecret) > 0 {
+		builtinFactories["flash"] = true
+	}
+	if options.FlashStore != nil {
+		builtinFactories["flashes"] = true
+	}
+	if options.ContextData != nil {
+		builtinFactories["context"] = true
+	}
+	if options.CSRFTokenFunc != nil {
+		for name := range csrfFactories {
+			builtinFactories[name] = true
+		}
+	}
+
+	// the funcs of a source replace those of the sources before it
+	sources := []funcSource{
+		{name: "provider funcs", funcs: funcNames(providerFuncs)},
+		{name: "markdown funcs", funcs: funcNames(markdownFuncs)},
+		{name: "state funcs", funcs: funcNames(stateFuncs)},
+		{name: "asset funcs", funcs: funcNames(assetFuncs)},
+		{name: "vite funcs", funcs: funcNames(viteFuncs)},
+		{name: "sanitize funcs", funcs: funcNames(sanitizeFuncs)},
+		{name: "global funcs", funcs: funcNames(globalFuncs)},
+		{name: "FuncMap", funcs: funcNames(options.FuncMap), user: true},
+		{name: "built-in factories", funcs: builtinFactories},
+		{name: "FuncFactories", funcs: funcNames(factories), user: true},
+		{name: "layout funcs", funcs: funcNames(helperFuncs)},
+	}
+	if options.EnableHelperFuncs {
+		library := funcSource{name: "helper library", funcs: funcNames(helperLibrary)}
+		sources = append(sources[:7], append([]funcSource{library}, sources[7:]...)...)
+	}
+
+	var collisions FuncCollisions
+	for i, source := range sources {
+		for _, earlier := range sources[:i] {
+			if !source.user && !earlier.user {
+				continue
+			}
+			for _, name := range sortedNames(source.funcs) {
+				if earlier.funcs[name] {
+					collisions = append(collisions, FuncCollision{Name: name, Used: source.name, Shadowed: earlier.name})
+				}
+			}
+		}
+	}
+
+	// scoped funcs replace all the others within their scope
+	for _, pattern := range funcScopes() {
+		scoped := fmt.Sprintf("ScopedFuncMaps[%q]", pattern)
+		for _, name := range sortedNames(funcNames(options.ScopedFuncMaps[pattern])) {
+			for _, source := range sources {
+				if source.funcs[name] && !source.user {
+					collisions = append(collisions, FuncCollision{Name: name, Used: scoped, Shadowed: source.name})
+				}
+			}
+		}
+	}
+
+	return collisions
+}
+
+// funcNames returns the keys of a map of funcs
+func funcNames(funcs interface{}) map[string]bool {
+	names := map[string]bool{}
+	for _, key := range reflect.ValueOf(funcs).MapKeys() {
+		names[key.String()] = true
+	}
+
+	return names
+}
+
+func sortedNames(names map[string]bool) []string {
+	result := make([]string, 0, len(names))
+	for name := range names {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+
+	return result
+}
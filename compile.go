@@ -0,0 +1,120 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"html/template"
+	"io/ioutil"
+	"runtime"
+	"sync"
+	texttemplate "text/template"
+	"text/template/parse"
+	"time"
+)
+
+// templateFile is a template file to parse, and the result of its parsing
+type templateFile struct {
+	path string
+	name string
+	// Parsed as a text template
+	text bool
+	// Parsed as a Mustache template
+	mustache bool
+	// Parsed by one of Options.Engines
+	engine bool
+	source string
+	// The source is registered with RegisterTemplates or loaded by Options.Loader rather than read from path
+	loaded bool
+	// Trees of the templates the file defines, sorted by name
+	trees []*parse.Tree
+	// Template the file defines when it is a Mustache one
+	mustacheTemplate *mustacheTemplate
+	err              error
+}
+
+// parseFiles reads and parses the files with Options.ParseWorkers goroutines, each into a template set of its own
+// since a set can't be parsed into concurrently. It returns the files in the same order.
+func parseFiles(files []templateFile) []templateFile {
+	workers := render.options.ParseWorkers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(files) {
+		workers = len(files)
+	}
+
+	start := time.Now()
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				parseFile(&files[i])
+			}
+		}()
+	}
+	for i := range files {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	logDebug("render: parsed the template files", "files", len(files), "duration", time.Since(start), "workers", workers)
+
+	return files
+}
+
+// parseFile reads and parses the file into a new template set, named after the directory as the one of
+// createTemplate is
+func parseFile(file *templateFile) {
+	if file.err != nil {
+		return
+	}
+	if !file.loaded {
+		buf, err := ioutil.ReadFile(file.path)
+		if err != nil {
+			file.err = err
+			return
+		}
+		file.source = string(buf)
+	}
+
+	// the files of Options.Engines are parsed together
+	if file.engine {
+		return
+	}
+
+	if file.mustache {
+		file.mustacheTemplate, file.err = parseMustache(file.name, file.source)
+		return
+	}
+
+	if file.text {
+		set := texttemplate.New(render.options.Directory)
+		set.Delims(render.options.Delimiter.Left, render.options.Delimiter.Right)
+		for _, funcs := range textFuncMaps() {
+			set.Funcs(funcs)
+		}
+		file.trees, file.err = parseTextTemplate(set, file.name, file.source)
+		return
+	}
+
+	set := template.New(render.options.Directory)
+	set.Delims(render.options.Delimiter.Left, render.options.Delimiter.Right)
+	for _, funcs := range htmlFuncMaps() {
+		set.Funcs(funcs)
+	}
+	file.trees, file.err = parseHTMLTemplate(set, file.name, file.source)
+}
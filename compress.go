@@ -0,0 +1,274 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/ronzxy/go-render/negotiate"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	ContentEncoding = "Content-Encoding"
+	// Default minimum size of a compressed body
+	defaultCompressionMinLength = 1024
+)
+
+// Compression is a struct for specifying the compression of buffered bodies
+type Compression struct {
+	// Compress JSON, XML, gob and HTML bodies with gzip, or a coding registered with RegisterCompressor, when the
+	// request accepts it. Requires the request to be given with the call options.
+	Enabled bool `yaml:"Enabled"`
+	// Smaller bodies are sent uncompressed. Defaults to 1024, a negative value compresses bodies of any length.
+	MinLength int `yaml:"MinLength"`
+	// gzip compression level. Defaults to gzip.DefaultCompression.
+	Level int `yaml:"Level"`
+	// Media types which are compressed, such as "application/json" or "text/*". Defaults to all of them, except
+	// the ones of already compressed formats such as images and archives, which are never compressed.
+	ContentTypes []string `yaml:"ContentTypes"`
+}
+
+// Media types of already compressed formats, by type/* for whole types
+var compressedTypes = map[string]bool{
+	"image/*":                      true,
+	"audio/*":                      true,
+	"video/*":                      true,
+	"font/woff":                    true,
+	"font/woff2":                   true,
+	"application/gzip":             true,
+	"application/x-gzip":           true,
+	"application/zip":              true,
+	"application/x-bzip2":          true,
+	"application/x-xz":             true,
+	"application/x-7z-compressed":  true,
+	"application/x-rar-compressed": true,
+	"application/vnd.rar":          true,
+	"application/zstd":             true,
+	"application/pdf":              true,
+}
+
+// Image types which are text and compress well
+var textImageTypes = map[string]bool{
+	"image/svg+xml": true,
+	"image/bmp":     true,
+	"image/x-icon":  true,
+}
+
+// Leading bytes of already compressed formats
+var compressedSignatures = [][]byte{
+	{0x1f, 0x8b},                       // gzip
+	{'P', 'K', 0x03, 0x04},             // zip, docx, jar
+	{0x89, 'P', 'N', 'G'},              // png
+	{0xff, 0xd8, 0xff},                 // jpeg
+	[]byte("GIF8"),                     // gif
+	{0x28, 0xb5, 0x2f, 0xfd},           // zstd
+	[]byte("BZh"),                      // bzip2
+	{0xfd, '7', 'z', 'X', 'Z', 0x00},   // xz
+	{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}, // 7z
+	[]byte("wOF2"),                     // woff2
+	[]byte("wOFF"),                     // woff
+	[]byte("Rar!"),                     // rar
+	[]byte("%PDF"),                     // pdf
+}
+
+// Compressor is an encoder of a content coding, reused for several bodies through Reset. *gzip.Writer, the brotli
+// and the zstd writers are compressors.
+type Compressor interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+type codec struct {
+	priority   int
+	compressor *sync.Pool
+}
+
+// Registered content codings, gzip is registered by Init
+var codecs = map[string]*codec{}
+
+// RegisterCompressor registers the content coding with the constructor of its compressor. Among the codings an
+// Accept-Encoding header prefers equally, the one of the highest priority is used. gzip has the priority 0.
+// Compressors are meant to be registered at startup, before rendering.
+//
+//	render.RegisterCompressor("br", 10, func() render.Compressor {
+//		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+//	})
+func RegisterCompressor(coding string, priority int, newCompressor func() Compressor) {
+	codecs[strings.ToLower(coding)] = &codec{
+		priority: priority,
+		compressor: &sync.Pool{
+			New: func() interface{} {
+				return newCompressor()
+			},
+		},
+	}
+}
+
+func prepareCompression(compression Compression) Compression {
+	if compression.MinLength == 0 {
+		compression.MinLength = defaultCompressionMinLength
+	}
+	if compression.Level == 0 || compression.Level < gzip.HuffmanOnly || compression.Level > gzip.BestCompression {
+		compression.Level = gzip.DefaultCompression
+	}
+
+	return compression
+}
+
+func resetCompression() {
+	level := render.options.Compression.Level
+	RegisterCompressor("gzip", 0, func() Compressor {
+		// the level is validated by prepareCompression
+		w, _ := gzip.NewWriterLevel(nil, level)
+		return w
+	})
+}
+
+// negotiateCoding returns the content coding for the body, or "" to send it as is. Vary is set when the body
+// would be compressed for some requests.
+func negotiateCoding(w http.ResponseWriter, contentType string, call callOptions, body [][]byte) string {
+	if call.noCompress || !compressibleType(contentType) || compressedBody(body) {
+		return ""
+	}
+
+	size := 0
+	for _, b := range body {
+		size += len(b)
+	}
+
+	return negotiateSizeCoding(w, call.request, int64(size))
+}
+
+// negotiateSizeCoding is negotiateCoding for a body of size bytes, -1 when unknown
+func negotiateSizeCoding(w http.ResponseWriter, r *http.Request, size int64) string {
+	if !render.options.Compression.Enabled || r == nil || len(w.Header().Get(ContentEncoding)) > 0 {
+		return ""
+	}
+	if size >= 0 && size < int64(render.options.Compression.MinLength) {
+		return ""
+	}
+
+	addVary(w.Header(), "Accept-Encoding")
+
+	specs := negotiate.Parse(r.Header.Get("Accept-Encoding"))
+	coding := ""
+	q := 0.0
+	for name, c := range codecs {
+		quality := negotiate.Quality(specs, name)
+		if quality <= 0 || quality < q {
+			continue
+		}
+		if quality == q {
+			if best := codecs[coding]; c.priority < best.priority || c.priority == best.priority && name > coding {
+				continue
+			}
+		}
+		coding, q = name, quality
+	}
+
+	return coding
+}
+
+// compressibleType tells whether bodies of the content type are compressed: it has to be one of
+// Compression.ContentTypes, when set, and not one of an already compressed format
+func compressibleType(contentType string) bool {
+	mediaType, _, _ := negotiate.ParseMediaType(contentType)
+	mediaType = strings.ToLower(mediaType)
+	if len(mediaType) == 0 {
+		return true
+	}
+
+	if allowed := render.options.Compression.ContentTypes; len(allowed) > 0 {
+		ok := false
+		for _, mediaRange := range allowed {
+			if negotiate.Match(mediaRange, mediaType) >= 0 {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	if textImageTypes[mediaType] {
+		return true
+	}
+	if i := strings.IndexByte(mediaType, '/'); i >= 0 && compressedTypes[mediaType[:i]+"/*"] {
+		return false
+	}
+
+	return !compressedTypes[mediaType]
+}
+
+// compressedBody tells whether the body starts with the signature of an already compressed format
+func compressedBody(body [][]byte) bool {
+	for _, b := range body {
+		if len(b) == 0 {
+			continue
+		}
+		for _, signature := range compressedSignatures {
+			if bytes.HasPrefix(b, signature) {
+				return true
+			}
+		}
+		// only the first bytes of the body are looked at
+		return false
+	}
+
+	return false
+}
+
+// compressBody returns the body compressed with the coding into a buffer of the BufferPool
+func compressBody(coding string, body [][]byte) (*bytes.Buffer, error) {
+	buf := render.buffer.Get()
+
+	pool := codecs[coding].compressor
+	c := pool.Get().(Compressor)
+	defer pool.Put(c)
+	c.Reset(buf)
+
+	for _, b := range body {
+		if _, err := c.Write(b); err != nil {
+			render.buffer.Set(buf)
+			return nil, err
+		}
+	}
+	if err := c.Close(); err != nil {
+		render.buffer.Set(buf)
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// codingETag derives the entity tag of the encoded representation from the one of the body
+func codingETag(etag, coding string) string {
+	return strings.TrimSuffix(etag, `"`) + "-" + coding + `"`
+}
+
+func addVary(header http.Header, name string) {
+	for _, value := range header.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), name) {
+				return
+			}
+		}
+	}
+
+	header.Add("Vary", name)
+}
@@ -0,0 +1,82 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"context"
+	"net/http"
+)
+
+type contextDataContextKey struct{}
+
+// withContextData returns the request carrying the values Options.ContextData derives from its context, called once
+// per render so that the binding and the context func read the same values
+func withContextData(r *http.Request) *http.Request {
+	if render.options.ContextData == nil || r == nil {
+		return r
+	}
+
+	data := render.options.ContextData(r.Context())
+	if len(data) == 0 {
+		return r
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), contextDataContextKey{}, data))
+}
+
+// requestData returns the values of Options.ContextData withContextData derived for r
+func requestData(r *http.Request) map[string]interface{} {
+	if r == nil {
+		return nil
+	}
+	data, _ := r.Context().Value(contextDataContextKey{}).(map[string]interface{})
+
+	return data
+}
+
+// contextFactory makes the context template func of a render, which returns the value of key of Options.ContextData
+// whatever the binding:
+//
+//	{{with context "User"}}<span>{{.Name}}</span>{{end}}
+func contextFactory(scope *Scope) interface{} {
+	return func(key string) interface{} {
+		return requestData(scope.Request)[key]
+	}
+}
+
+// mergeData returns a copy of the map binding with the global values and the values of Options.ContextData for r it
+// does not have, the context values replacing the global ones, so that the templates read them as {{.User}}. The
+// bindings which are not a map[string]interface{} or nil are returned as is, their templates read the values with
+// the global and context funcs.
+func mergeData(r *http.Request, binding interface{}) interface{} {
+	data, ok := binding.(map[string]interface{})
+	if !ok && binding != nil {
+		return binding
+	}
+
+	globalDataMu.RLock()
+	defer globalDataMu.RUnlock()
+
+	contextData := requestData(r)
+	if len(globalData) == 0 && len(contextData) == 0 {
+		return binding
+	}
+	merged := make(map[string]interface{}, len(globalData)+len(contextData)+len(data))
+	for _, values := range []map[string]interface{}{globalData, contextData, data} {
+		for key, value := range values {
+			merged[key] = value
+		}
+	}
+
+	return merged
+}
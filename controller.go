@@ -0,0 +1,56 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// flushResponse sends the output written so far to the client through http.ResponseController, which reaches the
+// Flusher of the writers wrapped by middlewares that Unwrap them
+func flushResponse(w http.ResponseWriter) {
+	http.NewResponseController(w).Flush()
+}
+
+// deadlineWriter pushes the write deadline of a streamed response back before each write, so that a stream which
+// keeps sending outlives the WriteTimeout of the server
+type deadlineWriter struct {
+	w          http.ResponseWriter
+	controller *http.ResponseController
+	timeout    time.Duration
+}
+
+// writeDeadline returns the writer of a stream to w with a write deadline of timeout, Options.StreamWriteTimeout when
+// it is 0, or w when neither is positive
+func writeDeadline(w http.ResponseWriter, timeout time.Duration) io.Writer {
+	if timeout == 0 {
+		timeout = render.options.StreamWriteTimeout
+	}
+	if timeout <= 0 {
+		return w
+	}
+
+	return &deadlineWriter{w: w, controller: http.NewResponseController(w), timeout: timeout}
+}
+
+func (d *deadlineWriter) Write(p []byte) (int, error) {
+	// a writer without deadlines streams as before
+	if err := d.controller.SetWriteDeadline(time.Now().Add(d.timeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return 0, err
+	}
+
+	return d.w.Write(p)
+}
@@ -0,0 +1,49 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"fmt"
+	"html/template"
+)
+
+// Default name of the form field of csrf_field
+const defaultCSRFFieldName = "csrf_token"
+
+// csrfFactories make the CSRF funcs of a render from its request, enabled by Options.CSRFTokenFunc
+var csrfFactories = map[string]FuncFactory{
+	"csrf_token": func(scope *Scope) interface{} {
+		return func() (string, error) {
+			return csrfToken(scope)
+		}
+	},
+	"csrf_field": func(scope *Scope) interface{} {
+		return func() (template.HTML, error) {
+			token, err := csrfToken(scope)
+			if err != nil {
+				return "", err
+			}
+			return template.HTML(fmt.Sprintf(`<input type="hidden" name="%s" value="%s">`,
+				template.HTMLEscapeString(render.options.CSRFFieldName), template.HTMLEscapeString(token))), nil
+		}
+	},
+}
+
+// csrfToken returns the token Options.CSRFTokenFunc gives for the request of the render
+func csrfToken(scope *Scope) (string, error) {
+	if scope.Request == nil {
+		return "", fmt.Errorf("render: the CSRF token needs the request, see HTMLOptions.Request")
+	}
+
+	return render.options.CSRFTokenFunc(scope.Request), nil
+}
@@ -0,0 +1,213 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"errors"
+	"github.com/ronzxy/go-render/negotiate"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Lines of source shown before and after the line of an error
+const excerptContext = 2
+
+// SourceLine is a line of a template file shown around an error
+type SourceLine struct {
+	// Line number, from 1
+	Number int
+	Text   string
+	// The line of the error
+	Current bool
+}
+
+// TemplateError is the failure to execute a template, located in its file
+type TemplateError struct {
+	// Path of the template file
+	File string
+	// Name of the template parsed from the file
+	Template string
+	// Position of the error in the file, 0 when unknown
+	Line    int
+	Column  int
+	Message string
+	// Lines of the file around Line
+	Excerpt []SourceLine
+	// The error of the template package
+	Err error
+}
+
+func (e *TemplateError) Error() string {
+	location := e.File
+	if e.Line > 0 {
+		location += ":" + strconv.Itoa(e.Line)
+		if e.Column > 0 {
+			location += ":" + strconv.Itoa(e.Column)
+		}
+	}
+
+	return location + ": " + e.Message
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// templateError locates the execution error of a text or of an HTML template in its file, other errors are
+// returned as they are
+func templateError(err error, text bool) error {
+	var located *TemplateError
+	if errors.As(err, &located) {
+		return err
+	}
+
+	var name, message string
+	var line, column int
+
+	var execError texttemplate.ExecError
+	var escapeError *template.Error
+	switch {
+	case errors.As(err, &execError):
+		// "template: name:line:column: executing ..."
+		m := parseErrorPattern.FindStringSubmatch(execError.Error())
+		if m == nil {
+			return err
+		}
+		name, message = m[1], m[4]
+		line, _ = strconv.Atoi(m[2])
+		column, _ = strconv.Atoi(m[3])
+	case errors.As(err, &escapeError) && escapeError.Line > 0:
+		name, line, message = escapeError.Name, escapeError.Line, escapeError.Description
+	default:
+		return err
+	}
+
+	e := &TemplateError{Template: name, Line: line, Column: column, Message: message, Err: err}
+	for _, info := range loaded().manifest {
+		// the text and Markdown templates are in a set of their own
+		if info.Name != name || (info.Engine != EngineHTML) != text {
+			continue
+		}
+		e.File = templatePath(info.File)
+		e.Excerpt = excerptLines(templateSource(info), line)
+		break
+	}
+	if len(e.File) == 0 {
+		e.File = name
+	}
+
+	return e
+}
+
+// templateSource returns the source of the loaded template file
+func templateSource(info TemplateInfo) string {
+	if source, ok := loaded().sources[info.Name]; ok && info.Engine == EngineHTML {
+		return source
+	}
+	if loader := render.options.Loader; loader != nil {
+		buf, _, _ := loader.Load(info.File)
+		return string(buf)
+	}
+	if source, ok := precompiled[info.File]; ok {
+		return source
+	}
+
+	buf, _ := ioutil.ReadFile(templatePath(info.File))
+	return string(buf)
+}
+
+// excerptLines returns the lines of source around line, none when line is unknown
+func excerptLines(source string, line int) []SourceLine {
+	if line <= 0 || len(source) == 0 {
+		return nil
+	}
+
+	lines := strings.Split(source, "\n")
+	var excerpt []SourceLine
+	for n := line - excerptContext; n <= line+excerptContext; n++ {
+		if n < 1 || n > len(lines) {
+			continue
+		}
+		excerpt = append(excerpt, SourceLine{Number: n, Text: strings.TrimRight(lines[n-1], "\r"), Current: n == line})
+	}
+
+	return excerpt
+}
+
+var diagnosticTemplate = template.Must(template.New("diagnostic").Parse(`<!doctype html>
+<html>
+<head>
+<title>render: {{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+pre { background: #f6f8fa; padding: .5em; overflow: auto; }
+.current { background: #ffdce0; display: block; }
+.number { color: #6a737d; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Errors}}<h2><code>{{.File}}{{if .Line}}:{{.Line}}{{if .Column}}:{{.Column}}{{end}}{{end}}</code></h2>
+<p>{{.Message}}</p>
+{{with .Excerpt}}<pre>{{range .}}<span{{if .Current}} class="current"{{end}}><span class="number">{{printf "%4d" .Number}}</span>  {{.Text}}</span>
+{{end}}</pre>{{end}}
+{{end}}</body>
+</html>
+`))
+
+// diagnostic is an error of the diagnostic page
+type diagnostic struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+	Excerpt []SourceLine
+}
+
+// writeDiagnostic answers the request with a page showing where the template errors are, returning false when err
+// holds none or when the request does not accept HTML
+func writeDiagnostic(w http.ResponseWriter, r *http.Request, status int, err error) bool {
+	if r != nil && negotiate.Type(r.Header.Get("Accept"), ContentHTML, ContentText) != ContentHTML {
+		return false
+	}
+
+	title := "template error"
+	var diagnostics []diagnostic
+	var templateErr *TemplateError
+	var parseErrors ParseErrors
+	switch {
+	case errors.As(err, &templateErr):
+		diagnostics = append(diagnostics, diagnostic{File: templateErr.File, Line: templateErr.Line,
+			Column: templateErr.Column, Message: templateErr.Message, Excerpt: templateErr.Excerpt})
+	case errors.As(err, &parseErrors):
+		title = "template parse errors"
+		for _, e := range parseErrors {
+			diagnostics = append(diagnostics, diagnostic{File: e.File, Line: e.Line, Column: e.Column,
+				Message: e.Message, Excerpt: e.Excerpt})
+		}
+	default:
+		return false
+	}
+
+	w.Header().Set(ContentType, ContentHTML+prepareCharset(""))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	setSecurityHeaders(w.Header())
+	w.WriteHeader(status)
+	diagnosticTemplate.Execute(w, map[string]interface{}{"Title": title, "Errors": diagnostics})
+
+	return true
+}
@@ -0,0 +1,100 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// walkTemplateFiles returns the template files of Options.Directories, the ones of textExtensions being text
+// templates, in walk order. The file of a later directory replaces the one of the same relative path.
+func walkTemplateFiles(textExtensions []string) ([]templateFile, error) {
+	byPath := map[string]templateFile{}
+	for _, dir := range render.options.Directories {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+			relativePath, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+
+			ext := getExt(relativePath)
+			name := templateName(relativePath, ext)
+			relativePath = filepath.ToSlash(relativePath)
+
+			switch {
+			case containsString(render.options.Extensions, ext):
+				byPath[relativePath] = templateFile{path: path, name: name}
+			case containsString(textExtensions, ext):
+				byPath[relativePath] = templateFile{path: path, name: name, text: true}
+			case containsString(render.options.MustacheExtensions, ext):
+				byPath[relativePath] = templateFile{path: path, name: name, mustache: true}
+			case engineIndex(ext) >= 0:
+				byPath[relativePath] = templateFile{path: path, name: name, engine: true}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	paths := make([]string, 0, len(byPath))
+	for path := range byPath {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return walkLess(paths[i], paths[j])
+	})
+
+	files := make([]templateFile, 0, len(paths))
+	for _, path := range paths {
+		files = append(files, byPath[path])
+	}
+
+	return files, nil
+}
+
+// relativeTemplatePath returns the slash separated path of a file of Options.Directories relative to its directory,
+// false when it is in none of them
+func relativeTemplatePath(path string) (string, bool) {
+	dirs := render.options.Directories
+	for i := len(dirs) - 1; i >= 0; i-- {
+		relativePath, err := filepath.Rel(dirs[i], path)
+		if err == nil && relativePath != ".." && !strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+			return filepath.ToSlash(relativePath), true
+		}
+	}
+
+	return "", false
+}
+
+// templatePath returns the file of a slash separated path relative to Options.Directories, the one of the last
+// directory holding it
+func templatePath(relativePath string) string {
+	dirs := render.options.Directories
+	for i := len(dirs) - 1; i >= 0; i-- {
+		path := filepath.Join(dirs[i], filepath.FromSlash(relativePath))
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+
+	return filepath.Join(render.options.Directory, filepath.FromSlash(relativePath))
+}
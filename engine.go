@@ -0,0 +1,103 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"io"
+	"path/filepath"
+)
+
+// Engine parses the template files of its extensions with a template language other than html/template, such as
+// the Jet adapter of the render/jet package. Its files are loaded like the HTML templates, from Options.Directories,
+// Options.Loader or RegisterTemplates, and loaded again when they change in debug mode. HTML renders its templates
+// by name, "users/show" for "users/show.jet", within the html/template layouts.
+type Engine interface {
+	// Name of the engine in the Manifest, such as "jet"
+	Name() string
+	// Extensions of the template files of the engine, such as ".jet"
+	Extensions() []string
+	// Parse parses the sources of the files, by slash separated path relative to the template directories such as
+	// "users/show.jet"
+	Parse(sources map[string]string) (EngineTemplates, error)
+}
+
+// EngineTemplates are the templates an Engine parsed
+type EngineTemplates interface {
+	// Execute writes the template of the file at path executed with binding
+	Execute(w io.Writer, path string, binding interface{}) error
+}
+
+// engineTemplate is the template of a file of an Engine
+type engineTemplate struct {
+	templates EngineTemplates
+	// Slash separated path of the file relative to the template directories
+	path string
+}
+
+// engineIndex returns the index of the Options.Engines of the extension ext, -1 when there is none
+func engineIndex(ext string) int {
+	for i, engine := range render.options.Engines {
+		if containsString(engine.Extensions(), ext) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// engineExtensions returns the extensions of all the Options.Engines
+func engineExtensions() []string {
+	var extensions []string
+	for _, engine := range render.options.Engines {
+		extensions = append(extensions, engine.Extensions()...)
+	}
+
+	return extensions
+}
+
+// parseEngineFiles parses the files of the Options.Engines, returning their templates by name
+func parseEngineFiles(files []templateFile) (map[string]engineTemplate, ParseErrors) {
+	engines := render.options.Engines
+	sources := make([]map[string]string, len(engines))
+	paths := make([]map[string]string, len(engines))
+	for i := range engines {
+		sources[i], paths[i] = map[string]string{}, map[string]string{}
+	}
+	for _, file := range files {
+		if !file.engine || file.err != nil {
+			continue
+		}
+		relativePath, ok := relativeTemplatePath(file.path)
+		if !ok {
+			relativePath = filepath.ToSlash(filepath.Base(file.path))
+		}
+		i := engineIndex(getExt(filepath.Base(file.path)))
+		sources[i][relativePath] = file.source
+		paths[i][file.name] = relativePath
+	}
+
+	templates := map[string]engineTemplate{}
+	var parseErrors ParseErrors
+	for i, engine := range engines {
+		parsed, err := engine.Parse(sources[i])
+		if err != nil {
+			parseErrors = append(parseErrors, newParseError(render.options.Directory, engine.Name(), err, ""))
+			continue
+		}
+		for name, path := range paths[i] {
+			templates[name] = engineTemplate{templates: parsed, path: path}
+		}
+	}
+
+	return templates, parseErrors
+}
@@ -0,0 +1,49 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// ErrorHTML renders the error page of status with data, within the layout. The page is the first template defined
+// of "errors/404", "errors/4xx" and "errors/default" for a 404, and so on. Error writes the status text when there
+// is none or it fails to render.
+func ErrorHTML(w http.ResponseWriter, r *http.Request, status int, data interface{}, htmlOptions ...HTMLOptions) {
+	option := prepareHTMLOptions(htmlOptions)
+	option.Request = r
+
+	name := errorPage(status)
+	if len(name) > 0 {
+		err := HTMLE(w, status, name, data, option)
+		if err == nil {
+			return
+		}
+		logError("render: ErrorHTML", "template", name, "err", err)
+	}
+
+	Error(w, status, nil, ErrorOptions{Request: r})
+}
+
+// errorPage returns the name of the error page template of status, "" when there is none
+func errorPage(status int) string {
+	code := strconv.Itoa(status)
+	for _, name := range []string{"errors/" + code, "errors/" + code[:1] + "xx", "errors/default"} {
+		if t := loaded().template.Lookup(name); t != nil && t.Tree != nil {
+			return name
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,55 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+func etagEnabled(generate, disable bool) bool {
+	return !disable && (generate || render.options.GenerateETags)
+}
+
+// computeETag returns a strong entity tag for the body parts
+func computeETag(body ...[]byte) string {
+	h := sha256.New()
+	for _, b := range body {
+		h.Write(b)
+	}
+
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// notModified reports whether the GET or HEAD request r has an If-None-Match header matching etag
+func notModified(r *http.Request, etag string) bool {
+	if r == nil || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+
+	return etagMatch(r.Header.Get("If-None-Match"), etag)
+}
+
+// etagMatch reports whether the entity tag list of an If-None-Match header matches etag, using the weak comparison
+func etagMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,221 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errEventStreamClosed is returned by the writes of an EventStream after its Close
+var errEventStreamClosed = errors.New("render: event stream closed")
+
+// EventStream is a response of server-sent events, counted among the streams Close waits for like the ones of
+// TrackStream. Its writes are given Options.StreamWriteTimeout, and it sends a comment every Options.StreamHeartbeat
+// it has nothing else to send. Done is closed once the client went away, a write failed or Close cut the stream, so
+// that the goroutine feeding it returns:
+//
+//	stream, err := render.StartEventStream(w, r)
+//	if err != nil {
+//		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+//		return
+//	}
+//	defer stream.Close()
+//	for {
+//		select {
+//		case event := <-events:
+//			stream.Send("update", event)
+//		case <-render.ShuttingDown():
+//			stream.Send("close", "reconnect")
+//			return
+//		case <-stream.Done():
+//			return
+//		}
+//	}
+type EventStream struct {
+	w     http.ResponseWriter
+	out   io.Writer
+	state *streamState
+
+	mu        sync.Mutex
+	err       error
+	lastWrite time.Time
+
+	done     chan struct{}
+	stop     chan struct{}
+	doneOnce sync.Once
+	stopOnce sync.Once
+}
+
+// StartEventStream writes the headers of a stream of server-sent events, answering the request r, and returns the
+// stream. It fails with ErrShuttingDown once Close is called.
+func StartEventStream(w http.ResponseWriter, r *http.Request) (*EventStream, error) {
+	state, err := startStream()
+	if err != nil {
+		return nil, err
+	}
+
+	header := w.Header()
+	header.Set(ContentType, ContentEventStream)
+	header.Set("Cache-Control", "no-cache")
+	// nginx buffers the responses it proxies otherwise
+	header.Set("X-Accel-Buffering", "no")
+	setSecurityHeaders(header)
+	w.WriteHeader(http.StatusOK)
+
+	s := &EventStream{
+		w:         w,
+		out:       streamOutput{writeDeadline(w, 0), state},
+		state:     state,
+		lastWrite: time.Now(),
+		done:      make(chan struct{}),
+		stop:      make(chan struct{}),
+	}
+	if err := s.flush(); err != nil {
+		s.fail(err)
+	}
+	go s.watch(r, render.options.StreamHeartbeat)
+
+	return s, nil
+}
+
+// Send sends the event, its data written as one data line per line. An empty event is a message event.
+func (s *EventStream) Send(event, data string) error {
+	var b strings.Builder
+	if len(event) > 0 {
+		b.WriteString("event: " + event + "\n")
+	}
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+
+	return s.write(b.String())
+}
+
+// Done returns a channel closed once the stream is over: the client went away, a write failed or the stream was cut
+func (s *EventStream) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the error which ended the stream, nil while it is open or when Close ended it
+func (s *EventStream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+// Close ends the stream, which Close of the package stops waiting for. The heartbeats are over when it returns, the
+// handler can return too.
+func (s *EventStream) Close() {
+	s.mu.Lock()
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		endStream(s.state)
+	})
+	s.mu.Unlock()
+	s.end()
+}
+
+// write writes and flushes p, ending the stream when it fails
+func (s *EventStream) write(p string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+	select {
+	case <-s.stop:
+		return errEventStreamClosed
+	default:
+	}
+
+	_, err := io.WriteString(s.out, p)
+	if err == nil {
+		err = s.flush()
+	}
+	if err != nil {
+		s.err = err
+		s.end()
+		return err
+	}
+	s.lastWrite = time.Now()
+
+	return nil
+}
+
+func (s *EventStream) flush() error {
+	if err := http.NewResponseController(s.w).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+
+	return nil
+}
+
+// fail ends the stream with err, unless it ended already
+func (s *EventStream) fail(err error) {
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.mu.Unlock()
+	s.end()
+}
+
+func (s *EventStream) end() {
+	s.doneOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// watch ends the stream when the request is canceled, and sends the heartbeats until then
+func (s *EventStream) watch(r *http.Request, heartbeat time.Duration) {
+	var canceled <-chan struct{}
+	if r != nil {
+		canceled = r.Context().Done()
+	}
+	var tick <-chan time.Time
+	if heartbeat > 0 {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.done:
+			return
+		case <-s.state.cut:
+			s.fail(ErrShuttingDown)
+			return
+		case <-canceled:
+			s.fail(r.Context().Err())
+			return
+		case <-tick:
+			s.mu.Lock()
+			idle := time.Since(s.lastWrite) >= heartbeat
+			s.mu.Unlock()
+			if idle {
+				s.write(": heartbeat\n\n")
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// deadlineRecorder records the write deadlines http.ResponseController sets, and fails the writes once failing is set
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	mu        sync.Mutex
+	deadlines []time.Time
+	failing   bool
+}
+
+func (d *deadlineRecorder) SetWriteDeadline(deadline time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deadlines = append(d.deadlines, deadline)
+	return nil
+}
+
+func (d *deadlineRecorder) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.failing {
+		return 0, errors.New("broken pipe")
+	}
+	return d.ResponseRecorder.Write(p)
+}
+
+func (d *deadlineRecorder) body() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.Body.String()
+}
+
+func TestStreamWriteTimeout(t *testing.T) {
+	initTemplates(t, map[string]string{"page.tmpl": `<p>{{.}}</p>`}, Options{StreamWriteTimeout: time.Minute})
+
+	for name, stream := range map[string]func(w http.ResponseWriter){
+		"Stream": func(w http.ResponseWriter) {
+			Stream(w, 200, ContentText, strings.NewReader("body"), -1)
+		},
+		"HTMLStream": func(w http.ResponseWriter) {
+			HTMLStream(w, 200, "page", "body")
+		},
+	} {
+		w := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+		stream(w)
+		if len(w.deadlines) == 0 || time.Until(w.deadlines[0]) < 50*time.Second {
+			t.Errorf("%s: got deadlines %v", name, w.deadlines)
+		}
+		if !strings.Contains(w.body(), "body") {
+			t.Errorf("%s: got %q", name, w.body())
+		}
+	}
+}
+
+func TestEventStreamHeartbeat(t *testing.T) {
+	initTemplates(t, nil, Options{StreamHeartbeat: 5 * time.Millisecond})
+
+	w := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+	stream, err := StartEventStream(w, httptest.NewRequest("GET", "/events", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send("update", "a\nb"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	stream.Close()
+
+	body := w.body()
+	if !strings.HasPrefix(body, "event: update\ndata: a\ndata: b\n\n") || !strings.Contains(body, ": heartbeat\n\n") {
+		t.Errorf("got %q", body)
+	}
+	if got := w.Header().Get(ContentType); got != ContentEventStream {
+		t.Errorf("got Content-Type %q", got)
+	}
+	if err := stream.Send("", "late"); err == nil {
+		t.Error("Send after Close succeeded")
+	}
+}
+
+func TestEventStreamEndsWithTheClient(t *testing.T) {
+	initTemplates(t, nil, Options{StreamHeartbeat: 5 * time.Millisecond})
+
+	// a heartbeat to a client gone without canceling the request fails
+	w := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+	stream, err := StartEventStream(w, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.mu.Lock()
+	w.failing = true
+	w.mu.Unlock()
+	select {
+	case <-stream.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the stream outlived the failing heartbeat")
+	}
+	if stream.Err() == nil {
+		t.Error("no error")
+	}
+	stream.Close()
+
+	// a canceled request ends the stream without heartbeats
+	initTemplates(t, nil, Options{})
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err = StartEventStream(httptest.NewRecorder(), httptest.NewRequest("GET", "/events", nil).WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	select {
+	case <-stream.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the stream outlived its request")
+	}
+	if stream.Err() != context.Canceled {
+		t.Errorf("got %v", stream.Err())
+	}
+	stream.Close()
+}
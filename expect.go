@@ -0,0 +1,170 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"text/template/parse"
+)
+
+// Binding types registered with Expect, by template name
+var expectations = map[string]reflect.Type{}
+
+// Expect registers the type of the binding the template name is rendered with. In debug mode HTML fails when it is
+// given a binding of another type, and Check verifies the fields the template uses against the type. Expectations
+// are meant to be registered at startup, before rendering.
+func Expect(name string, bindingType reflect.Type) {
+	expectations[name] = bindingType
+}
+
+// checkBinding verifies binding against the type expected for the template, in debug mode only
+func checkBinding(name string, binding interface{}) error {
+	if !render.options.DebugMode {
+		return nil
+	}
+
+	expected, ok := expectations[name]
+	if !ok {
+		return nil
+	}
+
+	if binding == nil {
+		switch expected.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+			return nil
+		}
+	} else if reflect.TypeOf(binding).AssignableTo(expected) {
+		return nil
+	}
+
+	return fmt.Errorf("render: template %q expects a binding of type %s, got %T", name, expected, binding)
+}
+
+// Check verifies every template registered with Expect exists, and the fields it reads from the binding, such as
+// {{.User.Name}} or {{$.Title}}, exist in the expected type. Fields read after dot has been changed by range or with
+// are not checked.
+func Check() []error {
+	var names []string
+	for name := range expectations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		expected := expectations[name]
+		t := loaded().template.Lookup(name)
+		if t == nil || t.Tree == nil {
+			errs = append(errs, fmt.Errorf("render: expected template %q is not defined", name))
+			continue
+		}
+
+		c := fieldChecker{tree: t.Tree, root: expected}
+		c.list(t.Tree.Root, true)
+		errs = append(errs, c.errs...)
+	}
+
+	return errs
+}
+
+type fieldChecker struct {
+	tree *parse.Tree
+	root reflect.Type
+	errs []error
+}
+
+// list checks the nodes, dot tells whether dot still is the binding
+func (c *fieldChecker) list(list *parse.ListNode, dot bool) {
+	if list == nil {
+		return
+	}
+
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.ActionNode:
+			c.pipe(n.Pipe, dot)
+		case *parse.IfNode:
+			c.pipe(n.Pipe, dot)
+			c.list(n.List, dot)
+			c.list(n.ElseList, dot)
+		case *parse.RangeNode:
+			c.pipe(n.Pipe, dot)
+			c.list(n.List, false)
+			c.list(n.ElseList, dot)
+		case *parse.WithNode:
+			c.pipe(n.Pipe, dot)
+			c.list(n.List, false)
+			c.list(n.ElseList, dot)
+		case *parse.TemplateNode:
+			c.pipe(n.Pipe, dot)
+		}
+	}
+}
+
+func (c *fieldChecker) pipe(pipe *parse.PipeNode, dot bool) {
+	if pipe == nil {
+		return
+	}
+
+	for _, cmd := range pipe.Cmds {
+		for _, arg := range cmd.Args {
+			switch n := arg.(type) {
+			case *parse.FieldNode:
+				if dot {
+					c.field(n, n.Ident)
+				}
+			case *parse.VariableNode:
+				if len(n.Ident) > 1 && n.Ident[0] == "$" {
+					c.field(n, n.Ident[1:])
+				}
+			case *parse.PipeNode:
+				c.pipe(n, dot)
+			}
+		}
+	}
+}
+
+func (c *fieldChecker) field(node parse.Node, ident []string) {
+	t := c.root
+	for _, name := range ident {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		switch t.Kind() {
+		case reflect.Interface, reflect.Map:
+			// resolved at execution time
+			return
+		}
+
+		if method, ok := reflect.PtrTo(t).MethodByName(name); ok {
+			if method.Type.NumOut() == 0 {
+				return
+			}
+			t = method.Type.Out(0)
+			continue
+		}
+		if t.Kind() == reflect.Struct {
+			if f, ok := t.FieldByName(name); ok && len(f.PkgPath) == 0 {
+				t = f.Type
+				continue
+			}
+		}
+
+		location, _ := c.tree.ErrorContext(node)
+		c.errs = append(c.errs, fmt.Errorf("render: %s: can't evaluate field %s in type %s", location, name, t))
+		return
+	}
+}
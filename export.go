@@ -0,0 +1,169 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// Default time an export is kept for the requests resuming its download
+const defaultExportTTL = time.Hour
+
+// ExportOptions is a struct for specifying the download of an Export call
+type ExportOptions struct {
+	// Name the export is saved under, its extension giving the content type. Defaults to none, the export is then
+	// not sent as an attachment.
+	Filename string
+	// Content type of the export. Defaults to the one of the extension of Filename, or application/octet-stream.
+	ContentType string
+	// Time the spooled export is kept for resumed downloads, from the end of its rendering. Defaults to an hour.
+	TTL time.Duration
+	// Directory of the spool files. Defaults to the directory of temporary files of the system.
+	Directory string
+}
+
+// exportSpool is an export rendered to a file
+type exportSpool struct {
+	path    string
+	etag    string
+	modTime time.Time
+	err     error
+	// Closed once the export is rendered
+	ready chan struct{}
+}
+
+// Spooled exports by key
+var (
+	exports   = map[string]*exportSpool{}
+	exportsMu sync.Mutex
+)
+
+// Export serves a large export, such as a multi-GB JSON or CSV dump, written by write to a spool file on the first
+// request for key, with support for Range and If-Range requests: a client whose download failed can resume it from
+// the same bytes. The spool is kept for ExportOptions.TTL, the requests for key arriving while it is written wait
+// for it. A write error is answered like the errors of HTML and the spool is written again on the next request.
+//
+//	render.Export(w, r, "orders-2018-06", func(w io.Writer) error {
+//		return json.NewEncoder(w).Encode(orders)
+//	}, render.ExportOptions{Filename: "orders.json"})
+func Export(w http.ResponseWriter, r *http.Request, key string, write func(w io.Writer) error, exportOptions ...ExportOptions) {
+	var option ExportOptions
+	if len(exportOptions) > 0 {
+		option = exportOptions[0]
+	}
+	if option.TTL <= 0 {
+		option.TTL = defaultExportTTL
+	}
+
+	spool := exportSpoolFor(key, write, option)
+	if spool.err != nil {
+		renderError(w, r, spool.err)
+		return
+	}
+
+	f, err := os.Open(spool.path)
+	if err != nil {
+		// expired while the request was waiting
+		serveFileError(w, err)
+		return
+	}
+	defer f.Close()
+
+	contentType := option.ContentType
+	if len(contentType) == 0 {
+		contentType = mime.TypeByExtension(path.Ext(option.Filename))
+	}
+	if len(contentType) == 0 {
+		contentType = ContentBinary
+	}
+	w.Header().Set(ContentType, contentType)
+	if len(option.Filename) > 0 {
+		w.Header().Set(ContentDisposition, contentDisposition("attachment", path.Base(option.Filename)))
+	}
+	// If-Range and If-None-Match are checked against the ETag by http.ServeContent
+	w.Header().Set(ETag, spool.etag)
+
+	http.ServeContent(w, r, "", spool.modTime, f)
+}
+
+// exportSpoolFor returns the spool of key, rendering it when there is none
+func exportSpoolFor(key string, write func(w io.Writer) error, option ExportOptions) *exportSpool {
+	exportsMu.Lock()
+	if spool, ok := exports[key]; ok {
+		exportsMu.Unlock()
+		<-spool.ready
+		return spool
+	}
+	spool := &exportSpool{ready: make(chan struct{})}
+	exports[key] = spool
+	exportsMu.Unlock()
+
+	spool.err = spool.render(write, option.Directory)
+	close(spool.ready)
+
+	if spool.err != nil {
+		exportsMu.Lock()
+		delete(exports, key)
+		exportsMu.Unlock()
+		return spool
+	}
+
+	time.AfterFunc(option.TTL, func() {
+		exportsMu.Lock()
+		if exports[key] == spool {
+			delete(exports, key)
+		}
+		exportsMu.Unlock()
+		os.Remove(spool.path)
+	})
+
+	return spool
+}
+
+// render writes the export to a new file of dir
+func (spool *exportSpool) render(write func(w io.Writer) error, dir string) error {
+	f, err := ioutil.TempFile(dir, "render-export-")
+	if err != nil {
+		return err
+	}
+	spool.path = f.Name()
+
+	h := sha256.New()
+	out := bufio.NewWriter(io.MultiWriter(f, h))
+	err = write(out)
+	if err == nil {
+		err = out.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(spool.path)
+		return err
+	}
+
+	spool.etag = `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+	spool.modTime = time.Now()
+
+	return nil
+}
@@ -0,0 +1,54 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// RenderFile executes the text template name with binding, merged with the global values as for TextString, and
+// writes the result to outPath with the permission bits perm. The file is replaced atomically, it is left untouched
+// when the execution fails.
+func RenderFile(outPath string, name string, binding interface{}, perm os.FileMode) error {
+	if err := refresh(); err != nil {
+		return err
+	}
+	release, err := acquireRender(nil)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	f, err := ioutil.TempFile(filepath.Dir(outPath), "."+filepath.Base(outPath)+".")
+	if err != nil {
+		return err
+	}
+
+	err = templateError(loaded().text.ExecuteTemplate(f, name, mergeData(nil, binding)), true)
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), outPath)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+
+	return err
+}
@@ -0,0 +1,43 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderFile(t *testing.T) {
+	initTemplates(t, map[string]string{
+		"motd.txt.tmpl":   "{{.Name}} {{.Version}}",
+		"broken.txt.tmpl": `{{template "missing" .}}`,
+	}, Options{GlobalData: map[string]interface{}{"Version": "1.2"}})
+
+	out := filepath.Join(t.TempDir(), "motd")
+	if err := RenderFile(out, "motd", map[string]interface{}{"Name": "render"}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(out); string(b) != "render 1.2" {
+		t.Errorf("got %q", b)
+	}
+
+	var templateErr *TemplateError
+	if err := RenderFile(out, "broken", nil, 0644); !errors.As(err, &templateErr) {
+		t.Errorf("got %v, want a TemplateError", err)
+	}
+	if b, _ := ioutil.ReadFile(out); string(b) != "render 1.2" {
+		t.Errorf("the failed render replaced the file: %q", b)
+	}
+}
@@ -0,0 +1,98 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FeatureProvider tells whether feature flags are on, for the feature template func
+type FeatureProvider interface {
+	Enabled(name string) bool
+}
+
+// FeatureFunc is a func used as a FeatureProvider
+type FeatureFunc func(name string) bool
+
+func (f FeatureFunc) Enabled(name string) bool {
+	return f(name)
+}
+
+// ConfigProvider looks up configuration values, for the config template func
+type ConfigProvider interface {
+	Value(key string) (interface{}, bool)
+}
+
+// ConfigFunc is a func used as a ConfigProvider
+type ConfigFunc func(key string) (interface{}, bool)
+
+func (f ConfigFunc) Value(key string) (interface{}, bool) {
+	return f(key)
+}
+
+// EnvConfig is a ConfigProvider reading environment variables, the key "site.name" reads the variable SITE_NAME
+// after the prefix, such as "APP_" for APP_SITE_NAME
+type EnvConfig string
+
+func (prefix EnvConfig) Value(key string) (interface{}, bool) {
+	name := strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+
+	return os.LookupEnv(string(prefix) + name)
+}
+
+// Template funcs reading Options.Features and Options.Config:
+//
+//	{{if feature "new-nav"}}...{{end}}
+//	<title>{{config "site.name"}}</title>
+var providerFuncs = map[string]interface{}{
+	"feature": feature,
+	"config":  config,
+}
+
+func feature(name string) (bool, error) {
+	if !allowlisted(render.options.FeatureAllowlist, name) {
+		return false, fmt.Errorf("render: feature %q is not in Options.FeatureAllowlist", name)
+	}
+	if render.options.Features == nil {
+		return false, nil
+	}
+
+	return render.options.Features.Enabled(name), nil
+}
+
+func config(key string) (interface{}, error) {
+	if !allowlisted(render.options.ConfigAllowlist, key) {
+		return nil, fmt.Errorf("render: config %q is not in Options.ConfigAllowlist", key)
+	}
+	if render.options.Config == nil {
+		return "", nil
+	}
+
+	if value, ok := render.options.Config.Value(key); ok {
+		return value, nil
+	}
+
+	return "", nil
+}
+
+func allowlisted(allowlist []string, name string) bool {
+	for _, allowed := range allowlist {
+		if allowed == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,328 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Name of the cookie carrying the payload of RedirectWithData
+const flashCookie = "render_flash"
+
+// Name of the cookie carrying the messages of the cookie FlashStore
+const flashMessagesCookie = "render_flashes"
+
+// Browsers drop larger cookies
+const maxFlashCookieLength = 4000
+
+// RedirectWithData redirects to location, carrying payload to the next render in a cookie signed with
+// Options.FlashSecret, which lasts Options.FlashMaxAge. The flash template func returns the payload, decoded as JSON,
+// and the next HTML render of the request clears the cookie. The status defaults to 302 Found, as for Redirect.
+// Suited to post/redirect/get flows:
+//
+//	render.RedirectWithData(w, r, http.StatusSeeOther, "/signup", map[string]interface{}{"Errors": errs})
+//	{{with flash}}{{range .Errors}}<p>{{.}}</p>{{end}}{{end}}
+func RedirectWithData(w http.ResponseWriter, r *http.Request, status int, location string, payload interface{}) {
+	value, err := encodeFlash(flashCookie, payload, time.Now().Add(render.options.FlashMaxAge))
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     flashCookie,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(render.options.FlashMaxAge / time.Second),
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	Redirect(w, r, status, location)
+}
+
+// Flash returns the payload RedirectWithData carried to the request, false when there is none or its cookie is
+// expired or not signed with Options.FlashSecret
+func Flash(r *http.Request) (interface{}, bool) {
+	if r == nil {
+		return nil, false
+	}
+	cookie, err := r.Cookie(flashCookie)
+	if err != nil {
+		return nil, false
+	}
+
+	var payload interface{}
+	if !decodeFlash(flashCookie, cookie.Value, time.Now(), &payload) {
+		return nil, false
+	}
+
+	return payload, true
+}
+
+// FlashMessage is a one-time notice shown by the next HTML render of the client, such as after a post/redirect/get
+type FlashMessage struct {
+	// Level of the message, such as "success", "info", "warning" or "error"
+	Level string
+	Text  string
+}
+
+// FlashStore keeps the flash messages of a client between its requests
+type FlashStore interface {
+	// Add adds message to the messages of the client of r
+	Add(w http.ResponseWriter, r *http.Request, message FlashMessage) error
+	// Consume returns the messages of the client of r, in the order they were added, and deletes them
+	Consume(w http.ResponseWriter, r *http.Request) ([]FlashMessage, error)
+}
+
+type flashesContextKey struct{}
+
+// AddFlash adds a message of level to the Options.FlashStore, which the flashes template func of the next HTML
+// render of the client returns:
+//
+//	render.AddFlash(w, r, "success", "Your profile was saved.")
+//	http.Redirect(w, r, "/profile", http.StatusSeeOther)
+//
+//	{{range flashes}}<div class="alert alert-{{.Level}}">{{.Text}}</div>{{end}}
+func AddFlash(w http.ResponseWriter, r *http.Request, level, text string) error {
+	if render.options.FlashStore == nil {
+		return fmt.Errorf("render: Options.FlashStore and Options.FlashSecret are not set")
+	}
+
+	return render.options.FlashStore.Add(w, r, FlashMessage{Level: level, Text: text})
+}
+
+// Flashes returns the flash messages of the client of r and deletes them, for the responses other than the HTML
+// renders, which consume them for the flashes template func
+func Flashes(w http.ResponseWriter, r *http.Request) ([]FlashMessage, error) {
+	if render.options.FlashStore == nil || r == nil {
+		return nil, nil
+	}
+
+	return render.options.FlashStore.Consume(w, r)
+}
+
+// flashWriter returns the response the flash of a render is cleared on: HTMLOptions.ResponseWriter, else w, nil when
+// w is the recorder of HTMLString whose headers are dropped. The recorder of Capture keeps them in the response.
+func flashWriter(w http.ResponseWriter, option HTMLOptions) http.ResponseWriter {
+	if option.ResponseWriter != nil {
+		return option.ResponseWriter
+	}
+	if _, ok := w.(*responseRecorder); ok {
+		return nil
+	}
+
+	return w
+}
+
+// clearFlash expires the cookie of RedirectWithData, once a render of the request showed it, and consumes the flash
+// messages of the FlashStore, on w, the response of the request. It returns the request carrying the messages to the
+// flashes template func. Nothing is cleared without w.
+func clearFlash(w http.ResponseWriter, r *http.Request) *http.Request {
+	if r == nil || w == nil {
+		return r
+	}
+	if len(render.options.FlashSecret) > 0 {
+		if _, err := r.Cookie(flashCookie); err == nil {
+			http.SetCookie(w, &http.Cookie{Name: flashCookie, Path: "/", MaxAge: -1})
+		}
+	}
+
+	messages, err := Flashes(w, r)
+	if err != nil {
+		logError("render: flash messages not consumed", "err", err)
+	}
+	if len(messages) == 0 {
+		return r
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), flashesContextKey{}, messages))
+}
+
+// flashesFactory makes the flashes template func of a render
+func flashesFactory(scope *Scope) interface{} {
+	return func() []FlashMessage {
+		if scope.Request == nil {
+			return nil
+		}
+		messages, _ := scope.Request.Context().Value(flashesContextKey{}).([]FlashMessage)
+		return messages
+	}
+}
+
+// cookieFlashStore is the default FlashStore, keeping the messages in a cookie signed with Options.FlashSecret
+type cookieFlashStore struct{}
+
+func (cookieFlashStore) Add(w http.ResponseWriter, r *http.Request, message FlashMessage) error {
+	// the messages added before by the same request are in its Set-Cookie header, the others in its cookie
+	var messages []FlashMessage
+	if value, ok := pendingFlashCookie(w); ok {
+		decodeFlash(flashMessagesCookie, value, time.Now(), &messages)
+	} else if cookie, err := r.Cookie(flashMessagesCookie); err == nil {
+		decodeFlash(flashMessagesCookie, cookie.Value, time.Now(), &messages)
+	}
+
+	return setFlashCookie(w, r, append(messages, message))
+}
+
+func (cookieFlashStore) Consume(w http.ResponseWriter, r *http.Request) ([]FlashMessage, error) {
+	cookie, err := r.Cookie(flashMessagesCookie)
+	if err != nil {
+		return nil, nil
+	}
+
+	var messages []FlashMessage
+	decodeFlash(flashMessagesCookie, cookie.Value, time.Now(), &messages)
+
+	// the messages the request added after those of its cookie are kept for the next request
+	if value, ok := pendingFlashCookie(w); ok {
+		var added []FlashMessage
+		if decodeFlash(flashMessagesCookie, value, time.Now(), &added) && len(added) > len(messages) {
+			return messages, setFlashCookie(w, r, added[len(messages):])
+		}
+	}
+	removeSetCookie(w.Header(), flashMessagesCookie)
+	http.SetCookie(w, &http.Cookie{Name: flashMessagesCookie, Path: "/", MaxAge: -1})
+
+	return messages, nil
+}
+
+// pendingFlashCookie returns the value of the flash messages cookie w sets, false when it sets none
+func pendingFlashCookie(w http.ResponseWriter) (string, bool) {
+	pending := (&http.Response{Header: http.Header{"Set-Cookie": w.Header()["Set-Cookie"]}}).Cookies()
+	for i := len(pending) - 1; i >= 0; i-- {
+		if pending[i].Name == flashMessagesCookie {
+			return pending[i].Value, true
+		}
+	}
+
+	return "", false
+}
+
+// setFlashCookie replaces the flash messages cookie w sets with the one of messages
+func setFlashCookie(w http.ResponseWriter, r *http.Request, messages []FlashMessage) error {
+	value, err := encodeFlash(flashMessagesCookie, messages, time.Now().Add(render.options.FlashMaxAge))
+	if err != nil {
+		return err
+	}
+
+	removeSetCookie(w.Header(), flashMessagesCookie)
+	http.SetCookie(w, &http.Cookie{
+		Name:     flashMessagesCookie,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(render.options.FlashMaxAge / time.Second),
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
+
+// removeSetCookie removes the Set-Cookie lines of the cookie name from header
+func removeSetCookie(header http.Header, name string) {
+	var kept []string
+	for _, line := range header["Set-Cookie"] {
+		if !strings.HasPrefix(line, name+"=") {
+			kept = append(kept, line)
+		}
+	}
+	if len(kept) == 0 {
+		header.Del("Set-Cookie")
+		return
+	}
+	header["Set-Cookie"] = kept
+}
+
+// flashFactory makes the flash template func of a render
+func flashFactory(scope *Scope) interface{} {
+	return func() interface{} {
+		payload, _ := Flash(scope.Request)
+		return payload
+	}
+}
+
+// encodeFlash returns the value of the cookie name carrying the payload, "payload.expiry.signature"
+func encodeFlash(name string, payload interface{}, expiry time.Time) (string, error) {
+	if len(render.options.FlashSecret) == 0 {
+		return "", fmt.Errorf("render: Options.FlashSecret is not set")
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	message := base64.RawURLEncoding.EncodeToString(b) + "." + strconv.FormatInt(expiry.Unix(), 10)
+	value := message + "." + base64.RawURLEncoding.EncodeToString(signFlash(name, message))
+	if len(value) > maxFlashCookieLength {
+		return "", fmt.Errorf("render: flash payload of %d bytes is too large for a cookie", len(b))
+	}
+
+	return value, nil
+}
+
+// decodeFlash decodes the payload of the value of the cookie name into payload, false when it is expired or not
+// signed with Options.FlashSecret for that cookie
+func decodeFlash(name, value string, now time.Time, payload interface{}) bool {
+	if len(render.options.FlashSecret) == 0 {
+		return false
+	}
+
+	i := strings.LastIndexByte(value, '.')
+	if i < 0 {
+		return false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(value[i+1:])
+	if err != nil || !hmac.Equal(signature, signFlash(name, value[:i])) {
+		return false
+	}
+
+	parts := strings.Split(value[:i], ".")
+	if len(parts) != 2 {
+		return false
+	}
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || now.Unix() > expiry {
+		return false
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+
+	return json.Unmarshal(b, payload) == nil
+}
+
+// signFlash returns the signature of the message of the cookie name, so that the value of a cookie is not accepted
+// as the one of another
+func signFlash(name, message string) []byte {
+	mac := hmac.New(sha256.New, render.options.FlashSecret)
+	// cookie names have no NUL
+	mac.Write([]byte(name))
+	mac.Write([]byte{0})
+	mac.Write([]byte(message))
+
+	return mac.Sum(nil)
+}
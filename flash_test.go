@@ -0,0 +1,94 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRedirectWithDataDefaultStatus(t *testing.T) {
+	initTemplates(t, nil, Options{FlashSecret: []byte("secret")})
+
+	for status, want := range map[int]int{0: http.StatusFound, http.StatusSeeOther: http.StatusSeeOther} {
+		w := httptest.NewRecorder()
+		RedirectWithData(w, httptest.NewRequest("POST", "/signup", nil), status, "/signup", "taken")
+		if w.Code != want || w.Header().Get("Location") != "/signup" {
+			t.Errorf("status %d: got %d to %q", status, w.Code, w.Header().Get("Location"))
+		}
+
+		r := httptest.NewRequest("GET", "/signup", nil)
+		for _, cookie := range w.Result().Cookies() {
+			r.AddCookie(cookie)
+		}
+		if payload, ok := Flash(r); !ok || payload != "taken" {
+			t.Errorf("status %d: got the payload %v, %v", status, payload, ok)
+		}
+	}
+}
+
+func TestHTMLStringClearsTheFlashOnTheResponse(t *testing.T) {
+	initTemplates(t, map[string]string{"page.tmpl": `{{flash}}{{range flashes}} {{.Text}}{{end}}`}, Options{FlashSecret: []byte("secret")})
+
+	redirect := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/", nil)
+	if err := AddFlash(redirect, r, "info", "message"); err != nil {
+		t.Fatal(err)
+	}
+	RedirectWithData(redirect, r, 0, "/", "payload")
+	r = httptest.NewRequest("GET", "/", nil)
+	for _, cookie := range redirect.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+
+	// without the response, the flash is left for it
+	s, err := HTMLString("page", nil, HTMLOptions{Request: r})
+	if err != nil || s != "payload" {
+		t.Errorf("got %q, %v", s, err)
+	}
+
+	w := httptest.NewRecorder()
+	s, err = HTMLString("page", nil, HTMLOptions{Request: r, ResponseWriter: w})
+	if err != nil || s != "payload message" {
+		t.Errorf("got %q, %v", s, err)
+	}
+	cleared := map[string]bool{}
+	for _, cookie := range w.Result().Cookies() {
+		cleared[cookie.Name] = cookie.MaxAge < 0
+	}
+	if !cleared[flashCookie] || !cleared[flashMessagesCookie] {
+		t.Errorf("the flash cookies were not cleared on the response: %v", w.Header()["Set-Cookie"])
+	}
+}
+
+func TestFlashCookiesAreNotInterchangeable(t *testing.T) {
+	initTemplates(t, nil, Options{FlashSecret: []byte("secret")})
+
+	// messages signed for the flash messages cookie, which also decode as a RedirectWithData payload
+	value, err := encodeFlash(flashMessagesCookie, []FlashMessage{{Level: "info", Text: "message"}}, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: flashCookie, Value: value})
+	if payload, ok := Flash(r); ok {
+		t.Errorf("the value of the flash messages cookie was accepted as the flash: %v", payload)
+	}
+
+	var messages []FlashMessage
+	if !decodeFlash(flashMessagesCookie, value, time.Now(), &messages) || len(messages) != 1 {
+		t.Errorf("got %v", messages)
+	}
+}
@@ -0,0 +1,199 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"context"
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LocaleFormat is how a locale writes dates and times, numbers, percentages and amounts following the CLDR data of
+// golang.org/x/text
+type LocaleFormat struct {
+	// Layouts of time.Format for dates and times. time.Format writes the names of the months, the days and AM/PM in
+	// English, which is why the built-in layouts of the other locales are numeric.
+	Date string
+	Time string
+}
+
+// Date and time formats of the common locales, matched to the locale of a render with a language.Matcher so that
+// regional variants such as "de-CH" use the closest one. Options.LocaleFormats adds or overrides locales.
+var localeFormats = map[string]LocaleFormat{
+	"en":    {Date: "Jan 2, 2006", Time: "3:04 PM"},
+	"en-GB": {Date: "2 Jan 2006", Time: "15:04"},
+	"de":    {Date: "02.01.2006", Time: "15:04"},
+	"es":    {Date: "2/1/2006", Time: "15:04"},
+	"fr":    {Date: "02/01/2006", Time: "15:04"},
+	"it":    {Date: "02/01/2006", Time: "15:04"},
+	"ja":    {Date: "2006/01/02", Time: "15:04"},
+	"nl":    {Date: "02-01-2006", Time: "15:04"},
+	"pl":    {Date: "02.01.2006", Time: "15:04"},
+	"pt":    {Date: "02/01/2006", Time: "15:04"},
+	"ru":    {Date: "02.01.2006", Time: "15:04"},
+	"zh":    {Date: "2006/1/2", Time: "15:04"},
+}
+
+// Locales of the date and time formats, English first as the default, and their matcher, made by resetLocaleFormats
+var (
+	localeFormatTags    []string
+	localeFormatMatcher language.Matcher
+)
+
+// Maximum number of message printers kept, the locales of the renders coming from the requests
+const maxLocalePrinters = 256
+
+// Message printers of the format funcs, by language tag, the least recently used dropped beyond maxLocalePrinters
+var (
+	localePrintersMu sync.Mutex
+	localePrinters   lruCache
+)
+
+// resetLocaleFormats makes the matcher of the built-in date and time formats and of Options.LocaleFormats
+func resetLocaleFormats() {
+	tags := []string{"en"}
+	for _, formats := range []map[string]LocaleFormat{localeFormats, render.options.LocaleFormats} {
+		for tag := range formats {
+			if tag != "en" && !containsString(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags[1:])
+
+	languageTags := make([]language.Tag, 0, len(tags))
+	for _, tag := range tags {
+		languageTags = append(languageTags, language.Make(tag))
+	}
+
+	localeFormatTags = tags
+	localeFormatMatcher = language.NewMatcher(languageTags)
+}
+
+type timeZoneContextKey struct{}
+
+// WithTimeZone returns a copy of ctx carrying the time zone the format funcs write times in, for the renders of
+// requests with that context
+func WithTimeZone(ctx context.Context, location *time.Location) context.Context {
+	return context.WithValue(ctx, timeZoneContextKey{}, location)
+}
+
+// formatFactories makes the format template funcs of a render, in the locale and the time zone of the render:
+//
+//	{{formatNumber .Total 2}} {{formatCurrency .Price "EUR"}} {{formatPercent .Ratio 1}}
+//	{{formatDate .Created}} {{formatTime .Created}}
+var formatFactories = map[string]FuncFactory{
+	"formatNumber": func(scope *Scope) interface{} {
+		return func(v interface{}, decimals int) string {
+			return localePrinter(scope.Locale).Sprint(number.Decimal(toFloat64(v), number.Scale(decimals)))
+		}
+	},
+	"formatCurrency": func(scope *Scope) interface{} {
+		return func(v interface{}, code string) string {
+			return formatCurrency(localePrinter(scope.Locale), toFloat64(v), code)
+		}
+	},
+	"formatPercent": func(scope *Scope) interface{} {
+		return func(v interface{}, decimals int) string {
+			return localePrinter(scope.Locale).Sprint(number.Percent(toFloat64(v), number.Scale(decimals)))
+		}
+	},
+	"formatDate": func(scope *Scope) interface{} {
+		return func(t interface{}) string {
+			return scopeTime(scope, t).Format(localeFormat(scope.Locale).Date)
+		}
+	},
+	"formatTime": func(scope *Scope) interface{} {
+		return func(t interface{}) string {
+			return scopeTime(scope, t).Format(localeFormat(scope.Locale).Time)
+		}
+	},
+}
+
+// localePrinter returns the message printer of the locale, English when it is empty or not a BCP 47 tag
+func localePrinter(locale string) *message.Printer {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		if locale != "" {
+			logDebug("render: format funcs fall back to English", "locale", locale, "error", err)
+		}
+		tag = language.English
+	}
+	key := tag.String()
+
+	localePrintersMu.Lock()
+	defer localePrintersMu.Unlock()
+
+	if p, ok := localePrinters.get(key); ok {
+		return p.(*message.Printer)
+	}
+	p := message.NewPrinter(tag)
+	localePrinters.add(key, p, 0, maxLocalePrinters, 0)
+
+	return p
+}
+
+// localeFormat returns the date and time format of the locale, or of the closest one, English by default
+func localeFormat(locale string) LocaleFormat {
+	if format, ok := render.options.LocaleFormats[locale]; ok {
+		return format
+	}
+	if format, ok := localeFormats[locale]; ok {
+		return format
+	}
+	if localeFormatMatcher == nil {
+		return localeFormats["en"]
+	}
+
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return localeFormats["en"]
+	}
+	_, index, confidence := localeFormatMatcher.Match(tag)
+	if confidence == language.No {
+		logDebug("render: no date format matches the locale, using English", "locale", locale)
+		index = 0
+	}
+	if format, ok := render.options.LocaleFormats[localeFormatTags[index]]; ok {
+		return format
+	}
+
+	return localeFormats[localeFormatTags[index]]
+}
+
+// formatCurrency writes the amount with the symbol and the minor unit of the ISO 4217 currency code, or with the code
+// when it is not a currency
+func formatCurrency(p *message.Printer, amount float64, code string) string {
+	unit, err := currency.ParseISO(code)
+	if err != nil {
+		return strings.ToUpper(code) + "\u00a0" + p.Sprint(number.Decimal(amount, number.Scale(2)))
+	}
+
+	return p.Sprint(currency.Symbol(unit.Amount(amount)))
+}
+
+// scopeTime returns t in the time zone of the render: HTMLOptions.TimeZone, or the one of the request context
+func scopeTime(scope *Scope, v interface{}) time.Time {
+	t := toTime(v)
+	if scope.TimeZone != nil {
+		return t.In(scope.TimeZone)
+	}
+
+	return t
+}
@@ -0,0 +1,72 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatFuncsOfRegionalLocales(t *testing.T) {
+	initTemplates(t, map[string]string{
+		"number.tmpl":   `{{formatNumber . 2}}`,
+		"currency.tmpl": `{{formatCurrency . "USD"}}`,
+		"date.tmpl":     `{{formatDate .}}`,
+	}, Options{EnableFormatFuncs: true})
+
+	format := func(name, locale string, binding interface{}) string {
+		w := httptest.NewRecorder()
+		if err := HTMLE(w, 200, name, binding, HTMLOptions{Locale: locale}); err != nil {
+			t.Fatal(err)
+		}
+		return w.Body.String()
+	}
+
+	if got := format("number", "en-IN", 1234567); got != "12,34,567.00" {
+		t.Errorf("en-IN: got %q, want lakh grouping", got)
+	}
+	if swiss, german := format("number", "de-CH", 1234.5), format("number", "de", 1234.5); swiss == german || !strings.HasSuffix(swiss, ".50") {
+		t.Errorf("de-CH: got %q, de %q", swiss, german)
+	}
+	if got := format("number", "sv", 1234.5); !strings.HasSuffix(got, ",50") {
+		t.Errorf("unlisted sv: got %q", got)
+	}
+	if got := format("currency", "en", 1234.5); !strings.Contains(got, "$") || !strings.Contains(got, "1,234.50") {
+		t.Errorf("USD: got %q", got)
+	}
+
+	created := time.Date(2018, 3, 9, 0, 0, 0, 0, time.UTC)
+	for locale, want := range map[string]string{"de-CH": "09.03.2018", "en-GB": "9 Mar 2018", "xx": "Mar 9, 2018"} {
+		if got := format("date", locale, created); got != want {
+			t.Errorf("date of %s: got %q, want %q", locale, got, want)
+		}
+	}
+}
+
+func TestLocalePrintersAreBounded(t *testing.T) {
+	for i := 0; i < 2*maxLocalePrinters; i++ {
+		localePrinter(fmt.Sprintf("en-x-%d", i))
+	}
+	if p, q := localePrinter("de-CH"), localePrinter("de-ch"); p != q {
+		t.Error("the same language tag got two printers")
+	}
+
+	localePrintersMu.Lock()
+	defer localePrintersMu.Unlock()
+	if stats := localePrinters.stats(maxLocalePrinters, 0); stats.Entries > maxLocalePrinters {
+		t.Errorf("got %+v", stats)
+	}
+}
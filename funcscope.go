@@ -0,0 +1,159 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+// The funcs of a template set are shared by all its templates, so the funcs of Options.ScopedFuncMaps are added
+// under a name made of their scope, which the calls of the templates within that scope are renamed to once parsed.
+
+// funcScopes returns the patterns of Options.ScopedFuncMaps, shortest first for the longest to take precedence
+func funcScopes() []string {
+	var patterns []string
+	for pattern := range render.options.ScopedFuncMaps {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) < len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+
+	return patterns
+}
+
+// matchFuncScope tells whether the template name is within the scope pattern, a directory such as "admin/" or a
+// path.Match pattern such as "admin/*"
+func matchFuncScope(pattern, name string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(name, pattern)
+	}
+
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+func scopedFuncName(name string, scope int) string {
+	return fmt.Sprintf("%s_scope%d", name, scope)
+}
+
+// scopedFuncs returns the funcs of Options.ScopedFuncMaps under their scoped names, along with placeholders declaring
+// their names to the templates out of scope, which fail when called
+func scopedFuncs() map[string]interface{} {
+	funcs := map[string]interface{}{}
+	for i, pattern := range funcScopes() {
+		for name, f := range render.options.ScopedFuncMaps[pattern] {
+			name := name
+			if _, ok := funcs[name]; !ok {
+				funcs[name] = func(...interface{}) (string, error) {
+					return "", fmt.Errorf("render: func %s is not available to this template", name)
+				}
+			}
+			funcs[scopedFuncName(name, i)] = f
+		}
+	}
+
+	return funcs
+}
+
+// scopeRenames maps the funcs of the scopes of the template name to their scoped names
+func scopeRenames(name string) map[string]string {
+	renames := map[string]string{}
+	for i, pattern := range funcScopes() {
+		if !matchFuncScope(pattern, name) {
+			continue
+		}
+		for f := range render.options.ScopedFuncMaps[pattern] {
+			renames[f] = scopedFuncName(f, i)
+		}
+	}
+
+	return renames
+}
+
+// parseHTMLTemplate parses the source of the template name into set, with the scoped funcs of name. It returns the
+// trees of the templates the source defines, sorted by name.
+func parseHTMLTemplate(set *template.Template, name, source string) ([]*parse.Tree, error) {
+	before := map[string]*parse.Tree{}
+	for _, t := range set.Templates() {
+		before[t.Name()] = t.Tree
+	}
+	if _, err := set.New(name).Parse(source); err != nil {
+		return nil, err
+	}
+
+	var trees []*parse.Tree
+	for _, t := range set.Templates() {
+		if t.Tree != nil && t.Tree != before[t.Name()] {
+			trees = append(trees, t.Tree)
+		}
+	}
+
+	return scopeTrees(name, trees), nil
+}
+
+// parseTextTemplate is parseHTMLTemplate for text templates
+func parseTextTemplate(set *texttemplate.Template, name, source string) ([]*parse.Tree, error) {
+	before := map[string]*parse.Tree{}
+	for _, t := range set.Templates() {
+		before[t.Name()] = t.Tree
+	}
+	if _, err := set.New(name).Parse(source); err != nil {
+		return nil, err
+	}
+
+	var trees []*parse.Tree
+	for _, t := range set.Templates() {
+		if t.Tree != nil && t.Tree != before[t.Name()] {
+			trees = append(trees, t.Tree)
+		}
+	}
+
+	return scopeTrees(name, trees), nil
+}
+
+// scopeTrees renames the calls of the scoped funcs of the template name in the trees parsed from its source, which
+// are sorted by name
+func scopeTrees(name string, trees []*parse.Tree) []*parse.Tree {
+	sort.Slice(trees, func(i, j int) bool {
+		return trees[i].Name < trees[j].Name
+	})
+
+	if renames := scopeRenames(name); len(renames) > 0 {
+		for _, tree := range trees {
+			renameFuncs(tree.Root, renames)
+		}
+	}
+
+	return trees
+}
+
+// renameFuncs renames the func calls of the tree
+func renameFuncs(node parse.Node, renames map[string]string) {
+	walkNodes(node, func(n parse.Node) {
+		if ident, ok := n.(*parse.IdentifierNode); ok {
+			if name, ok := renames[ident.Ident]; ok {
+				ident.Ident = name
+			}
+		}
+	})
+}
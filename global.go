@@ -0,0 +1,62 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"html/template"
+	"sync"
+)
+
+// Values of Options.GlobalData and SetGlobal, by key
+var (
+	globalData   map[string]interface{}
+	globalDataMu sync.RWMutex
+)
+
+var globalFuncs = template.FuncMap{
+	"global": global,
+}
+
+// resetGlobalData replaces the global values with a copy of Options.GlobalData
+func resetGlobalData() {
+	data := make(map[string]interface{}, len(render.options.GlobalData))
+	for key, value := range render.options.GlobalData {
+		data[key] = value
+	}
+
+	globalDataMu.Lock()
+	globalData = data
+	globalDataMu.Unlock()
+}
+
+// SetGlobal sets the global value of key, available to all the templates like those of Options.GlobalData, such as
+// the version of the application refreshed at runtime
+func SetGlobal(key string, value interface{}) {
+	globalDataMu.Lock()
+	defer globalDataMu.Unlock()
+
+	if globalData == nil {
+		globalData = map[string]interface{}{}
+	}
+	globalData[key] = value
+}
+
+// global returns the global value of key, nil when there is none, whatever the binding of the template:
+//
+//	<footer>v{{ global "Version" }}</footer>
+func global(key string) interface{} {
+	globalDataMu.RLock()
+	defer globalDataMu.RUnlock()
+
+	return globalData[key]
+}
@@ -0,0 +1,103 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ErrForbidden is returned by the renders of templates when a guard of Options.Guards denies the template and there
+// is no forbidden page to render, such as by HTMLE or HTMLString. HTML answers it with a 403 Forbidden.
+var ErrForbidden = errors.New("render: the template is forbidden to the principal of the request")
+
+// Guard tells whether the principal of a request, such as the signed in user, may see a template. The principal is
+// nil when the request has none.
+type Guard func(principal interface{}) bool
+
+type principalContextKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal the Options.Guards of the renders of requests with
+// that context are given, when Options.Principal is not set
+func WithPrincipal(ctx context.Context, principal interface{}) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// requestPrincipal returns the principal of r, of Options.Principal or else of WithPrincipal
+func requestPrincipal(r *http.Request) interface{} {
+	if r == nil {
+		return nil
+	}
+	if principal := render.options.Principal; principal != nil {
+		return principal(r)
+	}
+
+	return r.Context().Value(principalContextKey{})
+}
+
+// guardAllows tells whether the guards of the template name all allow the principal of r
+func guardAllows(r *http.Request, name string) bool {
+	if len(render.options.Guards) == 0 {
+		return true
+	}
+
+	principal := requestPrincipal(r)
+	for pattern, guard := range render.options.Guards {
+		if matchFuncScope(pattern, name) && !guard(principal) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkGuards returns ErrForbidden when a guard of one of the templates names denies the principal of r
+func checkGuards(r *http.Request, names ...string) error {
+	for _, name := range names {
+		if !guardAllows(r, name) {
+			logDebug("render: template denied by its guard", "template", name)
+			return ErrForbidden
+		}
+	}
+
+	return nil
+}
+
+// renderForbidden renders Options.ForbiddenTemplate, or else the error page of 403, with the name of the template
+// denied by its guards, returning ErrForbidden when there is no such template
+func renderForbidden(w http.ResponseWriter, name string, option HTMLOptions) error {
+	page := render.options.ForbiddenTemplate
+	if len(page) == 0 {
+		page = errorPage(http.StatusForbidden)
+	}
+	if len(page) == 0 || !definedTemplate(page) {
+		return ErrForbidden
+	}
+
+	buf, err := renderHTML(w, page, name, option)
+	if buf != nil {
+		defer buf.release()
+	}
+	if err != nil {
+		return err
+	}
+
+	call := callOptions{
+		request:    option.Request,
+		header:     option.Header,
+		noCompress: option.NoCompress,
+	}
+
+	return writeRendered(w, http.StatusForbidden, callContentType(render.options.HTMLContentType, option.ContentType, option.Charset), call, buf)
+}
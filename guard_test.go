@@ -0,0 +1,98 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// copyMarkdown is a MarkdownConverter writing the source as is
+type copyMarkdown struct{}
+
+func (copyMarkdown) Convert(source []byte, w io.Writer) error {
+	_, err := w.Write(source)
+	return err
+}
+
+func TestGuardsOfEveryRender(t *testing.T) {
+	initTemplates(t, map[string]string{
+		"admin/secret.tmpl":     "secret page",
+		"admin/secret.md":       "secret markdown",
+		"admin/secret.mustache": "secret mustache",
+		"forbidden.tmpl":        "forbidden {{.}}",
+	}, Options{
+		MarkdownExtensions: []string{".md"},
+		MustacheExtensions: []string{".mustache"},
+		Markdown:           copyMarkdown{},
+		ForbiddenTemplate:  "forbidden",
+		Guards: map[string]Guard{
+			"admin/*": func(principal interface{}) bool { return principal == "admin" },
+		},
+	})
+
+	renders := map[string]func(w http.ResponseWriter, option HTMLOptions){
+		"HTML": func(w http.ResponseWriter, option HTMLOptions) {
+			HTML(w, 200, "admin/secret", nil, option)
+		},
+		"HTMLStream": func(w http.ResponseWriter, option HTMLOptions) {
+			HTMLStream(w, 200, "admin/secret", nil, option)
+		},
+		"HTMLMulti": func(w http.ResponseWriter, option HTMLOptions) {
+			HTMLMulti(w, 200, []string{"admin/*"}, nil, option)
+		},
+		"Markdown": func(w http.ResponseWriter, option HTMLOptions) {
+			Markdown(w, 200, "admin/secret", nil, option)
+		},
+		"Mustache": func(w http.ResponseWriter, option HTMLOptions) {
+			Mustache(w, 200, "admin/secret", nil, option)
+		},
+		"CaptureHTML": func(w http.ResponseWriter, option HTMLOptions) {
+			resp, err := CaptureHTML(200, "admin/secret", nil, option)
+			if err != nil {
+				renderError(w, option.Request, err)
+				return
+			}
+			w.WriteHeader(resp.Status)
+			w.Write(resp.Body)
+		},
+		"HTMLString": func(w http.ResponseWriter, option HTMLOptions) {
+			s, err := HTMLString("admin/secret", nil, option)
+			if err != nil {
+				renderError(w, option.Request, err)
+				return
+			}
+			io.WriteString(w, s)
+		},
+	}
+
+	for name, render := range renders {
+		for _, principal := range []string{"admin", "guest"} {
+			r := httptest.NewRequest("GET", "/", nil)
+			r = r.WithContext(WithPrincipal(r.Context(), principal))
+			w := httptest.NewRecorder()
+			render(w, HTMLOptions{Request: r})
+
+			body := w.Body.String()
+			if principal == "admin" && (w.Code != 200 || !strings.Contains(body, "secret")) {
+				t.Errorf("%s as admin: got %d %q", name, w.Code, body)
+			}
+			if principal == "guest" && (w.Code != http.StatusForbidden || strings.Contains(body, "secret ")) {
+				t.Errorf("%s as guest: got %d %q", name, w.Code, body)
+			}
+		}
+	}
+}
@@ -0,0 +1,183 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// HALLink is a link object of a HAL resource
+type HALLink struct {
+	Href        string `json:"href"`
+	Templated   bool   `json:"templated,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Deprecation string `json:"deprecation,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Profile     string `json:"profile,omitempty"`
+	Title       string `json:"title,omitempty"`
+	HrefLang    string `json:"hreflang,omitempty"`
+}
+
+// HALResource is a HAL resource made of the state properties, the _links and the _embedded resources. A relation
+// with a single link or a single embedded resource is written as an object, otherwise as an array.
+type HALResource struct {
+	// State properties of the resource, a struct or a map which marshals to a JSON object
+	State    interface{}
+	Links    map[string][]HALLink
+	Embedded map[string][]*HALResource
+	// Embedded relations always written as an array
+	collections map[string]bool
+}
+
+// NewHALResource returns a HAL resource for the given state with a self link to href
+func NewHALResource(state interface{}, href string) *HALResource {
+	r := &HALResource{State: state}
+	if len(href) > 0 {
+		r.Link("self", href)
+	}
+
+	return r
+}
+
+// Link adds a link to href for the relation rel
+func (r *HALResource) Link(rel, href string) *HALResource {
+	return r.AddLink(rel, HALLink{Href: href})
+}
+
+// AddLink adds a link object for the relation rel
+func (r *HALResource) AddLink(rel string, link HALLink) *HALResource {
+	if r.Links == nil {
+		r.Links = map[string][]HALLink{}
+	}
+	r.Links[rel] = append(r.Links[rel], link)
+
+	return r
+}
+
+// Embed adds embedded resources for the relation rel
+func (r *HALResource) Embed(rel string, resources ...*HALResource) *HALResource {
+	if r.Embedded == nil {
+		r.Embedded = map[string][]*HALResource{}
+	}
+	r.Embedded[rel] = append(r.Embedded[rel], resources...)
+
+	return r
+}
+
+// EmbedCollection adds embedded resources for the relation rel, which is written as an array even when it holds
+// a single or no resource
+func (r *HALResource) EmbedCollection(rel string, resources []*HALResource) *HALResource {
+	if r.collections == nil {
+		r.collections = map[string]bool{}
+	}
+	r.collections[rel] = true
+	if r.Embedded == nil {
+		r.Embedded = map[string][]*HALResource{}
+	}
+	r.Embedded[rel] = append(r.Embedded[rel], resources...)
+
+	return r
+}
+
+// MarshalJSON implements json.Marshaler, with Options.JSONEncoder and the redaction of Options
+func (r *HALResource) MarshalJSON() ([]byte, error) {
+	document, err := r.document()
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonEncoder().Marshal(document)
+}
+
+// document returns the HAL document of the resource, its state properties redacted and marshaled by
+// Options.JSONEncoder, and its embedded resources as documents too, so that the document is marshaled at once. A nil
+// resource is an empty document.
+func (r *HALResource) document() (map[string]interface{}, error) {
+	document := map[string]interface{}{}
+	if r == nil {
+		return document, nil
+	}
+
+	if r.State != nil {
+		state, err := jsonEncoder().Marshal(redact(r.State))
+		if err != nil {
+			return nil, err
+		}
+
+		var properties map[string]json.RawMessage
+		if err := json.Unmarshal(state, &properties); err != nil {
+			return nil, fmt.Errorf("render: HAL state must be a JSON object: %s", err.Error())
+		}
+		for key, value := range properties {
+			document[key] = value
+		}
+	}
+
+	if len(r.Links) > 0 {
+		links := map[string]interface{}{}
+		for rel, link := range r.Links {
+			if len(link) == 1 {
+				links[rel] = link[0]
+			} else {
+				links[rel] = link
+			}
+		}
+		document["_links"] = links
+	}
+
+	if len(r.Embedded) > 0 {
+		embedded := map[string]interface{}{}
+		for rel, resources := range r.Embedded {
+			documents := make([]map[string]interface{}, 0, len(resources))
+			for _, resource := range resources {
+				d, err := resource.document()
+				if err != nil {
+					return nil, err
+				}
+				documents = append(documents, d)
+			}
+			if len(documents) == 1 && !r.collections[rel] {
+				embedded[rel] = documents[0]
+			} else {
+				embedded[rel] = documents
+			}
+		}
+		document["_embedded"] = embedded
+	}
+
+	return document, nil
+}
+
+// HAL writes the resource as a HAL document, marshaled by Options.JSONEncoder with the redaction, escaping and
+// indentation of Options and of jsonOptions. A nil resource is written as {}.
+func HAL(w http.ResponseWriter, status int, resource *HALResource, jsonOptions ...JSONOptions) {
+	if err := HALE(w, status, resource, jsonOptions...); err != nil {
+		renderError(w, prepareJSONOptions(jsonOptions).Request, err)
+	}
+}
+
+// HALE is HAL returning the marshal error instead of answering it with a 500, nothing is written then
+func HALE(w http.ResponseWriter, status int, resource *HALResource, jsonOptions ...JSONOptions) (err error) {
+	option := prepareJSONOptions(jsonOptions)
+	w, done := observeRender(w, option.Request, FormatJSON, "")
+	defer done(&err)
+
+	document, err := resource.document()
+	if err != nil {
+		return err
+	}
+
+	return renderJSON(w, status, callContentType(ContentHAL, option.ContentType, option.Charset), document, jsonCall(option))
+}
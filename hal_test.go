@@ -0,0 +1,94 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// countingJSON is a JSONEncoder counting the values it marshals
+type countingJSON struct {
+	calls *int
+}
+
+func (c countingJSON) Marshal(v interface{}) ([]byte, error) {
+	*c.calls++
+	return json.Marshal(v)
+}
+
+func (c countingJSON) MarshalIndent(v interface{}, prefix, indent string) ([]byte, error) {
+	*c.calls++
+	return json.MarshalIndent(v, prefix, indent)
+}
+
+func TestHALEncoding(t *testing.T) {
+	calls := 0
+	initTemplates(t, nil, Options{JSONEncoder: countingJSON{&calls}, JSONNoEscapeHTML: true})
+
+	order := NewHALResource(map[string]interface{}{"note": "<b>fragile</b>"}, "/orders/1").
+		Embed("customer", NewHALResource(redactBase{Name: "ann", Password: "secret"}, "/customers/1"))
+	w := httptest.NewRecorder()
+	HAL(w, 200, order)
+
+	body := w.Body.String()
+	if strings.Contains(body, "secret") || !strings.Contains(body, RedactMask) {
+		t.Errorf("the embedded state was not redacted: %s", body)
+	}
+	if !strings.Contains(body, "<b>fragile</b>") {
+		t.Errorf("HTML escaped despite JSONNoEscapeHTML: %s", body)
+	}
+	// the two states and the document
+	if calls != 3 {
+		t.Errorf("Options.JSONEncoder marshaled %d values, want 3", calls)
+	}
+}
+
+func TestHALWithoutResource(t *testing.T) {
+	initTemplates(t, nil, Options{})
+
+	w := httptest.NewRecorder()
+	if err := HALE(w, 200, nil); err != nil {
+		t.Fatal(err)
+	}
+	if w.Body.String() != "{}" || w.Header().Get(ContentType) != ContentHAL+"; charset=UTF-8" {
+		t.Errorf("got %q, %q", w.Body.String(), w.Header().Get(ContentType))
+	}
+
+	w = httptest.NewRecorder()
+	HAL(w, 200, NewHALResource(nil, "/orders").EmbedCollection("orders", []*HALResource{nil}))
+	if want := `{"_embedded":{"orders":[{}]},"_links":{"self":{"href":"/orders"}}}`; w.Body.String() != want {
+		t.Errorf("got %s, want %s", w.Body.String(), want)
+	}
+}
+
+func TestHALE(t *testing.T) {
+	initTemplates(t, nil, Options{})
+
+	w := httptest.NewRecorder()
+	if err := HALE(w, 200, NewHALResource([]int{1}, "")); err == nil {
+		t.Error("a state which is not an object was written")
+	}
+	if w.Body.Len() > 0 {
+		t.Errorf("HALE wrote %q on error", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/orders/1", nil)
+	HAL(w, 200, NewHALResource(map[string]int{"total": 1}, "/orders/1"), JSONOptions{Request: r, GenerateETag: true})
+	if w.Code != 200 || len(w.Header().Get(ETag)) == 0 {
+		t.Errorf("got %d, ETag %q", w.Code, w.Header().Get(ETag))
+	}
+}
@@ -0,0 +1,135 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Statuses of Health
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+)
+
+// HealthStatus tells whether the last load of the templates succeeded
+type HealthStatus struct {
+	// HealthOK, or HealthDegraded when the last reload failed and the templates loaded before are served
+	Status string `json:"status"`
+	// Error of the failed reload
+	Error string `json:"error,omitempty"`
+	// Time of the first of the failed reloads, nil when the status is HealthOK
+	Since *time.Time `json:"since,omitempty"`
+}
+
+var (
+	healthMu sync.Mutex
+	// Error of the last reload, nil when it succeeded
+	reloadErr error
+	// Time of the first failed reload
+	reloadFailedAt time.Time
+	// Hooks called when a reload fails
+	reloadErrorHooks []func(err error)
+)
+
+// OnReloadError registers a hook called when a reload of the templates fails, of Reload or of the debug mode watcher,
+// and again when the error changes, such as to alert on a broken deployment. Hooks are meant to be registered at
+// startup, before Init.
+func OnReloadError(hook func(err error)) {
+	reloadErrorHooks = append(reloadErrorHooks, hook)
+}
+
+// Health returns whether the templates served are the ones of the files, or stale ones kept after a failed reload
+func Health() HealthStatus {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+
+	if reloadErr == nil {
+		return HealthStatus{Status: HealthOK}
+	}
+
+	since := reloadFailedAt
+	return HealthStatus{Status: HealthDegraded, Error: reloadErr.Error(), Since: &since}
+}
+
+// HealthHandler answers the Health of the templates as JSON, with 200 OK even when degraded since the pages are
+// still served
+func HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := json.Marshal(Health())
+		if err != nil {
+			renderError(w, r, err)
+			return
+		}
+		w.Header().Set(ContentType, ContentJSON+prepareCharset(""))
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	})
+}
+
+// recordReload records the result of a reload, calling the hooks when it failed with a new error
+func recordReload(err error) {
+	healthMu.Lock()
+	changed := false
+	if err == nil {
+		reloadErr = nil
+		reloadFailedAt = time.Time{}
+	} else {
+		if reloadErr == nil {
+			reloadFailedAt = time.Now()
+		}
+		changed = reloadErr == nil || reloadErr.Error() != err.Error()
+		reloadErr = err
+	}
+	healthMu.Unlock()
+
+	if !changed {
+		return
+	}
+	logError("render: reload failed, the templates loaded before are kept", "err", err)
+	for _, hook := range reloadErrorHooks {
+		hook(err)
+	}
+}
+
+// resetHealth sets the health after the first load of the templates, without calling the hooks
+func resetHealth(err error) {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+
+	reloadErr = err
+	reloadFailedAt = time.Time{}
+	if err != nil {
+		reloadFailedAt = time.Now()
+	}
+}
+
+// staleError returns the error of a failed reload, nil while Options.StaleFor lets the templates loaded before be
+// served
+func staleError(err error) error {
+	if err == nil || render.options.StaleFor <= 0 {
+		return err
+	}
+
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	if time.Since(reloadFailedAt) < render.options.StaleFor {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,404 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Template funcs added by Options.EnableHelperFuncs, named after their sprig counterparts. Numbers of any type are
+// accepted, the integer funcs return an int64.
+var helperLibrary = map[string]interface{}{
+	// strings
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+	"title":      titleCase,
+	"trim":       strings.TrimSpace,
+	"trimAll":    func(cutset, s string) string { return strings.Trim(s, cutset) },
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+	"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+	"replace":    func(old, new, s string) string { return strings.Replace(s, old, new, -1) },
+	"repeat":     func(count int, s string) string { return strings.Repeat(s, count) },
+	"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+	"join":       join,
+	"substr":     substr,
+	"trunc":      trunc,
+	"abbrev":     abbrev,
+	"nospace":    func(s string) string { return strings.Join(strings.Fields(s), "") },
+	"quote":      func(v interface{}) string { return strconv.Quote(toString(v)) },
+	"toString":   toString,
+	"toJson":     toJSON,
+
+	// math
+	"add":   func(a interface{}, b ...interface{}) int64 { return foldInt64(a, b, addInt64) },
+	"add1":  func(a interface{}) int64 { return toInt64(a) + 1 },
+	"sub":   func(a, b interface{}) int64 { return toInt64(a) - toInt64(b) },
+	"mul":   func(a interface{}, b ...interface{}) int64 { return foldInt64(a, b, mulInt64) },
+	"div":   divInt64,
+	"mod":   modInt64,
+	"max":   func(a interface{}, b ...interface{}) int64 { return foldInt64(a, b, maxInt64) },
+	"min":   func(a interface{}, b ...interface{}) int64 { return foldInt64(a, b, minInt64) },
+	"addf":  func(a, b interface{}) float64 { return toFloat64(a) + toFloat64(b) },
+	"subf":  func(a, b interface{}) float64 { return toFloat64(a) - toFloat64(b) },
+	"mulf":  func(a, b interface{}) float64 { return toFloat64(a) * toFloat64(b) },
+	"divf":  func(a, b interface{}) float64 { return toFloat64(a) / toFloat64(b) },
+	"round": func(v interface{}, places int) float64 { return round(toFloat64(v), places) },
+	"toInt": toInt64,
+
+	// dicts and lists
+	"dict":   dict,
+	"list":   func(v ...interface{}) []interface{} { return v },
+	"keys":   keys,
+	"hasKey": func(d map[string]interface{}, key string) bool { _, ok := d[key]; return ok },
+	"get":    func(d map[string]interface{}, key string) interface{} { return d[key] },
+	"first":  first,
+	"last":   last,
+	"has":    has,
+	"until":  until,
+
+	// defaults and conditions
+	"default":  defaultValue,
+	"empty":    empty,
+	"coalesce": coalesce,
+	"ternary": func(whenTrue, whenFalse interface{}, condition bool) interface{} {
+		if condition {
+			return whenTrue
+		}
+		return whenFalse
+	},
+
+	// dates
+	"now":        time.Now,
+	"date":       func(layout string, t interface{}) string { return toTime(t).Format(layout) },
+	"dateInZone": dateInZone,
+	"toDate":     time.Parse,
+	"unixEpoch":  func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) },
+	"ago":        func(t time.Time) string { return time.Since(t).Round(time.Second).String() },
+}
+
+// titleCase upper cases the first letter of each word of s
+func titleCase(s string) string {
+	runes := []rune(s)
+	for i, r := range runes {
+		if i == 0 || unicode.IsSpace(runes[i-1]) {
+			runes[i] = unicode.ToTitle(r)
+		}
+	}
+
+	return string(runes)
+}
+
+func join(sep string, v interface{}) string {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return toString(v)
+	}
+
+	parts := make([]string, value.Len())
+	for i := range parts {
+		parts[i] = toString(value.Index(i).Interface())
+	}
+
+	return strings.Join(parts, sep)
+}
+
+// substr returns the runes of s from start to end, to the end of s when end is negative
+func substr(start, end int, s string) string {
+	runes := []rune(s)
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 || end > len(runes) {
+		end = len(runes)
+	}
+	if start > end {
+		return ""
+	}
+
+	return string(runes[start:end])
+}
+
+// trunc keeps the first n runes of s, or the last -n runes when n is negative
+func trunc(n int, s string) string {
+	count := utf8.RuneCountInString(s)
+	if n < 0 {
+		return substr(count+n, count, s)
+	}
+
+	return substr(0, n, s)
+}
+
+// abbrev truncates s to width runes, ending with an ellipsis when it is shortened
+func abbrev(width int, s string) string {
+	if width < 4 || utf8.RuneCountInString(s) <= width {
+		return s
+	}
+
+	return substr(0, width-3, s) + "..."
+}
+
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	case fmt.Stringer:
+		return s.String()
+	case error:
+		return s.Error()
+	}
+
+	return fmt.Sprint(v)
+}
+
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func toInt64(v interface{}) int64 {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		return int64(value.Float())
+	case reflect.Bool:
+		if value.Bool() {
+			return 1
+		}
+	case reflect.String:
+		i, _ := strconv.ParseInt(strings.TrimSpace(value.String()), 10, 64)
+		return i
+	}
+
+	return 0
+}
+
+func toFloat64(v interface{}) float64 {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return value.Float()
+	case reflect.String:
+		f, _ := strconv.ParseFloat(strings.TrimSpace(value.String()), 64)
+		return f
+	}
+
+	return float64(toInt64(v))
+}
+
+func foldInt64(a interface{}, b []interface{}, f func(x, y int64) int64) int64 {
+	result := toInt64(a)
+	for _, v := range b {
+		result = f(result, toInt64(v))
+	}
+
+	return result
+}
+
+func divInt64(a, b interface{}) (int64, error) {
+	if toInt64(b) == 0 {
+		return 0, fmt.Errorf("div by zero")
+	}
+
+	return toInt64(a) / toInt64(b), nil
+}
+
+func modInt64(a, b interface{}) (int64, error) {
+	if toInt64(b) == 0 {
+		return 0, fmt.Errorf("mod by zero")
+	}
+
+	return toInt64(a) % toInt64(b), nil
+}
+
+func addInt64(x, y int64) int64 {
+	return x + y
+}
+
+func mulInt64(x, y int64) int64 {
+	return x * y
+}
+
+func maxInt64(x, y int64) int64 {
+	if y > x {
+		return y
+	}
+	return x
+}
+
+func minInt64(x, y int64) int64 {
+	if y < x {
+		return y
+	}
+	return x
+}
+
+func round(f float64, places int) float64 {
+	pow := math.Pow(10, float64(places))
+	return math.Round(f*pow) / pow
+}
+
+// dict makes a map of the key and value pairs
+func dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("dict takes key and value pairs")
+	}
+
+	d := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		d[toString(pairs[i])] = pairs[i+1]
+	}
+
+	return d, nil
+}
+
+// keys returns the sorted keys of the maps
+func keys(dicts ...map[string]interface{}) []string {
+	var result []string
+	for _, d := range dicts {
+		for key := range d {
+			result = append(result, key)
+		}
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+func first(list interface{}) interface{} {
+	value := reflect.ValueOf(list)
+	if (value.Kind() != reflect.Slice && value.Kind() != reflect.Array) || value.Len() == 0 {
+		return nil
+	}
+
+	return value.Index(0).Interface()
+}
+
+func last(list interface{}) interface{} {
+	value := reflect.ValueOf(list)
+	if (value.Kind() != reflect.Slice && value.Kind() != reflect.Array) || value.Len() == 0 {
+		return nil
+	}
+
+	return value.Index(value.Len() - 1).Interface()
+}
+
+// has tells whether the list holds needle
+func has(needle, list interface{}) bool {
+	value := reflect.ValueOf(list)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return false
+	}
+
+	for i := 0; i < value.Len(); i++ {
+		if reflect.DeepEqual(value.Index(i).Interface(), needle) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// until returns the integers from 0 to n excluded
+func until(n int) []int {
+	if n < 0 {
+		n = 0
+	}
+
+	result := make([]int, n)
+	for i := range result {
+		result[i] = i
+	}
+
+	return result
+}
+
+// empty tells whether v is nil or the zero value of its type, an empty slice or map included
+func empty(v interface{}) bool {
+	value := reflect.ValueOf(v)
+	if !value.IsValid() {
+		return true
+	}
+
+	switch value.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String, reflect.Chan:
+		return value.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	}
+
+	return value.IsZero()
+}
+
+// defaultValue returns v, or d when v is empty: {{.Name | default "anonymous"}}
+func defaultValue(d interface{}, v ...interface{}) interface{} {
+	if len(v) == 0 || empty(v[0]) {
+		return d
+	}
+
+	return v[0]
+}
+
+// coalesce returns the first of values which is not empty
+func coalesce(values ...interface{}) interface{} {
+	for _, v := range values {
+		if !empty(v) {
+			return v
+		}
+	}
+
+	return nil
+}
+
+func toTime(v interface{}) time.Time {
+	switch t := v.(type) {
+	case time.Time:
+		return t
+	case *time.Time:
+		if t != nil {
+			return *t
+		}
+	case int, int32, int64, uint, uint32, uint64:
+		return time.Unix(toInt64(t), 0)
+	}
+
+	return time.Time{}
+}
+
+func dateInZone(layout string, t interface{}, zone string) (string, error) {
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return "", err
+	}
+
+	return toTime(t).In(location).Format(layout), nil
+}
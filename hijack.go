@@ -0,0 +1,62 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+// HijackProtection is the mode of Options.JSONHijackProtection, the prefix making the JSON bodies whose top level is
+// an array invalid JavaScript, so that a page of another origin loading them with a <script> tag can't read them.
+// Objects are not prefixed, a top level { being a block to JavaScript and not an expression.
+//
+// The clients strip the prefix before parsing the body. AngularJS's $http does it for HijackAngular, and others
+// remove everything up to the first newline, or the while(1); of HijackWhile:
+//
+//	const text = await (await fetch(url)).text()
+//	const data = JSON.parse(text.replace(/^\)\]\}',?\n/, "").replace(/^while\(1\);/, ""))
+type HijackProtection string
+
+// Modes of Options.JSONHijackProtection
+const (
+	// The JSON bodies are not prefixed
+	HijackNone HijackProtection = ""
+	// The array bodies are prefixed with )]}',\n, which AngularJS strips
+	HijackAngular HijackProtection = "angular"
+	// The array bodies are prefixed with while(1);, as Google's APIs do
+	HijackWhile HijackProtection = "while"
+)
+
+// hijackPrefix returns the prefix of the JSON body b for Options.JSONHijackProtection, nil when b is not an array
+func hijackPrefix(b []byte) []byte {
+	mode := render.options.JSONHijackProtection
+	if mode == HijackNone || !isJSONArray(b) {
+		return nil
+	}
+
+	switch mode {
+	case HijackWhile:
+		return []byte("while(1);")
+	default:
+		return []byte(")]}',\n")
+	}
+}
+
+// isJSONArray tells whether the top level of the JSON body b is an array
+func isJSONArray(b []byte) bool {
+	for _, c := range b {
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return c == '['
+	}
+
+	return false
+}
@@ -0,0 +1,100 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"text/template/parse"
+)
+
+// TemplateHook is called with the name and the parse tree of each template parsed from the template files, those of
+// the define actions included. An error fails the file like a parse error.
+type TemplateHook func(name string, tree *parse.Tree) error
+
+var templateHooks []TemplateHook
+
+// OnTemplateParsed registers a hook called whenever the templates are loaded, for plugins walking the parse trees
+// such as a linter of the template style or a collector of the translatable strings. Hooks are meant to be
+// registered at startup, before Init.
+//
+//	render.OnTemplateParsed(func(name string, tree *parse.Tree) error {
+//		if strings.Contains(tree.Root.String(), "<script>") {
+//			return fmt.Errorf("%s: inline script", name)
+//		}
+//		return nil
+//	})
+func OnTemplateParsed(hook TemplateHook) {
+	templateHooks = append(templateHooks, hook)
+}
+
+// runTemplateHooks calls the hooks with the trees parsed from a template file
+func runTemplateHooks(trees []*parse.Tree) error {
+	for _, tree := range trees {
+		for _, hook := range templateHooks {
+			if err := hook(tree.Name, tree); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// walkNodes calls visit with node and all the nodes under it
+func walkNodes(node parse.Node, visit func(node parse.Node)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		visit(n)
+		for _, child := range n.Nodes {
+			walkNodes(child, visit)
+		}
+		return
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		visit(n)
+		for _, cmd := range n.Cmds {
+			walkNodes(cmd, visit)
+		}
+		return
+	}
+
+	visit(node)
+	switch n := node.(type) {
+	case *parse.ActionNode:
+		walkNodes(n.Pipe, visit)
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walkNodes(arg, visit)
+		}
+	case *parse.ChainNode:
+		walkNodes(n.Node, visit)
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, visit)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, visit)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, visit)
+	case *parse.TemplateNode:
+		walkNodes(n.Pipe, visit)
+	}
+}
+
+func walkBranch(n *parse.BranchNode, visit func(node parse.Node)) {
+	walkNodes(n.Pipe, visit)
+	walkNodes(n.List, visit)
+	walkNodes(n.ElseList, visit)
+}
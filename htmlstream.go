@@ -0,0 +1,204 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+)
+
+// HTMLStream executes the template name straight into the response instead of buffering it first, so that the
+// browser starts receiving a large page early. The flush template func sends what has been rendered so far:
+//
+//	<head>...</head>{{ flush }}<body>...
+//
+// Together with the chunks template func, a long list is rendered in bounded memory:
+//
+//	{{range chunks .Items 100}}{{range .}}<li>{{.Name}}</li>{{end}}{{ flush }}{{end}}
+//
+// The page is executed when the layout yields it, so only the sections it names with content_for after that can be
+// yielded. The layouts, the template extended, the templates of HTMLOptions.Tenant and of Options.Engines are the
+// ones HTML renders. The status and headers are written along with the first output. An error before it is answered
+// like HTML does, an error after it can only be logged and cuts the response short.
+func HTMLStream(w http.ResponseWriter, status int, name string, binding interface{}, htmlOptions ...HTMLOptions) {
+	w, done := observeRender(w, prepareHTMLOptions(htmlOptions).Request, FormatHTML, name)
+	defer done(nil)
+
+	option := prepareHTMLOptions(htmlOptions)
+	if err := refresh(); err != nil {
+		renderError(w, option.Request, err)
+		return
+	}
+	if err := checkGuards(option.Request, name); err != nil {
+		if err = renderForbidden(w, name, option); err != nil {
+			renderError(w, option.Request, err)
+		}
+		return
+	}
+	name = selectVariant(w, option.Request, name)
+	if err := checkBinding(name, binding); err != nil {
+		renderError(w, option.Request, err)
+		return
+	}
+
+	stream, err := startStream()
+	if err != nil {
+		renderError(w, option.Request, err)
+		return
+	}
+	defer endStream(stream)
+
+	release, err := acquireRender(option.Request)
+	if err != nil {
+		renderError(w, option.Request, err)
+		return
+	}
+	defer release()
+
+	// the stream reads the templates of a single load, whatever reloads meanwhile
+	set := loaded()
+	locale := prepareLocale(w, option)
+	option.Request = withContextData(clearFlash(flashWriter(w, option), option.Request))
+	binding = mergeData(option.Request, binding)
+	name = localizeTemplate(name, locale)
+
+	// the layouts, the template extended, the tenant and the engine are the ones HTML would render
+	engine, ok := set.engines[name]
+	isEngine := ok && !definedTemplate(name)
+	var t *template.Template
+	var releaseTemplate func()
+	var layouts []string
+	page := name
+	if len(option.Extends) > 0 && !isEngine {
+		page = localizeTemplate(option.Extends, locale)
+		t, releaseTemplate, err = inheritedTemplate(set, page, name, option, locale)
+	} else {
+		t, releaseTemplate, err = scopedTemplate(set, option, locale)
+		layouts = layoutChain(option, locale)
+	}
+	if err != nil {
+		renderError(w, option.Request, err)
+		return
+	}
+	defer releaseTemplate()
+
+	setHeader(w, option.Header)
+	sw := &streamWriter{
+		w:           w,
+		out:         streamOutput{writeDeadline(w, option.WriteTimeout), stream},
+		status:      status,
+		contentType: callContentType(render.options.HTMLContentType, option.ContentType, option.Charset),
+	}
+	out := limitWriter(sw)
+
+	executePage := func() error {
+		if isEngine {
+			return engine.templates.Execute(out, engine.path, binding)
+		}
+		return t.ExecuteTemplate(out, page, binding)
+	}
+	execute := executePage
+	// the inner layouts and the page are written in place of yield
+	var names []string
+	data := layoutData(option, binding)
+	if len(layouts) > 0 {
+		names = append(layouts[1:len(layouts):len(layouts)], name)
+		execute = func() error {
+			return t.ExecuteTemplate(out, layouts[0], data)
+		}
+	}
+	depth := -1
+	sections := map[string]string{}
+	funcs := template.FuncMap{
+		"yield": func(args ...interface{}) (string, error) {
+			section, sectionData, err := yieldArgs(args, binding)
+			if err != nil {
+				return "", err
+			}
+			if len(section) > 0 {
+				if name, ok := sections[section]; ok {
+					return "", t.ExecuteTemplate(out, name, sectionData)
+				}
+				return "", nil
+			}
+
+			depth++
+			defer func() {
+				depth--
+			}()
+			if depth >= len(names) {
+				return "", fmt.Errorf("yield called with no layout defined")
+			}
+			// the inner layouts are executed with the layout data too
+			if depth < len(names)-1 {
+				return "", t.ExecuteTemplate(out, names[depth], data)
+			}
+			return "", executePage()
+		},
+		"content_for": func(section, name string) (string, error) {
+			sections[section] = name
+			return "", nil
+		},
+		"current": func() (string, error) {
+			return page, nil
+		},
+		"flush": func() (string, error) {
+			sw.Flush()
+			return "", nil
+		},
+	}
+	t.Funcs(funcs)
+
+	err = templateError(execute(), false)
+	if err != nil && !sw.wroteHeader {
+		renderError(w, option.Request, err)
+		return
+	}
+	if err != nil {
+		logError("render: HTMLStream cut short", "template", name, "err", err)
+	}
+	sw.writeHeader()
+}
+
+// streamWriter writes the status and headers of a response with its first output
+type streamWriter struct {
+	w           http.ResponseWriter
+	out         io.Writer
+	status      int
+	contentType string
+	wroteHeader bool
+}
+
+func (s *streamWriter) writeHeader() {
+	if s.wroteHeader {
+		return
+	}
+	s.wroteHeader = true
+
+	s.w.Header().Set(ContentType, s.contentType)
+	s.w.WriteHeader(s.status)
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	s.writeHeader()
+
+	return s.out.Write(p)
+}
+
+// Flush sends the output written so far to the client
+func (s *streamWriter) Flush() {
+	s.writeHeader()
+	flushResponse(s.w)
+}
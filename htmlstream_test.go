@@ -0,0 +1,84 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// upperEngine is an Engine writing its sources in upper case, followed by the binding
+type upperEngine struct{}
+
+func (upperEngine) Name() string         { return "upper" }
+func (upperEngine) Extensions() []string { return []string{".upper"} }
+
+func (upperEngine) Parse(sources map[string]string) (EngineTemplates, error) {
+	return upperTemplates(sources), nil
+}
+
+type upperTemplates map[string]string
+
+func (t upperTemplates) Execute(w io.Writer, path string, binding interface{}) error {
+	_, err := fmt.Fprintf(w, "%s %v", strings.ToUpper(t[path]), binding)
+	return err
+}
+
+func TestHTMLStreamRendersLikeHTML(t *testing.T) {
+	tenants := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tenants, "acme"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tenants, "acme", "page.tmpl"), []byte(`acme {{.}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	initTemplates(t, map[string]string{
+		"layout.tmpl":  `<main>{{yield}}</main>{{yield "sidebar"}}`,
+		"base.tmpl":    `<body>{{block "content" .}}{{end}}</body>{{yield "sidebar"}}`,
+		"side.tmpl":    `side {{.}}`,
+		"page.tmpl":    `{{content_for "sidebar" "side"}}page {{.}}`,
+		"child.tmpl":   `{{define "content"}}{{content_for "sidebar" "side"}}child {{.}}{{end}}`,
+		"engine.upper": `engine`,
+	}, Options{Layout: "layout", TenantDirectory: tenants, Engines: []Engine{upperEngine{}}})
+
+	for _, c := range []struct {
+		name   string
+		option HTMLOptions
+		want   string
+	}{
+		{"page", HTMLOptions{}, `<main>page 1</main>side 1`},
+		{"page", HTMLOptions{NoLayout: true}, `page 1`},
+		{"child", HTMLOptions{Extends: "base"}, `<body>child 1</body>side 1`},
+		{"page", HTMLOptions{Tenant: "acme"}, `<main>acme 1</main>`},
+		{"engine", HTMLOptions{}, `<main>ENGINE 1</main>`},
+		{"engine", HTMLOptions{NoLayout: true}, `ENGINE 1`},
+	} {
+		html := httptest.NewRecorder()
+		HTML(html, 200, c.name, 1, c.option)
+		stream := httptest.NewRecorder()
+		HTMLStream(stream, 200, c.name, 1, c.option)
+
+		if html.Body.String() != c.want {
+			t.Errorf("HTML %s %+v: got %q, want %q", c.name, c.option, html.Body.String(), c.want)
+		}
+		if stream.Code != 200 || stream.Body.String() != c.want {
+			t.Errorf("HTMLStream %s %+v: got %d %q, want %q", c.name, c.option, stream.Code, stream.Body.String(), c.want)
+		}
+	}
+}
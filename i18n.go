@@ -0,0 +1,237 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ronzxy/go-render/negotiate"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Catalog holds the translations of the messages of a locale, by message ID
+type Catalog map[string]Translation
+
+// Translation is the translation of a message, its plural forms for the messages of tn. It unmarshals from a JSON
+// string or array of strings:
+//
+//	{"Sign in": "Anmelden", "%d file": ["%d Datei", "%d Dateien"]}
+type Translation []string
+
+func (t *Translation) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*t = Translation{s}
+		return nil
+	}
+
+	var forms []string
+	if err := json.Unmarshal(b, &forms); err != nil {
+		return fmt.Errorf("render: translation must be a string or an array of strings")
+	}
+	*t = forms
+
+	return nil
+}
+
+type localeContextKey struct{}
+
+// WithLocale returns a copy of ctx carrying the locale renders of requests with that context use, when
+// HTMLOptions.Locale is not set. It takes precedence over the Accept-Language header.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeContextKey{}, locale)
+}
+
+// loadCatalogs reads the catalogs of Options.MessagesDirectory, one JSON file per locale, and merges
+// Options.Catalogs over them
+func loadCatalogs() (map[string]Catalog, ParseErrors) {
+	catalogs := map[string]Catalog{}
+	var parseErrors ParseErrors
+
+	if dir := render.options.MessagesDirectory; len(dir) > 0 {
+		files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			parseErrors = append(parseErrors, &ParseError{File: dir, Message: err.Error()})
+		}
+		for _, file := range files {
+			locale := strings.TrimSuffix(filepath.Base(file), ".json")
+
+			buf, err := ioutil.ReadFile(file)
+			if err == nil {
+				catalog := Catalog{}
+				if err = json.Unmarshal(buf, &catalog); err == nil {
+					catalogs[locale] = catalog
+				}
+			}
+			if err != nil {
+				parseErrors = append(parseErrors, &ParseError{File: file, Message: err.Error()})
+			}
+		}
+	}
+
+	for locale, catalog := range render.options.Catalogs {
+		merged := Catalog{}
+		for id, translation := range catalogs[locale] {
+			merged[id] = translation
+		}
+		for id, translation := range catalog {
+			merged[id] = translation
+		}
+		catalogs[locale] = merged
+	}
+
+	return catalogs, parseErrors
+}
+
+// negotiableLocales returns Options.Locales followed by the other locales of the catalogs
+func negotiableLocales(catalogs map[string]Catalog) []string {
+	locales := append([]string{}, render.options.Locales...)
+
+	var others []string
+	for locale := range catalogs {
+		if !containsString(locales, locale) {
+			others = append(others, locale)
+		}
+	}
+	sort.Strings(others)
+
+	return append(locales, others...)
+}
+
+// translationFactories makes the t and tn template funcs of a render, in the locale of the render:
+//
+//	{{t "Sign in"}} {{t "Hello %s" .Name}} {{tn "%d file" "%d files" .Count}}
+var translationFactories = map[string]FuncFactory{
+	"t": func(scope *Scope) interface{} {
+		return func(id string, args ...interface{}) string {
+			return Translate(scope.Locale, id, args...)
+		}
+	},
+	"tn": func(scope *Scope) interface{} {
+		return func(singular, plural string, n interface{}, args ...interface{}) string {
+			return TranslatePlural(scope.Locale, singular, plural, toInt64(n), args...)
+		}
+	},
+}
+
+// lookupTranslation returns the translation of id in the catalog of locale, or of its primary language
+func lookupTranslation(locale, id string) Translation {
+	catalogs := loaded().catalogs
+	if translation, ok := catalogs[locale][id]; ok && len(translation) > 0 {
+		return translation
+	}
+	if translation, ok := catalogs[negotiate.PrimaryLanguage(locale)][id]; ok && len(translation) > 0 {
+		return translation
+	}
+
+	return nil
+}
+
+// Translate returns the translation of the message id in the locale, formatted with args by fmt.Sprintf when there
+// are some. A message without translation is id itself.
+func Translate(locale, id string, args ...interface{}) string {
+	message := id
+	if translation := lookupTranslation(locale, id); translation != nil {
+		message = translation[0]
+	}
+
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+
+	return message
+}
+
+// TranslatePlural returns the plural form of the message for n in the locale, formatted with args, or with n when
+// there are no args. A message without translation is singular when n is 1 and plural otherwise.
+func TranslatePlural(locale, singular, plural string, n int64, args ...interface{}) string {
+	message := plural
+	if n == 1 {
+		message = singular
+	}
+	if translation := lookupTranslation(locale, singular); translation != nil {
+		form := pluralForm(locale, n)
+		if form >= len(translation) {
+			form = len(translation) - 1
+		}
+		message = translation[form]
+	}
+
+	switch {
+	case len(args) > 0:
+		return fmt.Sprintf(message, args...)
+	case strings.Contains(message, "%"):
+		return fmt.Sprintf(message, n)
+	}
+
+	return message
+}
+
+// pluralForm returns the index of the plural form of n in the locale, after the CLDR rules of the common languages
+func pluralForm(locale string, n int64) int {
+	if n < 0 {
+		n = -n
+	}
+
+	switch strings.ToLower(negotiate.PrimaryLanguage(locale)) {
+	case "ja", "ko", "ms", "id", "th", "vi", "zh":
+		return 0
+	case "fr", "pt":
+		if n <= 1 {
+			return 0
+		}
+		return 1
+	case "be", "bs", "hr", "ru", "sr", "uk":
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return 0
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return 1
+		}
+		return 2
+	case "pl":
+		switch {
+		case n == 1:
+			return 0
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return 1
+		}
+		return 2
+	case "cs", "sk":
+		switch {
+		case n == 1:
+			return 0
+		case n >= 2 && n <= 4:
+			return 1
+		}
+		return 2
+	}
+
+	if n == 1 {
+		return 0
+	}
+	return 1
+}
+
+// JSONLocalized writes the error document {"status": status, "message": message} with the message id translated to
+// the locale negotiated for r
+func JSONLocalized(w http.ResponseWriter, r *http.Request, status int, id string, args ...interface{}) {
+	locale := prepareLocale(w, HTMLOptions{Request: r})
+
+	JSON(w, status, errorDocument{Status: status, Message: Translate(locale, id, args...)}, JSONOptions{Request: r})
+}
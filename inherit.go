@@ -0,0 +1,84 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"sync"
+)
+
+// inheritedSet is the template set of a page extending a parent
+type inheritedSet struct {
+	// Set of the load it was built from
+	from *templateSet
+	// Clones of the set the renders execute
+	clones *templatePool
+}
+
+// Template sets by parent and page, dropped when the templates are loaded again
+var (
+	inheritedSets   = map[[2]string]*inheritedSet{}
+	inheritedSetsMu sync.RWMutex
+)
+
+func resetInheritedSets() {
+	inheritedSetsMu.Lock()
+	inheritedSets = map[[2]string]*inheritedSet{}
+	inheritedSetsMu.Unlock()
+}
+
+// inheritedTemplate returns the templates rendering page within parent: parent and page are parsed again from their
+// files, so that the define actions of page override the block defaults of parent, and only for this page. The set
+// is built once per parent and page of the templates of a load, the renders execute its clones. The returned func
+// gives the clone back.
+func inheritedTemplate(templates *templateSet, parent, page string, option HTMLOptions, locale string) (*template.Template, func(), error) {
+	key := [2]string{parent, page}
+
+	inheritedSetsMu.RLock()
+	set := inheritedSets[key]
+	inheritedSetsMu.RUnlock()
+	// a render which started before a reload may have built it from the templates loaded before
+	if set != nil && set.from != templates {
+		set = nil
+	}
+
+	detail := "hit"
+	if set == nil {
+		detail = "miss"
+	}
+	traceEvent(option.Request, TraceEvent{Kind: TraceCache, Name: "inherited", Detail: detail})
+
+	if set == nil {
+		pristine, err := templates.pristine.Clone()
+		if err != nil {
+			return nil, nil, err
+		}
+		// parent first, for its block defaults to replace the defines of the pages parsed after it
+		for _, name := range []string{parent, page} {
+			source, ok := templates.sources[name]
+			if !ok {
+				return nil, nil, fmt.Errorf("render: template %q is not defined", name)
+			}
+			if _, err = parseHTMLTemplate(pristine, name, source); err != nil {
+				return nil, nil, err
+			}
+		}
+		set = &inheritedSet{from: templates, clones: newTemplatePool(pristine)}
+		inheritedSetsMu.Lock()
+		inheritedSets[key] = set
+		inheritedSetsMu.Unlock()
+	}
+
+	return renderTemplate(set.clones, option, locale)
+}
@@ -0,0 +1,123 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+// Package jet renders Jet templates (github.com/CloudyKit/jet) with render.HTML, for the pages where html/template
+// is too slow. The .jet files are loaded along with the other template files and rendered within the layouts:
+//
+//	render.Init(render.Options{Layout: "layout", Engines: []render.Engine{jet.New()}})
+//
+//	// templates/users/show.jet
+//	render.HTML(w, http.StatusOK, "users/show", user)
+//
+// The templates extend and include one another by their path, such as {{ include "/users/_row.jet" }}.
+package jet
+
+import (
+	"github.com/CloudyKit/jet/v6"
+	"github.com/ronzxy/go-render"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Options of the Jet Engine
+type Options struct {
+	// Extensions of the Jet template files. Defaults to [".jet"]
+	Extensions []string
+	// Variables and funcs of all the templates
+	Globals map[string]interface{}
+	// Delimiters of the actions. Defaults to {{ and }}
+	Delimiter render.Delimiter
+}
+
+// Engine is the render.Engine of the Jet template files
+type Engine struct {
+	options Options
+}
+
+// New returns the Jet Engine to add to render.Options.Engines
+func New(options ...Options) *Engine {
+	var option Options
+	if len(options) > 0 {
+		option = options[0]
+	}
+	if len(option.Extensions) == 0 {
+		option.Extensions = []string{".jet"}
+	}
+
+	return &Engine{options: option}
+}
+
+func (e *Engine) Name() string {
+	return "jet"
+}
+
+func (e *Engine) Extensions() []string {
+	return e.options.Extensions
+}
+
+// Parse parses all the templates, so that their errors fail render.Init rather than the renders
+func (e *Engine) Parse(sources map[string]string) (render.EngineTemplates, error) {
+	var options []jet.Option
+	if len(e.options.Delimiter.Left) > 0 && len(e.options.Delimiter.Right) > 0 {
+		options = append(options, jet.WithDelims(e.options.Delimiter.Left, e.options.Delimiter.Right))
+	}
+	set := jet.NewSet(loader(sources), options...)
+	for name, v := range e.options.Globals {
+		set.AddGlobal(name, v)
+	}
+
+	paths := make([]string, 0, len(sources))
+	for path := range sources {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if _, err := set.GetTemplate("/" + path); err != nil {
+			return nil, err
+		}
+	}
+
+	return templates{set: set}, nil
+}
+
+type templates struct {
+	set *jet.Set
+}
+
+func (t templates) Execute(w io.Writer, path string, binding interface{}) error {
+	tmpl, err := t.set.GetTemplate("/" + path)
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(w, jet.VarMap{}, binding)
+}
+
+// loader is the jet.Loader of the sources of render, by path relative to the template directories
+type loader map[string]string
+
+func (l loader) Exists(path string) bool {
+	_, ok := l[strings.TrimPrefix(path, "/")]
+	return ok
+}
+
+func (l loader) Open(path string) (io.ReadCloser, error) {
+	source, ok := l[strings.TrimPrefix(path, "/")]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	return ioutil.NopCloser(strings.NewReader(source)), nil
+}
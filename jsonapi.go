@@ -0,0 +1,169 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"net/http"
+	"sort"
+)
+
+// JSONAPIResource is a resource object of a JSON:API document
+type JSONAPIResource struct {
+	Type string `json:"type"`
+	ID   string `json:"id,omitempty"`
+	// Attributes of the resource, usually a struct or a map
+	Attributes    interface{}                    `json:"attributes,omitempty"`
+	Relationships map[string]JSONAPIRelationship `json:"relationships,omitempty"`
+	Links         map[string]interface{}         `json:"links,omitempty"`
+	Meta          map[string]interface{}         `json:"meta,omitempty"`
+}
+
+// JSONAPIIdentifier is a resource identifier object used as relationship data
+type JSONAPIIdentifier struct {
+	Type string                 `json:"type"`
+	ID   string                 `json:"id"`
+	Meta map[string]interface{} `json:"meta,omitempty"`
+}
+
+// JSONAPIRelationship is a relationship object of a JSON:API resource
+type JSONAPIRelationship struct {
+	// Data is a JSONAPIIdentifier for to-one and a []JSONAPIIdentifier for to-many relationships. Use
+	// (*JSONAPIIdentifier)(nil) for an empty to-one relationship.
+	Data  interface{}            `json:"data,omitempty"`
+	Links map[string]interface{} `json:"links,omitempty"`
+	Meta  map[string]interface{} `json:"meta,omitempty"`
+	// Related resources to be side loaded into the included member of the document
+	Included []JSONAPIResource `json:"-"`
+}
+
+// JSONAPIOptions is a struct for specifying the top level members of a JSON:API document
+type JSONAPIOptions struct {
+	// Resources to be side loaded into the included member, in addition to those of the relationships
+	Included []JSONAPIResource
+	Links    map[string]interface{}
+	Meta     map[string]interface{}
+	// The request being answered, needed for conditional responses.
+	Request *http.Request
+	// Generate an ETag even if Options.GenerateETags is false.
+	GenerateETag bool
+	// Do not generate an ETag even if Options.GenerateETags is true.
+	NoETag bool
+	// Send the body uncompressed, even if Options.Compression is enabled.
+	NoCompress bool
+	// Headers set on the response.
+	Header http.Header
+}
+
+type jsonAPIDocument struct {
+	Data     interface{}            `json:"data"`
+	Included []JSONAPIResource      `json:"included,omitempty"`
+	Links    map[string]interface{} `json:"links,omitempty"`
+	Meta     map[string]interface{} `json:"meta,omitempty"`
+}
+
+// JSONAPI writes v as the primary data of a JSON:API document. v is a JSONAPIResource, a *JSONAPIResource, a
+// []JSONAPIResource or nil. Related resources are side loaded once into the included member, resources which are
+// already part of the primary data are left out, the relationships being walked in the order of their names.
+func JSONAPI(w http.ResponseWriter, status int, v interface{}, jsonAPIOptions ...JSONAPIOptions) {
+	if err := JSONAPIE(w, status, v, jsonAPIOptions...); err != nil {
+		renderError(w, prepareJSONAPIOptions(jsonAPIOptions).Request, err)
+	}
+}
+
+// JSONAPIE is JSONAPI returning the error instead of answering it with a 500, nothing is written then
+func JSONAPIE(w http.ResponseWriter, status int, v interface{}, jsonAPIOptions ...JSONAPIOptions) (err error) {
+	option := prepareJSONAPIOptions(jsonAPIOptions)
+	w, done := observeRender(w, option.Request, FormatJSON, "")
+	defer done(&err)
+
+	var primary []JSONAPIResource
+	switch data := v.(type) {
+	case JSONAPIResource:
+		primary = []JSONAPIResource{data}
+	case *JSONAPIResource:
+		if data != nil {
+			primary = []JSONAPIResource{*data}
+		}
+	case []JSONAPIResource:
+		primary = data
+	}
+
+	document := jsonAPIDocument{
+		Data:     v,
+		Included: jsonAPIIncluded(primary, option.Included),
+		Links:    option.Links,
+		Meta:     option.Meta,
+	}
+
+	// media type parameters other than ext and profile are not allowed by the specification
+	return renderJSON(w, status, ContentJSONAPI, document, jsonCall(JSONOptions{
+		Request:      option.Request,
+		GenerateETag: option.GenerateETag,
+		NoETag:       option.NoETag,
+		NoCompress:   option.NoCompress,
+		Header:       option.Header,
+	}))
+}
+
+func prepareJSONAPIOptions(jsonAPIOptions []JSONAPIOptions) JSONAPIOptions {
+	if len(jsonAPIOptions) > 0 {
+		return jsonAPIOptions[0]
+	}
+
+	return JSONAPIOptions{}
+}
+
+type jsonAPIKey struct {
+	Type string
+	ID   string
+}
+
+func jsonAPIIncluded(primary, included []JSONAPIResource) []JSONAPIResource {
+	seen := map[jsonAPIKey]bool{}
+	for _, resource := range primary {
+		seen[jsonAPIKey{Type: resource.Type, ID: resource.ID}] = true
+	}
+
+	var result []JSONAPIResource
+	var add func(resources []JSONAPIResource)
+	var addRelated func(resource JSONAPIResource)
+	add = func(resources []JSONAPIResource) {
+		for _, resource := range resources {
+			key := jsonAPIKey{Type: resource.Type, ID: resource.ID}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			result = append(result, resource)
+
+			addRelated(resource)
+		}
+	}
+	addRelated = func(resource JSONAPIResource) {
+		names := make([]string, 0, len(resource.Relationships))
+		for name := range resource.Relationships {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			add(resource.Relationships[name].Included)
+		}
+	}
+
+	for _, resource := range primary {
+		addRelated(resource)
+	}
+	add(included)
+
+	return result
+}
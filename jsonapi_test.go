@@ -0,0 +1,73 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJSONAPIIncludedOrder(t *testing.T) {
+	initTemplates(t, nil, Options{})
+
+	article := JSONAPIResource{Type: "articles", ID: "1", Relationships: map[string]JSONAPIRelationship{}}
+	for _, name := range []string{"tags", "author", "comments", "editor", "publisher"} {
+		article.Relationships[name] = JSONAPIRelationship{
+			Data:     JSONAPIIdentifier{Type: name, ID: "1"},
+			Included: []JSONAPIResource{{Type: name, ID: "1"}},
+		}
+	}
+
+	var first string
+	for i := 0; i < 20; i++ {
+		w := httptest.NewRecorder()
+		JSONAPI(w, http.StatusOK, article)
+		if i == 0 {
+			first = w.Body.String()
+		} else if w.Body.String() != first {
+			t.Fatalf("got %s, then %s", first, w.Body.String())
+		}
+	}
+
+	included := jsonAPIIncluded([]JSONAPIResource{article}, nil)
+	for i, want := range []string{"author", "comments", "editor", "publisher", "tags"} {
+		if included[i].Type != want {
+			t.Errorf("included %d: got %s, want %s", i, included[i].Type, want)
+		}
+	}
+}
+
+func TestJSONAPIEConditional(t *testing.T) {
+	initTemplates(t, nil, Options{})
+
+	article := JSONAPIResource{Type: "articles", ID: "1"}
+	w := httptest.NewRecorder()
+	if err := JSONAPIE(w, http.StatusOK, article, JSONAPIOptions{GenerateETag: true}); err != nil {
+		t.Fatal(err)
+	}
+	etag := w.Header().Get(ETag)
+	if len(etag) == 0 {
+		t.Fatal("no ETag")
+	}
+
+	r := httptest.NewRequest("GET", "/articles/1", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	if err := JSONAPIE(w, http.StatusOK, article, JSONAPIOptions{Request: r, GenerateETag: true}); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusNotModified {
+		t.Errorf("got %d, want 304", w.Code)
+	}
+}
@@ -0,0 +1,148 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+)
+
+// layoutChain returns the layouts of a render, the outermost first
+func layoutChain(option HTMLOptions, locale string) []string {
+	layouts := option.Layouts
+	if len(layouts) == 0 && len(option.Layout) > 0 {
+		layouts = []string{option.Layout}
+	}
+
+	chain := make([]string, len(layouts))
+	for i, layout := range layouts {
+		chain[i] = localizeTemplate(layout, locale)
+	}
+
+	return chain
+}
+
+// layoutData returns the data the layouts of a render are executed with
+func layoutData(option HTMLOptions, binding interface{}) interface{} {
+	if option.LayoutData != nil {
+		return mergeData(option.Request, option.LayoutData)
+	}
+
+	return binding
+}
+
+// executeLayouts executes the pages with binding one after another, then the layouts from the innermost with
+// layoutData, whose yield renders the output of the templates within it. A page names the template of a section
+// with content_for, the layouts render it with yield, the section name and optionally the data of the section,
+// which defaults to binding:
+//
+//	{{/* page */}}{{content_for "sidebar" "users/sidebar"}}
+//	{{/* layout */}}<aside>{{yield "sidebar" .Nav}}</aside><main>{{yield}}</main>
+func executeLayouts(t *template.Template, binding, layoutData interface{}, layouts []string, pages ...string) (*spoolBuffer, error) {
+	return executeLayoutsWith(t, binding, layoutData, layouts, pages[0], func(out io.Writer) error {
+		for _, page := range pages {
+			if err := t.ExecuteTemplate(out, page, binding); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// executeLayoutsWith is executeLayouts for the page content written by page, current naming the page. The output
+// spills to a file beyond Options.SpoolThreshold, the content a layout yields is read back in memory.
+func executeLayoutsWith(t *template.Template, binding, layoutData interface{}, layouts []string, current string, page func(out io.Writer) error) (*spoolBuffer, error) {
+	sections := map[string]string{}
+	var content *template.HTML
+	funcs := template.FuncMap{
+		"yield": func(args ...interface{}) (template.HTML, error) {
+			section, data, err := yieldArgs(args, binding)
+			if err != nil {
+				return "", err
+			}
+			if len(section) > 0 {
+				return executeSection(t, sections, section, data)
+			}
+			if content == nil {
+				return "", fmt.Errorf("yield called with no layout defined")
+			}
+			return *content, nil
+		},
+		"content_for": func(section, name string) (string, error) {
+			sections[section] = name
+			return "", nil
+		},
+		"current": func() (string, error) {
+			return current, nil
+		},
+	}
+	t.Funcs(funcs)
+
+	buf := newSpoolBuffer()
+	if err := page(limitWriter(buf)); err != nil {
+		return buf, templateError(err, false)
+	}
+
+	for i := len(layouts) - 1; i >= 0; i-- {
+		output, err := buf.contents()
+		if err != nil {
+			return buf, err
+		}
+		// return safe html here since we are rendering our own template
+		html := template.HTML(output)
+		content = &html
+
+		buf.Reset()
+		if err := t.ExecuteTemplate(limitWriter(buf), layouts[i], layoutData); err != nil {
+			return buf, templateError(err, false)
+		}
+	}
+
+	return buf, nil
+}
+
+// yieldArgs splits the arguments of yield into the section name, "" for the page, and the data of the section
+func yieldArgs(args []interface{}, binding interface{}) (string, interface{}, error) {
+	if len(args) == 0 {
+		return "", binding, nil
+	}
+
+	section, ok := args[0].(string)
+	if !ok || len(args) > 2 {
+		return "", nil, fmt.Errorf("yield takes a section name and the data of the section")
+	}
+	if len(args) == 2 {
+		if len(section) == 0 {
+			return "", nil, fmt.Errorf("yield can't give data to the page, which is rendered with the binding")
+		}
+		return section, args[1], nil
+	}
+
+	return section, binding, nil
+}
+
+// executeSection renders the template named for the section by content_for, nothing when there is none
+func executeSection(t *template.Template, sections map[string]string, section string, binding interface{}) (template.HTML, error) {
+	name, ok := sections[section]
+	if !ok {
+		return "", nil
+	}
+
+	buf, err := execute(t, name, binding)
+	html := template.HTML(buf.String())
+	// Set buffer in BufferPool
+	render.buffer.Set(buf)
+
+	return html, err
+}
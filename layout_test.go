@@ -0,0 +1,203 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentLayoutRenders(t *testing.T) {
+	files := map[string]string{
+		"layout.tmpl": `<aside>{{yield "sidebar"}}</aside><main>{{yield}}</main><p>{{current}}</p>`,
+	}
+	for i := 0; i < 10; i++ {
+		files[fmt.Sprintf("page%d.tmpl", i)] = fmt.Sprintf(`{{content_for "sidebar" "side%d"}}page%d {{.}}`, i, i)
+		files[fmt.Sprintf("side%d.tmpl", i)] = fmt.Sprintf(`side%d {{.}}`, i)
+	}
+	initTemplates(t, files, Options{Layout: "layout"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			page := fmt.Sprintf("page%d", i%10)
+			w := httptest.NewRecorder()
+			HTML(w, 200, page, i)
+
+			want := fmt.Sprintf(`<aside>side%d %d</aside><main>page%d %d</main><p>%s</p>`, i%10, i, i%10, i, page)
+			if w.Code != 200 || w.Body.String() != want {
+				t.Errorf("render %d: got %d %q, want %q", i, w.Code, w.Body.String(), want)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestConcurrentExtendsRenders(t *testing.T) {
+	files := map[string]string{
+		"base.tmpl":   `<main>{{block "content" .}}default{{end}}</main>{{yield "sidebar"}}`,
+		"side.tmpl":   `side {{.}}`,
+		"page0.tmpl":  `{{define "content"}}page0 {{.}}{{end}}`,
+		"page1.tmpl":  `{{define "content"}}{{content_for "sidebar" "side"}}page1 {{.}}{{end}}`,
+		"layout.tmpl": `{{yield}}`,
+	}
+	initTemplates(t, files, Options{Layout: "layout"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			w := httptest.NewRecorder()
+			HTML(w, 200, fmt.Sprintf("page%d", i%2), i, HTMLOptions{Extends: "base"})
+
+			want := fmt.Sprintf(`<main>page0 %d</main>`, i)
+			if i%2 == 1 {
+				want = fmt.Sprintf(`<main>page1 %d</main>side %d`, i, i)
+			}
+			if w.Code != 200 || w.Body.String() != want {
+				t.Errorf("render %d: got %d %q, want %q", i, w.Code, w.Body.String(), want)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestDefaultLayoutWithOptions(t *testing.T) {
+	initTemplates(t, map[string]string{
+		"layout.tmpl": `<main>{{yield}}</main>`,
+		"other.tmpl":  `<section>{{yield}}</section>`,
+		"page.tmpl":   `page`,
+	}, Options{Layout: "layout"})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	for want, call := range map[string]func(w *httptest.ResponseRecorder){
+		"<main>page</main>": func(w *httptest.ResponseRecorder) {
+			HTML(w, 200, "page", nil, HTMLOptions{Request: r, Locale: "en"})
+		},
+		// an empty HTMLOptions renders with the layout of Options too
+		"<main>page</main><main>page</main>": func(w *httptest.ResponseRecorder) {
+			HTML(w, 200, "page", nil, HTMLOptions{})
+			HTML(w, 200, "page", nil)
+		},
+		"<section>page</section>": func(w *httptest.ResponseRecorder) {
+			HTML(w, 200, "page", nil, HTMLOptions{Layout: "other"})
+		},
+		"page": func(w *httptest.ResponseRecorder) {
+			HTML(w, 200, "page", nil, HTMLOptions{Request: r, NoLayout: true})
+		},
+	} {
+		w := httptest.NewRecorder()
+		call(w)
+		if w.Body.String() != want {
+			t.Errorf("got %q, want %q", w.Body.String(), want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	Fragment(w, 200, "page", nil, HTMLOptions{Request: r})
+	if w.Body.String() != "page" {
+		t.Errorf("Fragment: got %q", w.Body.String())
+	}
+}
+
+func TestYieldAfterALayoutRender(t *testing.T) {
+	initTemplates(t, map[string]string{
+		"layout.tmpl": `<main>{{yield}}</main>`,
+		"page.tmpl":   `page {{.}}`,
+		"yield.tmpl":  `{{yield}}`,
+	}, Options{})
+
+	// the clones executed by a render are reused by the next ones, without the yield of the render
+	for i := 0; i < 10; i++ {
+		w := httptest.NewRecorder()
+		HTML(w, 200, "page", i, HTMLOptions{Layout: "layout"})
+		if want := fmt.Sprintf("<main>page %d</main>", i); w.Body.String() != want {
+			t.Fatalf("got %q, want %q", w.Body.String(), want)
+		}
+
+		if err := HTMLE(httptest.NewRecorder(), 200, "yield", nil); err == nil {
+			t.Fatal("yield without a layout rendered")
+		}
+	}
+}
+
+func BenchmarkHTMLLayout(b *testing.B) {
+	for _, n := range []int{10, 500} {
+		b.Run(fmt.Sprintf("%d templates", n), func(b *testing.B) {
+			files := map[string]string{
+				"layout.tmpl": `<html><body>{{yield "sidebar"}}<main>{{yield}}</main></body></html>`,
+			}
+			for i := 0; i < n; i++ {
+				files[fmt.Sprintf("page%d.tmpl", i)] = fmt.Sprintf(`{{content_for "sidebar" "side"}}<h1>page %d</h1>{{range .}}<p>{{.}}</p>{{end}}`, i)
+			}
+			files["side.tmpl"] = `<aside>{{len .}}</aside>`
+			initTemplates(b, files, Options{Layout: "layout"})
+			binding := []string{"a", "b", "c"}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if err := HTMLE(httptest.NewRecorder(), 200, "page0", binding); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestFragmentWithExtends(t *testing.T) {
+	initTemplates(t, map[string]string{
+		"base.tmpl":     `<main>{{block "content" .}}{{end}}</main>`,
+		"page.tmpl":     `{{define "content"}}page{{end}}`,
+		"fragment.tmpl": `fragment {{.}}`,
+	}, Options{Extends: "base"})
+
+	w := httptest.NewRecorder()
+	HTML(w, 200, "page", nil)
+	if w.Body.String() != "<main>page</main>" {
+		t.Errorf("HTML: got %q", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	Fragment(w, 200, "fragment", 1)
+	if w.Body.String() != "fragment 1" {
+		t.Errorf("Fragment: got %q", w.Body.String())
+	}
+}
+
+func TestNoLayoutWithExtends(t *testing.T) {
+	initTemplates(t, map[string]string{
+		"base.tmpl": `<main>{{block "content" .}}{{end}}</main>`,
+		"page.tmpl": `{{define "content"}}page{{end}}body`,
+	}, Options{Extends: "base"})
+
+	for want, option := range map[string]HTMLOptions{
+		"<main>page</main>": {},
+		"body":              {NoLayout: true},
+	} {
+		w := httptest.NewRecorder()
+		HTML(w, 200, "page", nil, option)
+		if w.Body.String() != want {
+			t.Errorf("%+v: got %q, want %q", option, w.Body.String(), want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ErrOverloaded is returned when no template execution slot frees up within Options.RenderQueueTimeout, it is
+// answered with 503 Service Unavailable
+var ErrOverloaded = errors.New("render: too many concurrent template executions")
+
+// Slots of concurrent template executions, nil when unlimited
+var renderSlots chan struct{}
+
+func resetRenderSlots() {
+	renderSlots = nil
+	if render.options.MaxConcurrentRenders > 0 {
+		renderSlots = make(chan struct{}, render.options.MaxConcurrentRenders)
+	}
+}
+
+// acquireRender waits for a template execution slot, up to Options.RenderQueueTimeout or the end of the request.
+// The returned func releases the slot.
+func acquireRender(r *http.Request) (func(), error) {
+	slots := renderSlots
+	if slots == nil {
+		return func() {}, nil
+	}
+
+	release := func() {
+		<-slots
+	}
+
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+	if render.options.RenderQueueTimeout <= 0 {
+		return nil, ErrOverloaded
+	}
+
+	ctx := context.Background()
+	if r != nil {
+		ctx = r.Context()
+	}
+	timer := time.NewTimer(render.options.RenderQueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, ErrOverloaded
+	case <-ctx.Done():
+		return nil, ErrOverloaded
+	}
+}
@@ -0,0 +1,119 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Loader loads the template files from a source other than Options.Directories, such as a database, S3 or an HTTP
+// server. Its files go through the same pipeline as the ones of the directories: their extension tells HTML, text
+// and Markdown templates apart, Options.PartialPrefixes names them and debug mode reloads them when their
+// modification time or size changes.
+type Loader interface {
+	// List returns the slash separated paths of the template files, such as "users/show.tmpl"
+	List() []string
+	// Load returns the source of the file at path and its modification time
+	Load(path string) ([]byte, time.Time, error)
+}
+
+// DirectoryLoader is the Loader of the template files of directories, the files of a later directory overriding
+// the ones of the same relative path, the way Options.Directories are loaded
+type DirectoryLoader struct {
+	Directories []string
+}
+
+func (l DirectoryLoader) List() []string {
+	seen := map[string]bool{}
+	var paths []string
+	for _, dir := range l.Directories {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+			relativePath, err := filepath.Rel(dir, path)
+			if err != nil {
+				return nil
+			}
+			relativePath = filepath.ToSlash(relativePath)
+			if !seen[relativePath] {
+				seen[relativePath] = true
+				paths = append(paths, relativePath)
+			}
+			return nil
+		})
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return walkLess(paths[i], paths[j])
+	})
+
+	return paths
+}
+
+func (l DirectoryLoader) Load(path string) ([]byte, time.Time, error) {
+	var err error
+	for i := len(l.Directories) - 1; i >= 0; i-- {
+		file := filepath.Join(l.Directories[i], filepath.FromSlash(path))
+		var info os.FileInfo
+		if info, err = os.Stat(file); err != nil {
+			continue
+		}
+		buf, err := ioutil.ReadFile(file)
+		return buf, info.ModTime(), err
+	}
+	if err == nil {
+		err = os.ErrNotExist
+	}
+
+	return nil, time.Time{}, err
+}
+
+// loaderFiles loads the files of loader to parse, in the order filepath.Walk would walk them
+func loaderFiles(loader Loader, textExtensions []string) []templateFile {
+	paths := loader.List()
+	sort.Slice(paths, func(i, j int) bool {
+		return walkLess(paths[i], paths[j])
+	})
+
+	var files []templateFile
+	for _, path := range paths {
+		ext := getExt(path)
+		file := templateFile{
+			path:   filepath.Join(render.options.Directory, filepath.FromSlash(path)),
+			name:   templateName(path, ext),
+			loaded: true,
+		}
+
+		switch {
+		case containsString(render.options.Extensions, ext):
+		case containsString(textExtensions, ext):
+			file.text = true
+		case containsString(render.options.MustacheExtensions, ext):
+			file.mustache = true
+		case engineIndex(ext) >= 0:
+			file.engine = true
+		default:
+			continue
+		}
+
+		buf, _, err := loader.Load(path)
+		file.source, file.err = string(buf), err
+		files = append(files, file)
+	}
+
+	return files
+}
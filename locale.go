@@ -0,0 +1,63 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"github.com/ronzxy/go-render/negotiate"
+	"net/http"
+)
+
+const ContentLanguage = "Content-Language"
+
+// prepareLocale returns the locale the templates are rendered in: HTMLOptions.Locale, the one of the request
+// context, or else the one of Options.Locales and of the catalogs the Accept-Language header of the request prefers.
+// "" stands for the default tree.
+func prepareLocale(w http.ResponseWriter, option HTMLOptions) string {
+	locale := option.Locale
+	if len(locale) == 0 && option.Request != nil {
+		locale, _ = option.Request.Context().Value(localeContextKey{}).(string)
+	}
+	if len(locale) == 0 && len(loaded().locales) > 0 && option.Request != nil {
+		addVary(w.Header(), "Accept-Language")
+		locale = negotiate.Language(option.Request.Header.Get("Accept-Language"), loaded().locales)
+	}
+	if len(locale) > 0 {
+		w.Header().Set(ContentLanguage, locale)
+	}
+
+	return locale
+}
+
+// localizeTemplate returns the name of the template of the locale tree, e.g. "de-AT/index" for "index", or of the
+// tree of its primary language such as "de/index", or name when neither tree has such a template
+func localizeTemplate(name, locale string) string {
+	return localizeName(name, locale, func(name string) bool {
+		t := loaded().template.Lookup(name)
+		return t != nil && t.Tree != nil
+	})
+}
+
+// localizeName is localizeTemplate for the templates defined tells are defined
+func localizeName(name, locale string, defined func(name string) bool) string {
+	if len(locale) == 0 || len(name) == 0 {
+		return name
+	}
+
+	for _, tree := range []string{locale, negotiate.PrimaryLanguage(locale)} {
+		if defined(tree + "/" + name) {
+			return tree + "/" + name
+		}
+	}
+
+	return name
+}
@@ -0,0 +1,80 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Logger receives the log lines of the package: a message and alternating keys and values, such as
+// "status", 500, "err", err. A *slog.Logger fits it, a zap SugaredLogger through Debugw and Errorw:
+//
+//	type zapLogger struct{ *zap.SugaredLogger }
+//
+//	func (l zapLogger) Debug(msg string, keyvals ...interface{}) { l.Debugw(msg, keyvals...) }
+//	func (l zapLogger) Error(msg string, keyvals ...interface{}) { l.Errorw(msg, keyvals...) }
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+// stdLogger writes to the standard logger, the debug lines in debug mode only
+type stdLogger struct{}
+
+func (stdLogger) Debug(msg string, keyvals ...interface{}) {
+	if render.options.DebugMode {
+		log.Print("DEBUG " + formatLog(msg, keyvals))
+	}
+}
+
+func (stdLogger) Error(msg string, keyvals ...interface{}) {
+	log.Print("ERROR " + formatLog(msg, keyvals))
+}
+
+// formatLog formats a message and its keys and values as "msg key=value ..."
+func formatLog(msg string, keyvals []interface{}) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		var value interface{} = "MISSING"
+		if i+1 < len(keyvals) {
+			value = keyvals[i+1]
+		}
+		s := fmt.Sprint(value)
+		if len(s) == 0 || strings.ContainsAny(s, " \t\r\n\"=") {
+			s = strconv.Quote(s)
+		}
+		fmt.Fprintf(&b, " %v=%s", keyvals[i], s)
+	}
+
+	return b.String()
+}
+
+func currentLogger() Logger {
+	if render.options.Logger != nil {
+		return render.options.Logger
+	}
+
+	return stdLogger{}
+}
+
+func logError(msg string, keyvals ...interface{}) {
+	currentLogger().Error(msg, keyvals...)
+}
+
+func logDebug(msg string, keyvals ...interface{}) {
+	currentLogger().Debug(msg, keyvals...)
+}
@@ -0,0 +1,110 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"sort"
+)
+
+// Engines of TemplateInfo
+const (
+	EngineHTML     = "html"
+	EngineText     = "text"
+	EngineMarkdown = "markdown"
+	EngineMustache = "mustache"
+)
+
+// TemplateInfo describes a loaded template file
+type TemplateInfo struct {
+	// Name of the template parsed from the file
+	Name string `json:"name"`
+	// Slash separated path of the file relative to Options.Directory
+	File string `json:"file"`
+	// Size of the file in bytes
+	Size int64 `json:"size"`
+	// SHA-256 of the file, such as "sha256:9f86d0..."
+	Checksum string `json:"checksum"`
+	// EngineHTML, EngineText, EngineMarkdown, EngineMustache or the Name of one of Options.Engines
+	Engine string `json:"engine"`
+}
+
+// Manifest returns the template files loaded, sorted by name then by file for sort.Search. Deployment tools can
+// compare the JSON encoding of the manifests of two environments to tell whether they run the same templates.
+func Manifest() ([]TemplateInfo, error) {
+	if loaded().template == nil {
+		return nil, errors.New("render: the templates are not loaded, call Init first")
+	}
+
+	return append([]TemplateInfo(nil), loaded().manifest...), nil
+}
+
+// templateInfo describes the parsed file
+func templateInfo(file templateFile) TemplateInfo {
+	relativePath, ok := relativeTemplatePath(file.path)
+	if !ok {
+		var err error
+		if relativePath, err = filepath.Rel(render.options.Directory, file.path); err != nil {
+			relativePath = file.path
+		}
+		relativePath = filepath.ToSlash(relativePath)
+	}
+
+	engine := EngineHTML
+	if file.engine {
+		engine = render.options.Engines[engineIndex(getExt(relativePath))].Name()
+	} else if file.mustache {
+		engine = EngineMustache
+	} else if file.text {
+		engine = EngineText
+		if containsString(render.options.MarkdownExtensions, getExt(relativePath)) {
+			engine = EngineMarkdown
+		}
+	}
+
+	sum := sha256.Sum256([]byte(file.source))
+	return TemplateInfo{
+		Name:     file.name,
+		File:     filepath.ToSlash(relativePath),
+		Size:     int64(len(file.source)),
+		Checksum: "sha256:" + hex.EncodeToString(sum[:]),
+		Engine:   engine,
+	}
+}
+
+// mergeManifest returns the manifest with the entries of files replacing the ones of the same files, sorted
+func mergeManifest(manifest, files []TemplateInfo) []TemplateInfo {
+	byFile := make(map[string]TemplateInfo, len(manifest)+len(files))
+	for _, info := range manifest {
+		byFile[info.File] = info
+	}
+	for _, info := range files {
+		byFile[info.File] = info
+	}
+
+	merged := make([]TemplateInfo, 0, len(byFile))
+	for _, info := range byFile {
+		merged = append(merged, info)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].Name != merged[j].Name {
+			return merged[i].Name < merged[j].Name
+		}
+		return merged[i].File < merged[j].File
+	})
+
+	return merged
+}
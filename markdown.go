@@ -0,0 +1,152 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+)
+
+// MarkdownConverter converts Markdown to HTML for Markdown and the markdown template func
+type MarkdownConverter interface {
+	Convert(source []byte, w io.Writer) error
+}
+
+// MarkdownFunc adapts a func to a MarkdownConverter, such as the Convert func of goldmark:
+//
+//	render.Init(render.Options{Markdown: render.MarkdownFunc(func(source []byte, w io.Writer) error {
+//		return goldmark.Convert(source, w)
+//	})})
+type MarkdownFunc func(source []byte, w io.Writer) error
+
+func (f MarkdownFunc) Convert(source []byte, w io.Writer) error {
+	return f(source, w)
+}
+
+// Template funcs converting Markdown
+var markdownFuncs = template.FuncMap{
+	// markdown converts the Markdown of untrusted content such as comments, the HTML is sanitized by the "ugc" policy
+	// of the sanitize func, which RegisterSanitizePolicy can replace
+	"markdown": func(source string) (template.HTML, error) {
+		buf := render.buffer.Get()
+		// Set buffer in BufferPool
+		defer render.buffer.Set(buf)
+
+		if err := convertMarkdown([]byte(source), buf); err != nil {
+			return "", err
+		}
+		return sanitize(buf.String(), "ugc")
+	},
+}
+
+func convertMarkdown(source []byte, w io.Writer) error {
+	if render.options.Markdown == nil {
+		return fmt.Errorf("render: no Markdown converter, Options.Markdown is not set")
+	}
+
+	return render.options.Markdown.Convert(source, w)
+}
+
+func Markdown(w http.ResponseWriter, status int, name string, binding interface{}, htmlOptions ...HTMLOptions) {
+	if err := MarkdownE(w, status, name, binding, htmlOptions...); err != nil {
+		renderError(w, prepareHTMLOptions(htmlOptions).Request, err)
+	}
+}
+
+// MarkdownE renders the Markdown template name, a text template of Options.MarkdownExtensions, converts it to HTML
+// and renders it within the layouts like HTML does. The HTML of the template is not sanitized, unlike the one of the
+// markdown func.
+func MarkdownE(w http.ResponseWriter, status int, name string, binding interface{}, htmlOptions ...HTMLOptions) (err error) {
+	w, done := observeRender(w, prepareHTMLOptions(htmlOptions).Request, FormatMarkdown, name)
+	defer done(&err)
+
+	if err := refresh(); err != nil {
+		return err
+	}
+	option := prepareHTMLOptions(htmlOptions)
+	if err := checkGuards(option.Request, name); err != nil {
+		return renderForbidden(w, name, option)
+	}
+	release, err := acquireRender(option.Request)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	locale := prepareLocale(w, option)
+	option.Request = withContextData(clearFlash(flashWriter(w, option), option.Request))
+	name = localizeName(name, locale, func(name string) bool {
+		return loaded().text.Lookup(name) != nil
+	})
+	binding = mergeData(option.Request, binding)
+
+	source, err := executeText(name, binding)
+	// Set buffer in BufferPool
+	defer render.buffer.Set(source)
+	if err != nil {
+		return err
+	}
+
+	page := render.buffer.Get()
+	// Set buffer in BufferPool
+	defer render.buffer.Set(page)
+	// the HTML of the Markdown counts in the limit of the body like the output of a page
+	if err := convertMarkdown(source.Bytes(), limitWriter(page)); err != nil {
+		return err
+	}
+
+	t, releaseTemplate, err := scopedTemplate(loaded(), option, locale)
+	if err != nil {
+		return err
+	}
+	defer releaseTemplate()
+
+	buf, err := executeLayoutsWith(t, binding, layoutData(option, binding), layoutChain(option, locale), name, func(out io.Writer) error {
+		_, err := out.Write(page.Bytes())
+		return err
+	})
+	defer buf.release()
+	if err != nil {
+		return err
+	}
+
+	call := callOptions{
+		request:    option.Request,
+		etag:       etagEnabled(option.GenerateETag, option.NoETag),
+		header:     option.Header,
+		noCompress: option.NoCompress,
+	}
+
+	return writeRendered(w, status, callContentType(render.options.HTMLContentType, option.ContentType, option.Charset), call, buf)
+}
+
+// executeText executes the text template name
+func executeText(name string, binding interface{}) (*bytes.Buffer, error) {
+	// Get buffer in BufferPool
+	buf := render.buffer.Get()
+
+	return buf, templateError(loaded().text.ExecuteTemplate(limitWriter(buf), name, binding), true)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,134 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// Message is a translatable string of the templates, the argument of a t call such as {{t "Sign in"}}, or the
+// singular and plural arguments of a tn call such as {{tn "%d file" "%d files" .Count}}
+type Message struct {
+	ID     string `json:"id"`
+	Plural string `json:"plural,omitempty"`
+	// Template positions of the calls, such as "users/index:12"
+	References []string `json:"references"`
+}
+
+// ExtractMessages returns the messages of the t and tn calls of the loaded templates, sorted by ID, as a catalog for
+// translators. The calls must give the messages as string constants.
+func ExtractMessages() []Message {
+	set := loaded()
+	if set.pristine == nil {
+		return nil
+	}
+
+	messages := map[string]*Message{}
+	extract := func(tree *parse.Tree) {
+		walkNodes(tree.Root, func(node parse.Node) {
+			cmd, ok := node.(*parse.CommandNode)
+			if !ok || len(cmd.Args) < 2 {
+				return
+			}
+			ident, ok := cmd.Args[0].(*parse.IdentifierNode)
+			if !ok || (ident.Ident != "t" && ident.Ident != "tn") {
+				return
+			}
+			id, ok := cmd.Args[1].(*parse.StringNode)
+			if !ok {
+				return
+			}
+
+			plural := ""
+			if ident.Ident == "tn" && len(cmd.Args) > 2 {
+				if s, ok := cmd.Args[2].(*parse.StringNode); ok {
+					plural = s.Text
+				}
+			}
+
+			message := messages[id.Text]
+			if message == nil {
+				message = &Message{ID: id.Text}
+				messages[id.Text] = message
+			}
+			if len(plural) > 0 {
+				message.Plural = plural
+			}
+			message.References = append(message.References, messageReference(tree, cmd))
+		})
+	}
+
+	for _, t := range set.pristine.Templates() {
+		if t.Tree != nil {
+			extract(t.Tree)
+		}
+	}
+	for _, t := range set.text.Templates() {
+		if t.Tree != nil {
+			extract(t.Tree)
+		}
+	}
+
+	result := make([]Message, 0, len(messages))
+	for _, message := range messages {
+		sort.Strings(message.References)
+		result = append(result, *message)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+
+	return result
+}
+
+// messageReference returns the template and the line of the node
+func messageReference(tree *parse.Tree, node parse.Node) string {
+	location, _ := tree.ErrorContext(node)
+	if i := strings.LastIndexByte(location, ':'); i > 0 {
+		location = location[:i]
+	}
+
+	return location
+}
+
+// WritePO writes the messages as a gettext PO template
+func WritePO(w io.Writer, messages []Message) error {
+	b := bufio.NewWriter(w)
+	for i, message := range messages {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		for _, reference := range message.References {
+			fmt.Fprintf(b, "#: %s\n", reference)
+		}
+		fmt.Fprintf(b, "msgid %s\n", poQuote(message.ID))
+		if len(message.Plural) > 0 {
+			fmt.Fprintf(b, "msgid_plural %s\nmsgstr[0] \"\"\nmsgstr[1] \"\"\n", poQuote(message.Plural))
+		} else {
+			b.WriteString("msgstr \"\"\n")
+		}
+	}
+
+	return b.Flush()
+}
+
+var poEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+func poQuote(s string) string {
+	return `"` + poEscaper.Replace(s) + `"`
+}
@@ -0,0 +1,128 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"net/http"
+	"time"
+)
+
+// Format is the format of a render, of RenderEvent and RenderTo
+type Format string
+
+// Formats of the renders
+const (
+	FormatHTML     Format = "html"
+	FormatMarkdown Format = "markdown"
+	FormatJSON     Format = "json"
+	FormatXML      Format = "xml"
+	FormatGob      Format = "gob"
+	FormatText     Format = "text"
+	FormatMustache Format = "mustache"
+)
+
+// RenderEvent describes a render for Options.Metrics
+type RenderEvent struct {
+	// FormatHTML, FormatMarkdown, FormatJSON, FormatXML, FormatGob, FormatText or FormatMustache
+	Format Format
+	// Name of the template, "" for the formats without templates. The names are joined with "," for HTMLMulti.
+	Template string
+	// Status written, or the one the error is answered with when nothing was written
+	Status int
+	// Bytes of the body written
+	Bytes int64
+	// Time from the call to the end of the response
+	Duration time.Duration
+	// Error of the render, nil when it succeeded
+	Err error
+}
+
+// Metrics receives an event for each render of HTML, HTMLMulti, HTMLStream, Markdown, JSON, RawJSON, XML, Gob and
+// Text, and of their E variants. It is called synchronously at the end of the render, such as to observe the
+// Duration of a Prometheus histogram with the Format and Status labels.
+type Metrics interface {
+	Render(event RenderEvent)
+}
+
+// MetricsFunc is an adapter to use a func as a Metrics
+type MetricsFunc func(event RenderEvent)
+
+func (f MetricsFunc) Render(event RenderEvent) {
+	f(event)
+}
+
+// metricsWriter records the status and the size of a response
+type metricsWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+	// Error answered by renderError
+	err error
+}
+
+func (m *metricsWriter) WriteHeader(status int) {
+	if m.status == 0 {
+		m.status = status
+	}
+	m.ResponseWriter.WriteHeader(status)
+}
+
+func (m *metricsWriter) Write(p []byte) (int, error) {
+	if m.status == 0 {
+		m.status = http.StatusOK
+	}
+	n, err := m.ResponseWriter.Write(p)
+	m.bytes += int64(n)
+
+	return n, err
+}
+
+// Flush sends the output written so far to the client, when the ResponseWriter can
+func (m *metricsWriter) Flush() {
+	flushResponse(m.ResponseWriter)
+}
+
+// Unwrap returns the ResponseWriter, for http.ResponseController
+func (m *metricsWriter) Unwrap() http.ResponseWriter {
+	return m.ResponseWriter
+}
+
+// observeRender returns the writer of a render of Options.Metrics and Options.Tracer, and the func to call at its
+// end with the error it returns, nil for the renders answering their errors
+func observeRender(w http.ResponseWriter, r *http.Request, format Format, template string) (http.ResponseWriter, func(err *error)) {
+	metrics := render.options.Metrics
+	if metrics == nil && render.options.Tracer == nil {
+		return w, func(*error) {}
+	}
+
+	start := time.Now()
+	span := startSpan(r, format, template)
+	m := &metricsWriter{ResponseWriter: w}
+	return m, func(errp *error) {
+		event := RenderEvent{Format: format, Template: template, Status: m.status, Bytes: m.bytes, Err: m.err}
+		if errp != nil && *errp != nil {
+			event.Err = *errp
+		}
+		if event.Status == 0 && event.Err != nil {
+			event.Status = ErrorStatus(event.Err)
+		}
+		event.Duration = time.Since(start)
+
+		if span != nil {
+			endSpan(span, event)
+		}
+		if metrics != nil {
+			metrics.Render(event)
+		}
+	}
+}
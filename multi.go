@@ -0,0 +1,142 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// HTMLMulti renders the templates names one after another into a single response. A name can be a path.Match
+// pattern such as "emails/digest/*", which selects the matching templates in lexical order. The layout renders the
+// concatenation with yield.
+func HTMLMulti(w http.ResponseWriter, status int, names []string, binding interface{}, htmlOptions ...HTMLOptions) {
+	w, done := observeRender(w, prepareHTMLOptions(htmlOptions).Request, FormatHTML, strings.Join(names, ","))
+	defer done(nil)
+
+	option := prepareHTMLOptions(htmlOptions)
+	if err := refresh(); err != nil {
+		renderError(w, option.Request, err)
+		return
+	}
+
+	names, err := resolveTemplateNames(names)
+	if err != nil {
+		renderError(w, option.Request, err)
+		return
+	}
+	if err = checkGuards(option.Request, names...); err != nil {
+		if err = renderForbidden(w, strings.Join(names, ","), option); err != nil {
+			renderError(w, option.Request, err)
+		}
+		return
+	}
+	release, err := acquireRender(option.Request)
+	if err != nil {
+		renderError(w, option.Request, err)
+		return
+	}
+	defer release()
+
+	locale := prepareLocale(w, option)
+	option.Request = withContextData(clearFlash(flashWriter(w, option), option.Request))
+	t, releaseTemplate, err := scopedTemplate(loaded(), option, locale)
+	if err != nil {
+		renderError(w, option.Request, err)
+		return
+	}
+	defer releaseTemplate()
+
+	pages := make([]string, len(names))
+	for i, name := range names {
+		if err = checkBinding(name, binding); err != nil {
+			renderError(w, option.Request, err)
+			return
+		}
+		pages[i] = localizeTemplate(name, locale)
+	}
+	binding = mergeData(option.Request, binding)
+
+	// the layouts render the concatenation
+	buf, err := executeLayouts(t, binding, layoutData(option, binding), layoutChain(option, locale), pages...)
+	defer buf.release()
+	if err != nil {
+		renderError(w, option.Request, err)
+		return
+	}
+
+	call := callOptions{
+		request:    option.Request,
+		etag:       etagEnabled(option.GenerateETag, option.NoETag),
+		header:     option.Header,
+		noCompress: option.NoCompress,
+	}
+
+	// templates rendered fine, write out the result
+	err = writeRendered(w, status, callContentType(render.options.HTMLContentType, option.ContentType, option.Charset), call, buf)
+	if err != nil {
+		renderError(w, option.Request, err)
+	}
+}
+
+// resolveTemplateNames expands the patterns of names into the matching template names
+func resolveTemplateNames(names []string) ([]string, error) {
+	var all []string
+	var result []string
+	for _, name := range names {
+		if !strings.ContainsAny(name, "*?[") {
+			result = append(result, name)
+			continue
+		}
+
+		if all == nil {
+			all = templateNames()
+		}
+
+		matched := false
+		for _, candidate := range all {
+			ok, err := path.Match(name, candidate)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				matched = true
+				result = append(result, candidate)
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("render: no template matches %q", name)
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("render: no template to render")
+	}
+
+	return result, nil
+}
+
+// templateNames returns the sorted names of the parsed HTML templates
+func templateNames() []string {
+	var names []string
+	for _, t := range loaded().template.Templates() {
+		if t.Tree != nil {
+			names = append(names, t.Name())
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,393 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Depth of the partials a Mustache template may nest, which stops recursive partials
+const mustacheMaxDepth = 64
+
+type mustacheKind int
+
+const (
+	mustacheText mustacheKind = iota
+	mustacheVariable
+	mustacheUnescaped
+	mustacheSection
+	mustacheInverted
+	mustachePartial
+)
+
+type mustacheNode struct {
+	kind mustacheKind
+	// Text, or name of the variable, section or partial
+	value string
+	// Nodes of a section
+	nodes []*mustacheNode
+}
+
+// mustacheTemplate is a parsed Mustache template, of a file of Options.MustacheExtensions
+type mustacheTemplate struct {
+	name  string
+	nodes []*mustacheNode
+}
+
+func Mustache(w http.ResponseWriter, status int, name string, binding interface{}, htmlOptions ...HTMLOptions) {
+	if err := MustacheE(w, status, name, binding, htmlOptions...); err != nil {
+		renderError(w, prepareHTMLOptions(htmlOptions).Request, err)
+	}
+}
+
+// MustacheE renders the Mustache template name, a file of Options.MustacheExtensions such as
+// "mail/receipt.mustache", within the layouts like HTML does. Mustache templates are logic-less: they have no funcs
+// and can only read the binding, so they are suited to the email and notification templates customers edit.
+// Variables are HTML escaped, the ones of {{{triple}}} or {{& ampersand}} tags are not, and a section renders for
+// each item of a list, or once for a value a Go template if would find true. Partials are the other Mustache
+// templates, lambdas are not supported.
+func MustacheE(w http.ResponseWriter, status int, name string, binding interface{}, htmlOptions ...HTMLOptions) (err error) {
+	w, done := observeRender(w, prepareHTMLOptions(htmlOptions).Request, FormatMustache, name)
+	defer done(&err)
+
+	if err := refresh(); err != nil {
+		return err
+	}
+	option := prepareHTMLOptions(htmlOptions)
+	if err := checkGuards(option.Request, name); err != nil {
+		return renderForbidden(w, name, option)
+	}
+	release, err := acquireRender(option.Request)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	locale := prepareLocale(w, option)
+	option.Request = withContextData(clearFlash(flashWriter(w, option), option.Request))
+	name = localizeName(name, locale, func(name string) bool {
+		return loaded().mustache[name] != nil
+	})
+	binding = mergeData(option.Request, binding)
+
+	page, err := executeMustache(name, binding)
+	// Set buffer in BufferPool
+	defer render.buffer.Set(page)
+	if err != nil {
+		return err
+	}
+
+	t, releaseTemplate, err := scopedTemplate(loaded(), option, locale)
+	if err != nil {
+		return err
+	}
+	defer releaseTemplate()
+
+	buf, err := executeLayoutsWith(t, binding, layoutData(option, binding), layoutChain(option, locale), name, func(out io.Writer) error {
+		_, err := out.Write(page.Bytes())
+		return err
+	})
+	defer buf.release()
+	if err != nil {
+		return err
+	}
+
+	call := callOptions{
+		request:    option.Request,
+		etag:       etagEnabled(option.GenerateETag, option.NoETag),
+		header:     option.Header,
+		noCompress: option.NoCompress,
+	}
+
+	return writeRendered(w, status, callContentType(render.options.HTMLContentType, option.ContentType, option.Charset), call, buf)
+}
+
+// MustacheString returns the output of the Mustache template name without layout, for the body of an email
+func MustacheString(name string, binding interface{}) (string, error) {
+	if err := refresh(); err != nil {
+		return "", err
+	}
+	release, err := acquireRender(nil)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	buf, err := executeMustache(name, mergeData(nil, binding))
+	// Set buffer in BufferPool
+	defer render.buffer.Set(buf)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// executeMustache executes the Mustache template name
+func executeMustache(name string, binding interface{}) (*bytes.Buffer, error) {
+	// Get buffer in BufferPool
+	buf := render.buffer.Get()
+
+	templates := loaded().mustache
+	t := templates[name]
+	if t == nil {
+		return buf, fmt.Errorf("render: no Mustache template %q", name)
+	}
+	e := &mustacheExec{w: limitWriter(buf), partials: templates}
+
+	return buf, e.walk(t, t.nodes, []interface{}{binding}, 0)
+}
+
+type mustacheExec struct {
+	w        io.Writer
+	partials map[string]*mustacheTemplate
+}
+
+// walk writes nodes of t, stack holding the values of the enclosing sections, the innermost last
+func (e *mustacheExec) walk(t *mustacheTemplate, nodes []*mustacheNode, stack []interface{}, depth int) error {
+	for _, node := range nodes {
+		var err error
+		switch node.kind {
+		case mustacheText:
+			_, err = io.WriteString(e.w, node.value)
+		case mustacheVariable, mustacheUnescaped:
+			v := mustacheLookup(stack, node.value)
+			if v == nil {
+				continue
+			}
+			s := fmt.Sprint(v)
+			if node.kind == mustacheVariable {
+				s = template.HTMLEscapeString(s)
+			}
+			_, err = io.WriteString(e.w, s)
+		case mustacheSection:
+			v := mustacheLookup(stack, node.value)
+			if list := reflect.ValueOf(v); list.Kind() == reflect.Slice || list.Kind() == reflect.Array {
+				for i := 0; i < list.Len() && err == nil; i++ {
+					err = e.walk(t, node.nodes, append(stack, list.Index(i).Interface()), depth)
+				}
+			} else if mustacheTruth(v) {
+				err = e.walk(t, node.nodes, append(stack, v), depth)
+			}
+		case mustacheInverted:
+			if !mustacheTruth(mustacheLookup(stack, node.value)) {
+				err = e.walk(t, node.nodes, stack, depth)
+			}
+		case mustachePartial:
+			partial := e.partials[node.value]
+			if partial == nil {
+				continue
+			}
+			if depth >= mustacheMaxDepth {
+				return fmt.Errorf("template: %s: partials nested deeper than %d", t.name, mustacheMaxDepth)
+			}
+			err = e.walk(partial, partial.nodes, stack, depth+1)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mustacheTruth tells whether a section renders for v, which has to be true the way a Go template if tells
+func mustacheTruth(v interface{}) bool {
+	truth, ok := template.IsTrue(v)
+	return truth && ok
+}
+
+// mustacheLookup returns the value of name, "." for the innermost value or a dotted name such as "user.name" whose
+// first part is looked up from the innermost value out, nil when it is not found
+func mustacheLookup(stack []interface{}, name string) interface{} {
+	if name == "." {
+		return stack[len(stack)-1]
+	}
+
+	parts := strings.Split(name, ".")
+	for i := len(stack) - 1; i >= 0; i-- {
+		v, ok := mustacheField(stack[i], parts[0])
+		if !ok {
+			continue
+		}
+		for _, part := range parts[1:] {
+			if v, ok = mustacheField(v, part); !ok {
+				return nil
+			}
+		}
+		return v
+	}
+
+	return nil
+}
+
+// mustacheField returns the map entry, the exported field or the result of the exported method without arguments
+// of v named name
+func mustacheField(v interface{}, name string) (interface{}, bool) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || len(name) == 0 {
+		return nil, false
+	}
+
+	if method := rv.MethodByName(name); method.IsValid() && method.Type().NumIn() == 0 && method.Type().NumOut() > 0 {
+		return method.Call(nil)[0].Interface(), true
+	}
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, false
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		value := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+		if !value.IsValid() {
+			return nil, false
+		}
+		return value.Interface(), true
+	case reflect.Struct:
+		field, ok := rv.Type().FieldByName(name)
+		if !ok || len(field.PkgPath) > 0 {
+			return nil, false
+		}
+		return rv.FieldByIndex(field.Index).Interface(), true
+	}
+
+	return nil, false
+}
+
+// parseMustache parses the Mustache source of the template name
+func parseMustache(name, source string) (*mustacheTemplate, error) {
+	p := &mustacheParser{name: name, source: source, left: "{{", right: "}}"}
+	nodes, err := p.parse("")
+	if err != nil {
+		return nil, err
+	}
+
+	return &mustacheTemplate{name: name, nodes: nodes}, nil
+}
+
+type mustacheParser struct {
+	name, source string
+	// Delimiters, set with {{=<% %>=}} tags
+	left, right string
+	pos         int
+}
+
+// parse parses the nodes up to the end of section, or of the source when section is ""
+func (p *mustacheParser) parse(section string) ([]*mustacheNode, error) {
+	var nodes []*mustacheNode
+	for {
+		i := strings.Index(p.source[p.pos:], p.left)
+		if i < 0 {
+			if len(section) > 0 {
+				return nil, p.errorf(len(p.source), "section %q is not closed", section)
+			}
+			nodes = appendMustacheText(nodes, p.source[p.pos:])
+			p.pos = len(p.source)
+			return nodes, nil
+		}
+
+		start := p.pos + i
+		tagStart := start + len(p.left)
+		right := p.right
+		if strings.HasPrefix(p.source[tagStart:], "{") {
+			right = "}" + right
+		}
+		j := strings.Index(p.source[tagStart:], right)
+		if j < 0 {
+			return nil, p.errorf(start, "tag is not closed")
+		}
+		end := tagStart + j + len(right)
+
+		tag := strings.TrimSpace(p.source[tagStart : tagStart+j])
+		var sigil byte
+		if len(tag) > 0 && strings.IndexByte("#^/!>&{=", tag[0]) >= 0 {
+			sigil, tag = tag[0], strings.TrimSpace(tag[1:])
+		}
+
+		// a section, comment, partial or delimiter tag alone on its line removes the line
+		text := p.source[p.pos:start]
+		if sigil != 0 && strings.IndexByte("#^/!>=", sigil) >= 0 {
+			lineStart := strings.LastIndexByte(p.source[:start], '\n') + 1
+			lineEnd := len(p.source)
+			if k := strings.IndexByte(p.source[end:], '\n'); k >= 0 {
+				lineEnd = end + k + 1
+			}
+			if lineStart >= p.pos && len(strings.TrimSpace(p.source[lineStart:start])) == 0 &&
+				len(strings.TrimSpace(p.source[end:lineEnd])) == 0 {
+				text, end = p.source[p.pos:lineStart], lineEnd
+			}
+		}
+		nodes = appendMustacheText(nodes, text)
+		p.pos = end
+
+		switch sigil {
+		case '!':
+		case '=':
+			delims := strings.Fields(strings.TrimSuffix(tag, "="))
+			if len(delims) != 2 {
+				return nil, p.errorf(start, "bad delimiters %q", tag)
+			}
+			p.left, p.right = delims[0], delims[1]
+		case '#', '^':
+			children, err := p.parse(tag)
+			if err != nil {
+				return nil, err
+			}
+			kind := mustacheSection
+			if sigil == '^' {
+				kind = mustacheInverted
+			}
+			nodes = append(nodes, &mustacheNode{kind: kind, value: tag, nodes: children})
+		case '/':
+			if tag != section {
+				return nil, p.errorf(start, "unexpected closing tag %q", tag)
+			}
+			return nodes, nil
+		case '>':
+			nodes = append(nodes, &mustacheNode{kind: mustachePartial, value: tag})
+		case '&', '{':
+			nodes = append(nodes, &mustacheNode{kind: mustacheUnescaped, value: tag})
+		default:
+			if len(tag) == 0 {
+				return nil, p.errorf(start, "empty tag")
+			}
+			nodes = append(nodes, &mustacheNode{kind: mustacheVariable, value: tag})
+		}
+	}
+}
+
+// errorf returns an error at the offset pos of the source, formatted as the errors of text/template are
+func (p *mustacheParser) errorf(pos int, format string, args ...interface{}) error {
+	line := 1 + strings.Count(p.source[:pos], "\n")
+	return fmt.Errorf("template: %s:%d: %s", p.name, line, fmt.Sprintf(format, args...))
+}
+
+func appendMustacheText(nodes []*mustacheNode, text string) []*mustacheNode {
+	if len(text) == 0 {
+		return nodes
+	}
+
+	return append(nodes, &mustacheNode{kind: mustacheText, value: text})
+}
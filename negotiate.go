@@ -0,0 +1,59 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"github.com/ronzxy/go-render/negotiate"
+	"net/http"
+)
+
+// Offer is a media type Negotiate can answer with, and the func rendering it
+type Offer struct {
+	// Media type, with the parameters the Accept header is matched against, such as "application/json; version=2"
+	// or `application/ld+json; profile="urn:example"`. A media range of the header with parameters only accepts the
+	// offers with the same parameter values.
+	Type   string
+	Render func(w http.ResponseWriter, r *http.Request)
+}
+
+// Negotiate renders the offer the Accept header of r prefers, the first one when the header is empty or r is nil, and
+// answers with 406 Not Acceptable when it accepts none of them:
+//
+//	render.Negotiate(w, r,
+//		render.Offer{Type: "application/json; version=2", Render: func(w http.ResponseWriter, r *http.Request) {
+//			render.JSON(w, http.StatusOK, v2, render.JSONOptions{ContentType: "application/json; version=2"})
+//		}},
+//		render.Offer{Type: "application/json", Render: func(w http.ResponseWriter, r *http.Request) {
+//			render.JSON(w, http.StatusOK, v1)
+//		}},
+//	)
+func Negotiate(w http.ResponseWriter, r *http.Request, offers ...Offer) {
+	addVary(w.Header(), "Accept")
+
+	types := make([]string, len(offers))
+	for i, offer := range offers {
+		types[i] = offer.Type
+	}
+
+	accept := ""
+	if r != nil {
+		accept = r.Header.Get("Accept")
+	}
+	i := negotiate.Offer(accept, types)
+	if i < 0 || i >= len(offers) {
+		Error(w, http.StatusNotAcceptable, nil, ErrorOptions{Request: r})
+		return
+	}
+
+	offers[i].Render(w, r)
+}
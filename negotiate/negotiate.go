@@ -0,0 +1,261 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+// Package negotiate parses the Accept, Accept-Language and Accept-Encoding headers the way render negotiates its
+// formats, locales and compression, for dispatching on them beyond the built-in formats:
+//
+//	switch negotiate.Type(r.Header.Get("Accept"), "text/html", "application/json", "text/csv") {
+//	case "text/csv":
+//		writeCSV(w, rows)
+//	...
+//	}
+//
+// Entries are ordered by q-value, a q-value of 0 refusing the value, and the most specific matching media range
+// gives the q-value of a media type: "text/html" before "text/*" before "*/*".
+package negotiate
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Spec is an entry of an Accept, Accept-Encoding or Accept-Language header
+type Spec struct {
+	// Media range, content coding or language tag, such as "text/*", "gzip" or "de-AT"
+	Value string
+	// q-value, 1 when missing
+	Q float64
+	// Media type parameters before q, by lower case name
+	Params map[string]string
+}
+
+// Parse returns the entries of the header, by descending q-value and in header order for equal q-values
+func Parse(header string) []Spec {
+	var specs []Spec
+	for _, part := range splitQuoted(header, ',') {
+		value, params, q := ParseMediaType(part)
+		if len(value) == 0 {
+			continue
+		}
+
+		specs = append(specs, Spec{Value: value, Q: q, Params: params})
+	}
+
+	sort.SliceStable(specs, func(i, j int) bool {
+		return specs[i].Q > specs[j].Q
+	})
+
+	return specs
+}
+
+// ParseMediaType splits a media range such as `application/json; profile="urn:x"; q=0.5` into its value, its
+// parameters and its q-value, 1 when missing. The accept-ext parameters after q are dropped.
+func ParseMediaType(s string) (string, map[string]string, float64) {
+	fields := splitQuoted(s, ';')
+	value := strings.TrimSpace(fields[0])
+
+	var params map[string]string
+	q := 1.0
+	for _, param := range fields[1:] {
+		name, v := param, ""
+		if i := strings.IndexByte(param, '='); i >= 0 {
+			name, v = param[:i], strings.TrimSpace(param[i+1:])
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if len(name) == 0 {
+			continue
+		}
+
+		if name == "q" {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+			break
+		}
+
+		if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+			v = strings.Replace(v[1:len(v)-1], `\"`, `"`, -1)
+		}
+		if params == nil {
+			params = map[string]string{}
+		}
+		params[name] = v
+	}
+
+	return value, params, q
+}
+
+// splitQuoted splits s around sep, except within quoted strings
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+// Type returns the offered media type the Accept header prefers, the first offer when the header is empty or
+// accepts none of them
+func Type(header string, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+
+	if i := Offer(header, offers); i >= 0 {
+		return offers[i]
+	}
+
+	return offers[0]
+}
+
+// Offer returns the index of the offered media type the Accept header prefers, 0 when the header is empty and -1
+// when it accepts none of them. Offers may have parameters, such as "application/json; version=2".
+func Offer(header string, offers []string) int {
+	specs := Parse(header)
+	if len(specs) == 0 {
+		if len(offers) == 0 {
+			return -1
+		}
+		return 0
+	}
+
+	best, bestQ := -1, 0.0
+	for i, offer := range offers {
+		mediaType, params, _ := ParseMediaType(offer)
+
+		// the most specific matching range gives the q-value of the offer
+		q, specificity := 0.0, -1
+		for _, spec := range specs {
+			if s := mediaRangeSpecificity(spec, mediaType, params); s > specificity {
+				q, specificity = spec.Q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+
+	return best
+}
+
+// mediaRangeSpecificity tells how the media range of spec matches the media type with params: -1 for no match,
+// otherwise the specificity of the type plus the number of parameters of the range, which all have to be equal to
+// the ones of the media type
+func mediaRangeSpecificity(spec Spec, mediaType string, params map[string]string) int {
+	s := Match(spec.Value, mediaType)
+	if s < 0 {
+		return -1
+	}
+
+	for name, value := range spec.Params {
+		if v, ok := params[name]; !ok || v != value {
+			return -1
+		}
+	}
+
+	return s + len(spec.Params)
+}
+
+// Match tells how a media range such as "*/*", "text/*" or "text/html" matches the media type: -1 for no match, 0
+// for */*, 1 for type/* and 2 for an exact match
+func Match(mediaRange, mediaType string) int {
+	mediaRange = strings.ToLower(strings.TrimSpace(mediaRange))
+	mediaType = strings.ToLower(mediaType)
+
+	switch {
+	case mediaRange == "*/*" || mediaRange == "*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*"):
+		if strings.HasPrefix(mediaType, mediaRange[:len(mediaRange)-1]) {
+			return 1
+		}
+	case mediaRange == mediaType:
+		return 2
+	}
+
+	return -1
+}
+
+// Quality returns the q-value the Accept-Encoding or Accept-Language entries give to value, the one of the "*"
+// entry when none names it, 0 when there is neither
+func Quality(specs []Spec, value string) float64 {
+	wildcard := 0.0
+	for _, spec := range specs {
+		if strings.EqualFold(spec.Value, value) {
+			return spec.Q
+		}
+		if spec.Value == "*" {
+			wildcard = spec.Q
+		}
+	}
+
+	return wildcard
+}
+
+// Encoding returns the offered content coding the Accept-Encoding header prefers, the first one for equal
+// q-values, "" when it accepts none of them
+func Encoding(header string, codings ...string) string {
+	specs := Parse(header)
+	best, bestQ := "", 0.0
+	for _, coding := range codings {
+		if q := Quality(specs, coding); q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+
+	return best
+}
+
+// Language returns the language tag the Accept-Language header prefers, matched exactly or by primary language,
+// "" when it accepts none of them
+func Language(header string, tags []string) string {
+	for _, spec := range Parse(header) {
+		if spec.Q <= 0 || spec.Value == "*" {
+			continue
+		}
+
+		for _, tag := range tags {
+			if strings.EqualFold(spec.Value, tag) {
+				return tag
+			}
+		}
+		// "zh" matches "zh-CN", and "zh-TW" matches "zh"
+		for _, tag := range tags {
+			if strings.EqualFold(PrimaryLanguage(spec.Value), PrimaryLanguage(tag)) {
+				return tag
+			}
+		}
+	}
+
+	return ""
+}
+
+// PrimaryLanguage returns the primary language subtag of the language tag, such as "zh" for "zh-CN" or "zh_CN"
+func PrimaryLanguage(tag string) string {
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		return tag[:i]
+	}
+
+	return tag
+}
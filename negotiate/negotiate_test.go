@@ -0,0 +1,38 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+package negotiate
+
+import (
+	"testing"
+)
+
+func TestLanguage(t *testing.T) {
+	for _, c := range []struct {
+		header string
+		want   string
+	}{
+		{"de-CH, de;q=0.8", "de"},
+		{"zh-TW", "zh_CN"},
+		{"fr;q=0, en", "en-US"},
+		{"ja", ""},
+	} {
+		if got := Language(c.header, []string{"en-US", "de", "zh_CN", "fr"}); got != c.want {
+			t.Errorf("%q: got %q, want %q", c.header, got, c.want)
+		}
+	}
+
+	for tag, want := range map[string]string{"zh-CN": "zh", "zh_CN": "zh", "en": "en", "": ""} {
+		if got := PrimaryLanguage(tag); got != want {
+			t.Errorf("PrimaryLanguage(%q): got %q, want %q", tag, got, want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+package render
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateWithoutRequest(t *testing.T) {
+	initTemplates(t, nil, Options{})
+
+	offers := []Offer{
+		{Type: ContentJSON, Render: func(w http.ResponseWriter, r *http.Request) {
+			JSON(w, http.StatusOK, "json")
+		}},
+		{Type: ContentXML, Render: func(w http.ResponseWriter, r *http.Request) {
+			XML(w, http.StatusOK, "xml")
+		}},
+	}
+
+	w := httptest.NewRecorder()
+	Negotiate(w, nil, offers...)
+	if w.Code != http.StatusOK || w.Body.String() != `"json"` {
+		t.Errorf("got %d %q, want the first offer", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	Negotiate(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("no offers: got %d, want 406", w.Code)
+	}
+}
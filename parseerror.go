@@ -0,0 +1,76 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ParseError is the failure to read or parse a template file
+type ParseError struct {
+	// Path of the template file
+	File string
+	// Name of the template parsed from the file
+	Template string
+	// Position of the error in the file, 0 when unknown
+	Line    int
+	Column  int
+	Message string
+	// Lines of the file around Line
+	Excerpt []SourceLine
+}
+
+func (e *ParseError) Error() string {
+	location := e.File
+	if e.Line > 0 {
+		location += ":" + strconv.Itoa(e.Line)
+		if e.Column > 0 {
+			location += ":" + strconv.Itoa(e.Column)
+		}
+	}
+
+	return location + ": " + e.Message
+}
+
+// ParseErrors lists the template files which failed to parse, in the order they were walked. It is the error
+// returned by InitE and the value Init panics with.
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return "render: " + strings.Join(messages, "\n")
+}
+
+// "template: name:line: message" and "template: name:line:column: message"
+var parseErrorPattern = regexp.MustCompile(`(?s)^template: (.*?):(\d+):(?:(\d+):)? (.*)$`)
+
+// newParseError splits the position out of the error returned by parsing the template name from file, whose
+// source is shown around it
+func newParseError(file, name string, err error, source string) *ParseError {
+	e := &ParseError{File: file, Template: name, Message: err.Error()}
+
+	if m := parseErrorPattern.FindStringSubmatch(err.Error()); m != nil {
+		e.Line, _ = strconv.Atoi(m[2])
+		e.Column, _ = strconv.Atoi(m[3])
+		e.Message = m[4]
+		e.Excerpt = excerptLines(source, e.Line)
+	}
+
+	return e
+}
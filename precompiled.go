@@ -0,0 +1,87 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Template files compiled into the program, by slash separated path relative to Options.Directory
+var precompiled map[string]string
+
+// RegisterTemplates registers template files compiled into the program, by slash separated path relative to
+// Options.Directory such as "layouts/base.tmpl". They are loaded instead of the files of Options.Directory, which
+// is then not needed at run time. The files are meant to be registered at startup, before Init, by the source
+// generated by the render-compile command:
+//
+//	//go:generate render-compile -dir templates -o templates.go
+func RegisterTemplates(files map[string]string) {
+	if precompiled == nil {
+		precompiled = map[string]string{}
+	}
+	for path, source := range files {
+		precompiled[path] = source
+	}
+}
+
+// precompiledFiles returns the files to parse among the registered ones, in the order filepath.Walk would walk them
+func precompiledFiles(textExtensions []string) []templateFile {
+	paths := make([]string, 0, len(precompiled))
+	for path := range precompiled {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return walkLess(paths[i], paths[j])
+	})
+
+	var files []templateFile
+	for _, path := range paths {
+		ext := getExt(path)
+		file := templateFile{
+			path:   filepath.Join(render.options.Directory, filepath.FromSlash(path)),
+			name:   templateName(path, ext),
+			source: precompiled[path],
+			loaded: true,
+		}
+
+		switch {
+		case containsString(render.options.Extensions, ext):
+			files = append(files, file)
+		case containsString(textExtensions, ext):
+			file.text = true
+			files = append(files, file)
+		case containsString(render.options.MustacheExtensions, ext):
+			file.mustache = true
+			files = append(files, file)
+		case engineIndex(ext) >= 0:
+			file.engine = true
+			files = append(files, file)
+		}
+	}
+
+	return files
+}
+
+// walkLess orders slash separated paths the way filepath.Walk walks them, directory by directory
+func walkLess(a, b string) bool {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] != bs[i] {
+			return as[i] < bs[i]
+		}
+	}
+
+	return len(as) < len(bs)
+}
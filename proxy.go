@@ -0,0 +1,104 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Hop-by-hop headers, which are not forwarded
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// ProxyOptions is a struct for specifying the request answered by a Proxy call
+type ProxyOptions struct {
+	// The request being answered. When given, an uncompressed upstream body is compressed as of Options.Compression.
+	Request *http.Request
+	// Send the upstream body as is, even if Options.Compression is enabled.
+	NoCompress bool
+	// Time each write of the body may take, as HTMLOptions.WriteTimeout. Defaults to Options.StreamWriteTimeout.
+	WriteTimeout time.Duration
+}
+
+// Proxy streams the upstream response resp, its status, headers and body, and closes its body. Hop-by-hop headers
+// are dropped, rewrite, when not nil, can edit the other headers before they are written.
+func Proxy(w http.ResponseWriter, resp *http.Response, rewrite func(header http.Header), proxyOptions ...ProxyOptions) {
+	defer resp.Body.Close()
+
+	var option ProxyOptions
+	if len(proxyOptions) > 0 {
+		option = proxyOptions[0]
+	}
+
+	stream, err := startStream()
+	if err != nil {
+		renderError(w, option.Request, err)
+		return
+	}
+	defer endStream(stream)
+
+	header := w.Header()
+	for key, values := range resp.Header {
+		header[key] = append([]string(nil), values...)
+	}
+	for _, connection := range resp.Header.Values("Connection") {
+		for _, key := range strings.Split(connection, ",") {
+			header.Del(strings.TrimSpace(key))
+		}
+	}
+	for _, key := range hopHeaders {
+		header.Del(key)
+	}
+
+	if rewrite != nil {
+		rewrite(header)
+	}
+	// the security headers of the upstream response are kept
+	setSecurityHeaders(header)
+
+	coding := ""
+	if !option.NoCompress && compressibleType(header.Get(ContentType)) &&
+		resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotModified {
+		coding = negotiateSizeCoding(w, option.Request, resp.ContentLength)
+	}
+	if len(coding) == 0 {
+		w.WriteHeader(resp.StatusCode)
+		copyBody(streamOutput{writeDeadline(w, option.WriteTimeout), stream}, resp.Body)
+		return
+	}
+
+	header.Del(ContentLength)
+	header.Set(ContentEncoding, coding)
+	if etag := header.Get(ETag); strings.HasSuffix(etag, `"`) {
+		header.Set(ETag, codingETag(etag, coding))
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	pool := codecs[coding].compressor
+	c := pool.Get().(Compressor)
+	defer pool.Put(c)
+	c.Reset(streamOutput{writeDeadline(w, option.WriteTimeout), stream})
+	copyBody(c, resp.Body)
+	c.Close()
+}
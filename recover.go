@@ -0,0 +1,125 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ronzxy/go-render/negotiate"
+	"net/http"
+	"runtime/debug"
+)
+
+const ContentProblemJSON = "application/problem+json"
+
+// problemDocument is a problem details object of RFC 7807
+type problemDocument struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Stack  string `json:"stack,omitempty"`
+}
+
+// Recover is a middleware answering the panics of next with a 500: a problem+json document when the Accept header
+// prefers JSON, the page of ErrorHTML otherwise. The page is rendered with a map holding Status and Title, and in
+// debug mode only, Detail, the panic value, and Stack, the stack trace. Panics after the response has started can
+// only be logged.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &recoverWriter{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			stack := string(debug.Stack())
+			logError("render: Recover", "method", r.Method, "path", r.URL.Path, "panic", v, "stack", stack)
+			if rw.wroteHeader {
+				return
+			}
+
+			problem := problemDocument{
+				Type:   "about:blank",
+				Title:  http.StatusText(http.StatusInternalServerError),
+				Status: http.StatusInternalServerError,
+			}
+			if render.options.DebugMode {
+				problem.Detail = fmt.Sprint(v)
+				problem.Stack = stack
+			}
+			writeProblem(w, r, problem)
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, problem problemDocument) {
+	w.Header().Del(ContentLength)
+	w.Header().Del(ContentEncoding)
+	addVary(w.Header(), "Accept")
+
+	switch negotiate.Type(r.Header.Get("Accept"), ContentHTML, ContentProblemJSON, ContentJSON) {
+	case ContentProblemJSON, ContentJSON:
+		result, err := json.Marshal(problem)
+		if err == nil {
+			w.Header().Set(ContentType, ContentProblemJSON)
+			setSecurityHeaders(w.Header())
+			w.WriteHeader(problem.Status)
+			w.Write(result)
+			return
+		}
+	}
+
+	data := map[string]interface{}{
+		"Status": problem.Status,
+		"Title":  problem.Title,
+	}
+	if len(problem.Detail) > 0 {
+		data["Detail"] = problem.Detail
+		data["Stack"] = problem.Stack
+	}
+	ErrorHTML(w, r, problem.Status, data)
+}
+
+// recoverWriter tells whether the response has started
+type recoverWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *recoverWriter) WriteHeader(status int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recoverWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *recoverWriter) Flush() {
+	w.wroteHeader = true
+	flushResponse(w.ResponseWriter)
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer
+func (w *recoverWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
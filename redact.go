@@ -0,0 +1,308 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// RedactMask replaces the value of redacted string fields
+const RedactMask = "******"
+
+// Cache of types which may hold redactable fields, reset by Init
+var redactTypes sync.Map
+
+type redactor struct {
+	// Pointers, maps and slices on the current path, to stop walking cyclic values
+	seen map[redactKey]bool
+}
+
+type redactKey struct {
+	kind    reflect.Kind
+	pointer uintptr
+	// Length of a slice, its subslices being other values
+	length int
+}
+
+// redact returns a copy of v in which every struct field tagged with `redact:"true"`, every struct field and every
+// map key listed in Options.RedactFields is masked. Strings are replaced with RedactMask, other types are zeroed.
+// v is returned as is when it holds none of them.
+func redact(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if !redactable(rv.Type()) {
+		return v
+	}
+
+	r := redactor{seen: map[redactKey]bool{}}
+	redacted, _ := r.value(rv)
+
+	return redacted.Interface()
+}
+
+func resetRedactTypes() {
+	redactTypes.Range(func(key, value interface{}) bool {
+		redactTypes.Delete(key)
+		return true
+	})
+}
+
+func redactable(t reflect.Type) bool {
+	if ok, found := redactTypes.Load(t); found {
+		return ok.(bool)
+	}
+
+	ok := typeRedactable(t, map[reflect.Type]bool{})
+	redactTypes.Store(t, ok)
+
+	return ok
+}
+
+func typeRedactable(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Interface:
+		// Dynamic values have to be inspected at render time
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return typeRedactable(t.Elem(), seen)
+	case reflect.Map:
+		if t.Key().Kind() == reflect.String && len(render.options.RedactFields) > 0 {
+			return true
+		}
+		return typeRedactable(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" && !f.Anonymous {
+				continue
+			}
+			if isRedactField(f) || typeRedactable(f.Type, seen) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func isRedactField(f reflect.StructField) bool {
+	return f.Tag.Get("redact") == "true" || isRedactName(f.Name)
+}
+
+func isRedactName(name string) bool {
+	for _, field := range render.options.RedactFields {
+		if strings.EqualFold(field, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// value returns v with its redacted fields masked, and whether it masked any. v is copied only when it did, so that
+// the values which only may hold redactable fields, such as a map[string]interface{} of strings, are not.
+func (r redactor) value(v reflect.Value) (reflect.Value, bool) {
+	if !v.IsValid() || !redactable(v.Type()) {
+		return v, false
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v, false
+		}
+		elem, changed := r.value(v.Elem())
+		if !changed {
+			return v, false
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(elem)
+		return c, true
+	case reflect.Ptr:
+		if v.IsNil() || !r.enter(v) {
+			return v, false
+		}
+		defer r.leave(v)
+
+		elem, changed := r.value(v.Elem())
+		if !changed {
+			return v, false
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(elem)
+		return c, true
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() || !r.enter(v) {
+				return v, false
+			}
+			defer r.leave(v)
+		}
+
+		var c reflect.Value
+		for i := 0; i < v.Len(); i++ {
+			elem, changed := r.value(v.Index(i))
+			if !changed {
+				continue
+			}
+			if !c.IsValid() {
+				c = copyList(v)
+			}
+			c.Index(i).Set(elem)
+		}
+		return orValue(c, v)
+	case reflect.Map:
+		if v.IsNil() || !r.enter(v) {
+			return v, false
+		}
+		defer r.leave(v)
+
+		var c reflect.Value
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key()
+			var elem reflect.Value
+			if key.Kind() == reflect.String && isRedactName(key.String()) {
+				elem = redactMask(v.Type().Elem())
+			} else if value, changed := r.value(iter.Value()); changed {
+				elem = value
+			} else {
+				continue
+			}
+			if !c.IsValid() {
+				c = reflect.MakeMapWithSize(v.Type(), v.Len())
+				for copied := v.MapRange(); copied.Next(); {
+					c.SetMapIndex(copied.Key(), copied.Value())
+				}
+			}
+			c.SetMapIndex(key, elem)
+		}
+		return orValue(c, v)
+	case reflect.Struct:
+		var c reflect.Value
+		changed := false
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			field := v.Field(i)
+			if f.PkgPath != "" {
+				// encoding/json and encoding/xml skip the unexported fields, but for the fields promoted from an
+				// unexported embedded struct
+				if !f.Anonymous || !isRedactField(f) && !redactable(f.Type) {
+					continue
+				}
+				// read and written through a copy of its own, the values read through an unexported field can't
+				// be copied
+				if !c.IsValid() {
+					c = reflect.New(v.Type()).Elem()
+					c.Set(v)
+				}
+				field = c.Field(i)
+				field = reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem()
+			}
+
+			var elem reflect.Value
+			if isRedactField(f) {
+				elem = redactMask(f.Type)
+			} else if value, ok := r.value(field); ok {
+				elem = value
+			} else {
+				continue
+			}
+			if !c.IsValid() {
+				c = reflect.New(v.Type()).Elem()
+				c.Set(v)
+			}
+			if target := c.Field(i); target.CanSet() {
+				target.Set(elem)
+			} else {
+				field.Set(elem)
+			}
+			changed = true
+		}
+		if changed {
+			return c, true
+		}
+		return v, false
+	}
+
+	return v, false
+}
+
+// enter marks the pointer, map or slice v as on the current path, false when it already is, the value being cyclic
+func (r redactor) enter(v reflect.Value) bool {
+	key := redactKey{kind: v.Kind(), pointer: v.Pointer()}
+	if v.Kind() == reflect.Slice {
+		key.length = v.Len()
+	}
+	if r.seen[key] {
+		return false
+	}
+	r.seen[key] = true
+
+	return true
+}
+
+func (r redactor) leave(v reflect.Value) {
+	key := redactKey{kind: v.Kind(), pointer: v.Pointer()}
+	if v.Kind() == reflect.Slice {
+		key.length = v.Len()
+	}
+	delete(r.seen, key)
+}
+
+// copyList returns a copy of the slice or array v
+func copyList(v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Array {
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		return c
+	}
+	c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+	reflect.Copy(c, v)
+
+	return c
+}
+
+// orValue returns the copy c when it was made, v otherwise
+func orValue(c, v reflect.Value) (reflect.Value, bool) {
+	if c.IsValid() {
+		return c, true
+	}
+
+	return v, false
+}
+
+func redactMask(t reflect.Type) reflect.Value {
+	mask := reflect.ValueOf(RedactMask)
+	if t.Kind() == reflect.String {
+		return mask.Convert(t)
+	}
+	if mask.Type().AssignableTo(t) {
+		c := reflect.New(t).Elem()
+		c.Set(mask)
+		return c
+	}
+
+	return reflect.Zero(t)
+}
@@ -0,0 +1,93 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type redactBase struct {
+	Name     string
+	Password string `redact:"true"`
+}
+
+type redactEmbedded struct {
+	redactBase
+	Role string
+}
+
+type redactEmbeddedPointer struct {
+	*redactBase
+}
+
+func TestRedactEmbeddedStruct(t *testing.T) {
+	initTemplates(t, nil, Options{})
+
+	for _, v := range []interface{}{
+		redactEmbedded{redactBase: redactBase{Name: "ann", Password: "secret"}, Role: "admin"},
+		&redactEmbeddedPointer{&redactBase{Name: "ann", Password: "secret"}},
+		[]interface{}{redactEmbedded{redactBase: redactBase{Password: "secret"}}},
+	} {
+		w := httptest.NewRecorder()
+		JSON(w, 200, v)
+		if strings.Contains(w.Body.String(), "secret") || !strings.Contains(w.Body.String(), RedactMask) {
+			t.Errorf("%T: got %s", v, w.Body.String())
+		}
+	}
+
+	// the value rendered is left as is
+	user := &redactEmbeddedPointer{&redactBase{Password: "secret"}}
+	redact(user)
+	if user.Password != "secret" {
+		t.Errorf("redact changed the value: %q", user.Password)
+	}
+}
+
+func TestRedactFields(t *testing.T) {
+	initTemplates(t, nil, Options{RedactFields: []string{"token"}})
+
+	w := httptest.NewRecorder()
+	JSON(w, 200, map[string]interface{}{"Token": "abc", "Nested": []interface{}{map[string]string{"token": "def"}}})
+	if got, want := w.Body.String(), `{"Nested":[{"token":"******"}],"Token":"******"}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestRedactLeavesPlainValues(t *testing.T) {
+	initTemplates(t, nil, Options{})
+
+	v := map[string]interface{}{"a": "b", "c": []interface{}{1, "d"}}
+	if redacted := redact(v); reflect.ValueOf(redacted).Pointer() != reflect.ValueOf(v).Pointer() {
+		t.Error("a value without redacted fields was copied")
+	}
+}
+
+func TestRedactCycles(t *testing.T) {
+	initTemplates(t, nil, Options{RedactFields: []string{"token"}})
+
+	m := map[string]interface{}{"token": "abc"}
+	m["self"] = m
+	s := []interface{}{redactBase{Password: "secret"}, nil}
+	s[1] = s
+
+	// returns instead of walking forever
+	if redacted := redact(m).(map[string]interface{}); redacted["token"] != RedactMask {
+		t.Errorf("got %v", redacted["token"])
+	}
+	if redacted := redact(s).([]interface{}); redacted[0].(redactBase).Password != RedactMask {
+		t.Errorf("got %v", redacted[0])
+	}
+}
@@ -0,0 +1,276 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileStamp tells whether a file changed
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Default of Options.ReloadInterval
+const defaultReloadInterval = time.Second
+
+// State of the debug mode watcher, which reloads the templates when their files change
+var (
+	watchMu sync.Mutex
+	// Template and catalog files as of the last load
+	watchedFiles map[string]fileStamp
+	// Closed to stop the watcher of the last Init, nil when there is none
+	watchStop chan struct{}
+
+	watchErrMu sync.Mutex
+	// Error of the last reload of the watcher, which the renders answer in debug mode
+	watchErr error
+)
+
+// startWatcher starts the debug mode watcher, which looks for changed files every Options.ReloadInterval in the
+// background, the renders never walking the directories themselves
+func startWatcher() {
+	if !render.options.DebugMode {
+		return
+	}
+
+	watchMu.Lock()
+	defer watchMu.Unlock()
+
+	stop := make(chan struct{})
+	watchStop = stop
+	go func(interval time.Duration) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				reloadChanged(stop)
+			}
+		}
+	}(render.options.ReloadInterval)
+}
+
+// stopWatcher stops the watcher of the last Init. It does not reload anything once stopWatcher returns.
+func stopWatcher() {
+	watchMu.Lock()
+	if watchStop != nil {
+		close(watchStop)
+		watchStop = nil
+	}
+	watchMu.Unlock()
+
+	setWatchError(nil)
+}
+
+func setWatchError(err error) {
+	watchErrMu.Lock()
+	watchErr = err
+	watchErrMu.Unlock()
+}
+
+// watchError returns the error of the last reload of the watcher
+func watchError() error {
+	watchErrMu.Lock()
+	defer watchErrMu.Unlock()
+
+	return watchErr
+}
+
+// snapshotFiles returns the stamps of the template files and of the catalogs
+func snapshotFiles() map[string]fileStamp {
+	extensions := append(append(append(append([]string{}, render.options.Extensions...), render.options.TextExtensions...),
+		render.options.MarkdownExtensions...), render.options.MustacheExtensions...)
+	extensions = append(extensions, engineExtensions()...)
+
+	stamps := map[string]fileStamp{}
+	// the templates registered with RegisterTemplates are not read from their files
+	if loader := render.options.Loader; loader != nil {
+		for _, path := range loader.List() {
+			if !containsString(extensions, getExt(filepath.Base(path))) {
+				continue
+			}
+			if buf, modTime, err := loader.Load(path); err == nil {
+				stamps[filepath.Join(render.options.Directory, filepath.FromSlash(path))] = fileStamp{modTime: modTime, size: int64(len(buf))}
+			}
+		}
+	} else if len(precompiled) == 0 {
+		for _, dir := range render.options.Directories {
+			filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() || !containsString(extensions, getExt(filepath.Base(path))) {
+					return nil
+				}
+				stamps[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+				return nil
+			})
+		}
+	}
+
+	if dir := render.options.MessagesDirectory; len(dir) > 0 {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+		for _, file := range files {
+			if info, err := os.Stat(file); err == nil {
+				stamps[file] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+			}
+		}
+	}
+
+	return stamps
+}
+
+// reloadChanged reloads the templates when their files changed since the last load, unless the watcher was stopped.
+// Changed and added HTML templates are parsed again on their own, other changes reload everything. A failed reload is
+// tried again on the next look, the files being still changed.
+func reloadChanged(stop chan struct{}) {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+
+	select {
+	case <-stop:
+		return
+	default:
+	}
+
+	stamps := snapshotFiles()
+	var changed []string
+	for path, stamp := range stamps {
+		if previous, ok := watchedFiles[path]; !ok || previous != stamp {
+			changed = append(changed, path)
+		}
+	}
+	removed := false
+	for path := range watchedFiles {
+		if _, ok := stamps[path]; !ok {
+			removed = true
+			break
+		}
+	}
+	// the files are the ones of the last load, which succeeded, such as the one of Reload
+	if len(changed) == 0 && !removed {
+		setWatchError(nil)
+		return
+	}
+	sort.Strings(changed)
+	logDebug("render: reloading the templates", "changed", len(changed))
+
+	var err error
+	reloaded := false
+	if !removed && loadedSet.Load() != nil {
+		err, reloaded = reloadFiles(changed, stamps)
+	}
+	if !reloaded {
+		err = loadTemplates()
+	}
+	recordReload(err)
+	setWatchError(err)
+}
+
+// Reload parses all the template files and the catalogs again, such as from an admin endpoint. When some fail to
+// parse, the templates loaded before are kept and served, Health turns degraded and the ParseErrors are returned.
+func Reload() error {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+
+	err := loadTemplates()
+	recordReload(err)
+	return err
+}
+
+// reloadFiles parses the changed HTML template files again into a copy of the templates. It fails, returning false,
+// when the files are not all HTML templates, or when they define other templates, which could be left stale.
+func reloadFiles(paths []string, stamps map[string]fileStamp) (error, bool) {
+	// the files of a Loader are all loaded again
+	if render.options.Loader != nil {
+		return nil, false
+	}
+
+	type file struct {
+		path, name, source string
+	}
+	var files []file
+	for _, path := range paths {
+		// a file overridden by the one of a later directory is not loaded
+		relativePath, ok := relativeTemplatePath(path)
+		if !ok || templatePath(relativePath) != path {
+			return nil, false
+		}
+		ext := getExt(relativePath)
+		if !containsString(render.options.Extensions, ext) {
+			return nil, false
+		}
+
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, false
+		}
+		name := templateName(relativePath, ext)
+		if definesTemplates(loaded().sources[name]) || definesTemplates(string(buf)) {
+			return nil, false
+		}
+		files = append(files, file{path: path, name: name, source: string(buf)})
+	}
+
+	set := *loaded()
+	t, err := set.pristine.Clone()
+	if err != nil {
+		return nil, false
+	}
+	sources := make(map[string]string, len(set.sources)+len(files))
+	for name, source := range set.sources {
+		sources[name] = source
+	}
+
+	var infos []TemplateInfo
+	var parseErrors ParseErrors
+	for _, f := range files {
+		sources[f.name] = f.source
+		infos = append(infos, templateInfo(templateFile{path: f.path, name: f.name, source: f.source}))
+		trees, err := parseHTMLTemplate(t, f.name, f.source)
+		if err == nil {
+			err = runTemplateHooks(trees)
+		}
+		if err != nil {
+			parseErrors = append(parseErrors, newParseError(f.path, f.name, err, f.source))
+		}
+	}
+	if len(parseErrors) > 0 {
+		return parseErrors, true
+	}
+
+	set.template = template.Must(t.Clone())
+	set.pristine = t
+	set.clones = newTemplatePool(t)
+	set.sources = sources
+	set.manifest = mergeManifest(set.manifest, infos)
+	loadedSet.Store(&set)
+	resetInheritedSets()
+	resetTenantSets()
+	watchedFiles = stamps
+
+	return nil, true
+}
+
+// definesTemplates tells whether the source may define named templates
+func definesTemplates(source string) bool {
+	return strings.Contains(source, "define") || strings.Contains(source, "block")
+}
@@ -0,0 +1,115 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReloadWhileRendering(t *testing.T) {
+	initTemplates(t, map[string]string{
+		"layout.tmpl": `<main>{{yield}}</main>`,
+		"base.tmpl":   `<main>{{block "content" .}}{{end}}</main>`,
+		"page.tmpl":   `{{define "content"}}page{{end}}`,
+	}, Options{Layout: "layout"})
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(extends string) {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				w := httptest.NewRecorder()
+				HTML(w, 200, "page", nil, HTMLOptions{Extends: extends})
+				if w.Code != 200 {
+					t.Errorf("got %d %q", w.Code, w.Body.String())
+					return
+				}
+			}
+		}([]string{"", "base"}[i%2])
+	}
+
+	for i := 0; i < 20; i++ {
+		if err := Reload(); err != nil {
+			t.Error(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestReloadServesTheNewTemplates(t *testing.T) {
+	initTemplates(t, map[string]string{"page.tmpl": `old`}, Options{})
+
+	if err := ioutil.WriteFile(filepath.Join(render.options.Directory, "page.tmpl"), []byte(`new`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	HTML(w, 200, "page", nil)
+	if w.Body.String() != "new" {
+		t.Errorf("got %q", w.Body.String())
+	}
+}
+
+func TestDebugModeWatcher(t *testing.T) {
+	initTemplates(t, map[string]string{"page.tmpl": `old`}, Options{DebugMode: true, ReloadInterval: 10 * time.Millisecond})
+	defer stopWatcher()
+	file := filepath.Join(render.options.Directory, "page.tmpl")
+
+	// renders until the watcher reloaded the page, or the deadline
+	await := func(want func(body string, err error) bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			w := httptest.NewRecorder()
+			err := HTMLE(w, 200, "page", nil)
+			if want(w.Body.String(), err) {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("got %q, %v", w.Body.String(), err)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	if err := ioutil.WriteFile(file, []byte(`{{`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	await(func(body string, err error) bool {
+		_, ok := err.(ParseErrors)
+		return ok
+	})
+
+	if err := ioutil.WriteFile(file, []byte(`new`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	await(func(body string, err error) bool {
+		return err == nil && body == "new"
+	})
+}
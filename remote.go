@@ -0,0 +1,211 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Default time a file of an HTTPLoader is used before it is revalidated
+	defaultRemoteTTL = time.Minute
+	// Default maximum size of the files an HTTPLoader keeps
+	defaultRemoteMaxBytes = 32 << 20
+)
+
+// Client of the HTTPLoaders without one, a fetch taking longer than its timeout fails and the last good copy is used
+var defaultRemoteClient = &http.Client{Timeout: 10 * time.Second}
+
+// HTTPLoader is the Loader of template files published under a URL prefix, such as an S3 bucket or a CDN the
+// templates of a CMS are uploaded to. The files are listed by an index, a JSON array of their paths:
+//
+//	["layouts/base.tmpl", "pages/home.tmpl"]
+//
+// Each file is cached for TTL, then revalidated with its ETag or Last-Modified. When a fetch fails, the last good
+// copy is used, so that an outage of the storage does not break the pages. The least recently loaded files beyond
+// MaxBytes are dropped, and fetched again on their next load. An HTTPLoader must not be copied after first use.
+//
+//	render.Options{Loader: &render.HTTPLoader{BaseURL: "https://cms.example.com/templates/"}}
+type HTTPLoader struct {
+	// URL prefix of the files, the slash separated path of a file being appended to it
+	BaseURL string
+	// Path of the index relative to BaseURL. Defaults to "index.json".
+	Index string
+	// Client fetching the files. Defaults to a client timing out after 10 seconds.
+	Client *http.Client
+	// Time a file is used before it is revalidated. Defaults to a minute.
+	TTL time.Duration
+	// Headers of the requests, such as Authorization
+	Header http.Header
+	// Maximum size of the files kept. Defaults to 32 MiB.
+	MaxBytes int64
+	// Time after which a file not loaded is dropped, in the background. It is fetched again on its next load, with no
+	// last good copy to fall back on. Defaults to 0, kept until MaxBytes drops it.
+	MaxIdle time.Duration
+
+	mu    sync.Mutex
+	files lruCache
+}
+
+// remoteFile is the last good copy of a file of an HTTPLoader
+type remoteFile struct {
+	body         []byte
+	etag         string
+	lastModified string
+	// Time the body was fetched with a change
+	modTime time.Time
+	// Time the body was last fetched or revalidated
+	checked time.Time
+}
+
+func (l *HTTPLoader) List() []string {
+	index := l.Index
+	if len(index) == 0 {
+		index = "index.json"
+	}
+
+	buf, _, err := l.Load(index)
+	if err != nil {
+		logError("render: HTTPLoader index", "err", err)
+		return nil
+	}
+	var paths []string
+	if err := json.Unmarshal(buf, &paths); err != nil {
+		logError("render: HTTPLoader index", "err", err)
+		return nil
+	}
+
+	return paths
+}
+
+func (l *HTTPLoader) Load(path string) ([]byte, time.Time, error) {
+	l.mu.Lock()
+	var cached *remoteFile
+	if file, ok := l.files.get(path); ok {
+		cached = file.(*remoteFile)
+	}
+	l.mu.Unlock()
+
+	ttl := l.TTL
+	if ttl <= 0 {
+		ttl = defaultRemoteTTL
+	}
+	if cached != nil && time.Since(cached.checked) < ttl {
+		return cached.body, cached.modTime, nil
+	}
+
+	file, err := l.fetch(path, cached)
+	if err != nil {
+		if cached != nil {
+			logError("render: HTTPLoader fetch failed, serving the last good copy", "path", path, "err", err)
+			return cached.body, cached.modTime, nil
+		}
+		return nil, time.Time{}, err
+	}
+
+	l.mu.Lock()
+	l.files.add(path, file, int64(len(file.body)), 0, l.maxBytes())
+	l.files.dropIdleLater(&l.mu, l.MaxIdle)
+	l.mu.Unlock()
+
+	return file.body, file.modTime, nil
+}
+
+// Stats returns the occupancy of the file cache
+func (l *HTTPLoader) Stats() CacheStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.files.stats(0, l.maxBytes())
+}
+
+func (l *HTTPLoader) maxBytes() int64 {
+	if l.MaxBytes <= 0 {
+		return defaultRemoteMaxBytes
+	}
+
+	return l.MaxBytes
+}
+
+// fetch gets the file at path, revalidating cached when it is not nil
+func (l *HTTPLoader) fetch(path string, cached *remoteFile) (*remoteFile, error) {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, segment := range segments {
+		if segment == "." || segment == ".." {
+			return nil, fmt.Errorf("render: HTTPLoader path %q leaves BaseURL", path)
+		}
+		segments[i] = url.PathEscape(segment)
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(l.BaseURL, "/")+"/"+strings.Join(segments, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range l.Header {
+		req.Header[key] = values
+	}
+	if cached != nil {
+		if len(cached.etag) > 0 {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if len(cached.lastModified) > 0 {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	client := l.Client
+	if client == nil {
+		client = defaultRemoteClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	now := time.Now()
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		file := *cached
+		file.checked = now
+		return &file, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("render: GET %s: %s", req.URL, resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	file := &remoteFile{
+		body:         body,
+		etag:         resp.Header.Get(ETag),
+		lastModified: resp.Header.Get("Last-Modified"),
+		modTime:      now,
+		checked:      now,
+	}
+	if modTime, err := http.ParseTime(file.lastModified); err == nil {
+		file.modTime = modTime
+	}
+	if cached != nil && string(cached.body) == string(body) {
+		file.modTime = cached.modTime
+	}
+
+	return file, nil
+}
@@ -0,0 +1,43 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPLoaderEscapesPaths(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.EscapedPath())
+		fmt.Fprint(w, "x")
+	}))
+	defer server.Close()
+
+	loader := &HTTPLoader{BaseURL: server.URL + "/templates/"}
+	if _, _, err := loader.Load("pages/a b?#.tmpl"); err != nil {
+		t.Fatal(err)
+	}
+	if len(requested) != 1 || requested[0] != "/templates/pages/a%20b%3F%23.tmpl" {
+		t.Errorf("got %v", requested)
+	}
+	if _, _, err := loader.Load("../secrets.tmpl"); err == nil {
+		t.Error("loaded a path out of BaseURL")
+	}
+	if defaultRemoteClient.Timeout <= 0 {
+		t.Error("the default client has no timeout")
+	}
+}
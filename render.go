@@ -14,50 +14,115 @@ package render
 
 import (
 	"bytes"
+	"context"
+	"encoding/gob"
 	"encoding/json"
-	"encoding/xml"
+	"errors"
 	"fmt"
 	"github.com/ronzxy/go-helper"
-	"github.com/ronzxy/go-logger"
+	"github.com/ronzxy/go-render/negotiate"
 	"html/template"
 	"io"
-	"io/ioutil"
 	"net/http"
-	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	texttemplate "text/template"
+	"time"
 )
 
 const (
-	ContentType    = "Content-Type"
-	ContentLength  = "Content-Length"
-	ContentBinary  = "application/octet-stream"
-	ContentText    = "text/plain"
-	ContentJSON    = "application/json"
-	ContentHTML    = "text/html"
-	ContentXHTML   = "application/xhtml+xml"
-	ContentXML     = "text/xml"
-	defaultCharset = "UTF-8"
+	ContentType        = "Content-Type"
+	ContentLength      = "Content-Length"
+	ContentDisposition = "Content-Disposition"
+	ETag               = "ETag"
+	ContentBinary      = "application/octet-stream"
+	ContentText        = "text/plain"
+	ContentJSON        = "application/json"
+	ContentJSONAPI     = "application/vnd.api+json"
+	ContentHAL         = "application/hal+json"
+	ContentHTML        = "text/html"
+	ContentXHTML       = "application/xhtml+xml"
+	ContentXML         = "text/xml"
+	ContentGob         = "application/x-gob"
+	ContentEventStream = "text/event-stream"
+	defaultCharset     = "UTF-8"
 )
 
 var (
 	render = renderer{}
 )
 
+// ErrResponseTooLarge is returned when a rendered body exceeds Options.MaxResponseBytes
+var ErrResponseTooLarge = errors.New("render: response body exceeds MaxResponseBytes")
+
+var errInvalidJSON = errors.New("render: invalid JSON passed to RawJSON")
+
 // Included helper functions for use when rendering html
 var helperFuncs = template.FuncMap{
-	"yield": func() (string, error) {
+	"yield": func(args ...interface{}) (string, error) {
 		return "", fmt.Errorf("yield called with no layout defined")
 	},
+	"content_for": func(section, name string) (string, error) {
+		return "", nil
+	},
 	"current": func() (string, error) {
 		return "", nil
 	},
+	"flush": func() (string, error) {
+		return "", nil
+	},
+	"chunks": chunks,
 }
 
 type renderer struct {
+	buffer  *helper.BufferPool
+	options Options
+}
+
+// templateSet is what a load of the template files, the catalogs and the assets makes. A load publishes a new set
+// as a whole, never changed afterwards, so that the renders running meanwhile keep reading the one they started with.
+type templateSet struct {
 	template *template.Template
-	buffer   *helper.BufferPool
-	options  Options
+	// Never executed copy of template, which can still be cloned
+	pristine *template.Template
+	// Clones of pristine the renders execute
+	clones *templatePool
+	// Source of the HTML template files, by template name
+	sources map[string]string
+	text    *texttemplate.Template
+	// Mustache templates, by name
+	mustache map[string]*mustacheTemplate
+	// Templates of Options.Engines, by name
+	engines map[string]engineTemplate
+	// Message catalogs by locale
+	catalogs map[string]Catalog
+	// Locales negotiated with the Accept-Language header
+	locales []string
+	// Template files loaded, see Manifest
+	manifest []TemplateInfo
+	// Fingerprinted paths of the assets by path, and the other way around
+	assets     map[string]string
+	assetFiles map[string]string
+	// Chunks of the Vite manifest, by source file
+	vite map[string]viteChunk
+}
+
+var (
+	// Set of the last successful load, nil before the first one
+	loadedSet atomic.Pointer[templateSet]
+	// Set read before the first load
+	emptySet = &templateSet{}
+)
+
+// loaded returns the set of the last successful load of the templates
+func loaded() *templateSet {
+	if set := loadedSet.Load(); set != nil {
+		return set
+	}
+
+	return emptySet
 }
 
 // Delimiter represents a set of Left and Right delimiters for HTML template rendering
@@ -72,44 +137,391 @@ type Delimiter struct {
 type Options struct {
 	// Directory to load templates. Default is "templates"
 	Directory string `yaml:"Directory"`
+	// Loader of the template files, such as from a database, instead of the files of Directories. Defaults to none.
+	Loader Loader `yaml:"-"`
+	// Directories to load templates from, the files of a later directory overriding the ones of the same relative
+	// path of the earlier directories, such as a base theme shipped in a library and the local templates of an
+	// application. Defaults to [Directory], Directory defaulting to the last one.
+	Directories []string `yaml:"Directories"`
 	// Layout template name. Will not render a layout if "". Defaults to "".
 	Layout string `yaml:"Layout"`
+	// Template pages extend, a layout made of {{block}} actions the pages override with {{define}} actions, instead
+	// of calling yield. Defaults to "".
+	Extends string `yaml:"Extends"`
 	// Extensions to parse template files from. Defaults to [".tmpl"]
 	Extensions []string `yaml:"Extensions"`
+	// Extensions to parse text/template files from, executed without HTML escaping. Defaults to [".txt.tmpl"]
+	TextExtensions []string `yaml:"TextExtensions"`
+	// Extensions to parse Markdown text/template files from, rendered by Markdown, such as [".md"]. Defaults to none,
+	// a README.md of the directories is not parsed.
+	MarkdownExtensions []string `yaml:"MarkdownExtensions"`
+	// Extensions to parse logic-less Mustache template files from, rendered by Mustache, such as [".mustache"].
+	// Defaults to none.
+	MustacheExtensions []string `yaml:"MustacheExtensions"`
+	// Engines of the template files of other extensions, rendered by HTML. Defaults to none.
+	Engines []Engine `yaml:"-"`
+	// Markdown converts the Markdown of Markdown and of the markdown template func.
+	Markdown MarkdownConverter `yaml:"-"`
+	// Name prefixes of the templates of directories, such as "shared/" to "_" naming "shared/header.tmpl" "_header",
+	// for the partial naming conventions of Rails-style projects. The longest matching directory wins. Defaults to none.
+	PartialPrefixes map[string]string `yaml:"PartialPrefixes"`
 	// Funcs is a slice of FuncMap to apply to the template upon compilation. This is useful for helper functions. Defaults to [].
 	FuncMap template.FuncMap `yaml:"FuncMap"`
+	// Values available to all the templates, such as the version of the application or the navigation: merged under
+	// the map bindings, read with the global func whatever the binding. SetGlobal changes them at runtime.
+	GlobalData map[string]interface{} `yaml:"GlobalData"`
+	// ContextData derives values from the context of the request of each render, such as the user a middleware
+	// authenticated: merged under the map bindings over GlobalData, read with the context func whatever the binding.
+	// Called once per render given the request. Defaults to none.
+	ContextData func(ctx context.Context) map[string]interface{} `yaml:"-"`
+	// Add a library of common template funcs, such as upper, trunc, add, dict, default, ternary and date, named
+	// after the ones of sprig. FuncMap overrides them.
+	EnableHelperFuncs bool `yaml:"EnableHelperFuncs"`
+	// Add the formatNumber, formatCurrency, formatPercent, formatDate and formatTime template funcs, which write
+	// values the way the locale of the render does.
+	EnableFormatFuncs bool `yaml:"EnableFormatFuncs"`
+	// Date and time formats of the format funcs by locale, such as "de-CH", adding to or overriding the built-in ones.
+	// Numbers, percentages and amounts follow the CLDR data of the locale.
+	LocaleFormats map[string]LocaleFormat `yaml:"-"`
+	// FuncMaps of the templates within a scope, a directory such as "admin/" or a path.Match pattern of template
+	// names such as "admin/*". They override FuncMap, and the longest pattern wins among the scopes of a template.
+	ScopedFuncMaps map[string]template.FuncMap `yaml:"-"`
+	// Guards of the templates within a scope, a directory such as "admin/" or a path.Match pattern such as "admin/*",
+	// which HTML consults with the principal of the request before rendering a template. When one denies it, the
+	// ForbiddenTemplate is rendered with a 403 Forbidden instead. Defaults to none.
+	Guards map[string]Guard `yaml:"-"`
+	// Principal returns the principal of a request given to the Guards. Defaults to the one of WithPrincipal.
+	Principal func(r *http.Request) interface{} `yaml:"-"`
+	// Template rendered when a guard denies a template, with the name of that template. Defaults to the error page
+	// of 403, such as "errors/403", or to a plain 403 Forbidden when there is none.
+	ForbiddenTemplate string `yaml:"ForbiddenTemplate"`
+	// Fail Init with the FuncCollisions of FuncMap, FuncFactories and ScopedFuncMaps with the built-in funcs or with
+	// one another, instead of logging them.
+	StrictFuncs bool `yaml:"StrictFuncs"`
+	// Render the same HTML bytes for the same templates and binding: the now func of the helper library returns the
+	// Unix epoch, line endings are normalized to LF without trailing spaces and the digest of the body is set in the
+	// Render-Digest header. Does not apply to HTMLStream.
+	Reproducible bool `yaml:"Reproducible"`
 	// Delimiter sets the action delimiters to the specified strings in the Delimiter struct.
 	Delimiter Delimiter `yaml:"Delimiter"`
 	// Appends the given charset to the Content-Type header. Default is "UTF-8".
 	Charset string `yaml:"Charset"`
 	// Outputs human readable JSON
 	IndentJSON bool `yaml:"IndentJSON"`
+	// JSONEncoder marshals JSON bodies. Defaults to encoding/json.
+	JSONEncoder JSONEncoder `yaml:"-"`
+	// Do not escape <, > and & in JSON strings as \u003c, \u003e and \u0026.
+	JSONNoEscapeHTML bool `yaml:"JSONNoEscapeHTML"`
+	// End JSON bodies with a newline, like json.Encoder does.
+	JSONTrailingNewline bool `yaml:"JSONTrailingNewline"`
 	// Outputs human readable XML
 	IndentXML bool `yaml:"IndentXML"`
-	// Prefixes the JSON output with the given bytes.
-	PrefixJSON []byte `yaml:"PrefixJSON"`
-	// Prefixes the XML output with the given bytes.
+	// Prefix of the JSON bodies whose top level is an array, against JSON hijacking: HijackAngular for )]}',\n or
+	// HijackWhile for while(1);. The clients strip it before parsing, see HijackProtection. Default is HijackNone.
+	JSONHijackProtection HijackProtection `yaml:"JSONHijackProtection"`
+	// Prefixes the XML output with the given bytes, after the XML declaration.
 	PrefixXML []byte `yaml:"PrefixXML"`
+	// Start XML output with <?xml version="1.0" encoding="UTF-8"?>, with the encoding of Charset.
+	XMLDeclaration bool `yaml:"XMLDeclaration"`
 	// Allows changing of output to XHTML instead of HTML. Default is "text/html"
 	HTMLContentType string `yaml:"HTMLContentType"`
 	// Initial BufferPool cap
 	BufferPool int `yaml:"BufferPool"`
-	// Set template in debug mode to refresh template.
+	// Set template in debug mode to refresh template. The templates are reloaded when their files change.
 	DebugMode bool `yaml:"DebugMode"`
+	// Time between two looks for changed template files in debug mode, made in the background and not by the renders.
+	// Default is 1s.
+	ReloadInterval time.Duration `yaml:"ReloadInterval"`
+	// Time the templates loaded before are served after a reload of debug mode fails to parse, Health reporting
+	// degraded, before the renders fail with the ParseErrors. Default is 0, they fail right away to show the errors.
+	StaleFor time.Duration `yaml:"StaleFor"`
+	// Number of template files parsed at the same time. Defaults to the number of CPUs.
+	ParseWorkers int `yaml:"ParseWorkers"`
+	// Field names masked in JSON, XML and gob output, in addition to fields tagged with `redact:"true"`. Case insensitive,
+	// also matches map keys.
+	RedactFields []string `yaml:"RedactFields"`
+	// Size beyond which the output of an HTML render is moved from memory to a temporary file, from which it is
+	// streamed once the render succeeded. Such outputs are not made reproducible. Default is 0, no file.
+	SpoolThreshold int64 `yaml:"SpoolThreshold"`
+	// Directory of the files of SpoolThreshold. Defaults to the directory of temporary files of the system.
+	SpoolDirectory string `yaml:"SpoolDirectory"`
+	// Maximum size of a buffered JSON, XML, gob or HTML body. Rendering is aborted with a 500 when exceeded. Default is 0,
+	// no limit.
+	MaxResponseBytes int64 `yaml:"MaxResponseBytes"`
+	// Set a strong ETag computed from the body of successful JSON, XML, gob and HTML responses, and answer requests
+	// with a matching If-None-Match with 304 Not Modified.
+	GenerateETags bool `yaml:"GenerateETags"`
+	// Check that the bodies given to RawJSON are well-formed JSON.
+	ValidateRawJSON bool `yaml:"ValidateRawJSON"`
+	// Compress buffered bodies when the client accepts it.
+	Compression Compression `yaml:"Compression"`
+	// Maximum number of templates executed at the same time by HTML, HTMLMulti and HTMLStream. Default is 0, no
+	// limit.
+	MaxConcurrentRenders int `yaml:"MaxConcurrentRenders"`
+	// Time a render waits for one of the MaxConcurrentRenders slots before it fails with 503. Default is 0, fail
+	// right away.
+	RenderQueueTimeout time.Duration `yaml:"RenderQueueTimeout"`
+	// Time each write of Stream, HTMLStream, Proxy and EventStream may take, the write deadline of the response being
+	// pushed back before each one: the write to a client which stopped reading fails instead of blocking its
+	// goroutine. HTMLOptions.WriteTimeout and ProxyOptions.WriteTimeout override it. Defaults to 0, the deadline of
+	// the server is left as is.
+	StreamWriteTimeout time.Duration `yaml:"StreamWriteTimeout"`
+	// Interval of the comments an EventStream sends when it has nothing else to send, so that proxies keep the
+	// connection open and a client which went away is detected. Defaults to 0, no heartbeat.
+	StreamHeartbeat time.Duration `yaml:"StreamHeartbeat"`
+	// Locales with a translated template tree in a subdirectory of Directory, such as "templates/zh-CN". HTML
+	// negotiates them, and the locales of the message catalogs, with the Accept-Language header. A page or layout
+	// missing from a locale tree falls back to the tree of the primary language, such as "templates/zh", then to the
+	// default tree.
+	Locales []string `yaml:"Locales"`
+	// Directory of the message catalogs of the t and tn template funcs, one JSON file per locale such as
+	// "messages/de.json". Defaults to "", no catalog files.
+	MessagesDirectory string `yaml:"MessagesDirectory"`
+	// Message catalogs by locale, merged over the files of MessagesDirectory.
+	Catalogs map[string]Catalog `yaml:"-"`
+	// Key signing the cookie of RedirectWithData, which enables the flash template func. Defaults to none.
+	FlashSecret []byte `yaml:"FlashSecret"`
+	// Lifetime of the cookie of RedirectWithData, and of the one of the flash messages. Defaults to a minute.
+	FlashMaxAge time.Duration `yaml:"FlashMaxAge"`
+	// FlashStore keeps the messages of AddFlash, which the next HTML render of the client consumes for the flashes
+	// template func. Defaults to a cookie signed with FlashSecret when it is set.
+	FlashStore FlashStore `yaml:"-"`
+	// CSRFTokenFunc returns the token of the CSRF middleware for a request, such as csrf.Token of gorilla/csrf,
+	// which enables the csrf_token and csrf_field template funcs. Defaults to none.
+	CSRFTokenFunc func(r *http.Request) string `yaml:"-"`
+	// Name of the hidden input of csrf_field, the form field the CSRF middleware reads. Defaults to "csrf_token".
+	CSRFFieldName string `yaml:"CSRFFieldName"`
+	// ErrorHandler answers the renders which fail to marshal or execute, r is nil when the call was not given the
+	// request. Defaults to a plain text status message, without the error message out of debug mode.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error) `yaml:"-"`
+	// Features answers the feature template func, for the flags listed in FeatureAllowlist only.
+	Features FeatureProvider `yaml:"-"`
+	// Feature flags templates may read. Reading another one fails the execution.
+	FeatureAllowlist []string `yaml:"FeatureAllowlist"`
+	// Config answers the config template func, for the keys listed in ConfigAllowlist only.
+	Config ConfigProvider `yaml:"-"`
+	// Configuration keys templates may read. Reading another one fails the execution.
+	ConfigAllowlist []string `yaml:"ConfigAllowlist"`
+	// Directory of the template trees of the tenants, one subdirectory per tenant such as "tenants/acme", whose
+	// HTML templates override the shared ones for the renders of HTMLOptions.Tenant. Defaults to "", no tenants.
+	TenantDirectory string `yaml:"TenantDirectory"`
+	// Limits of the tenant template sets kept compiled, the least recently used ones are dropped beyond.
+	TenantCache TenantCache `yaml:"TenantCache"`
+	// Security headers set on the responses of the renders, instead of by a middleware which may run after the
+	// response is written.
+	SecurityHeaders SecurityHeaders `yaml:"SecurityHeaders"`
+	// Static files the asset template func returns the fingerprinted URL of, for far-future caching.
+	Assets Assets `yaml:"Assets"`
+	// Vite or webpack manifest the vite_asset, vite_entry_scripts and vite_entry_styles template funcs link to the
+	// built files of.
+	Vite Vite `yaml:"Vite"`
+	// SelectVariant returns the template rendered instead of the one of an HTML render, before Rollouts.
+	SelectVariant VariantSelector `yaml:"-"`
+	// Rollouts of variants of templates, by template name. They apply to the renders given HTMLOptions.Request.
+	Rollouts map[string]Rollout `yaml:"Rollouts"`
+	// Metrics receives an event for each render, with its format, template, status, size, duration and error.
+	Metrics Metrics `yaml:"-"`
+	// Tracer starts a span around each render, such as an adapter of OpenTelemetry.
+	Tracer Tracer `yaml:"-"`
+	// Logger receives the errors and debug lines of the package. Defaults to the standard logger, with the debug
+	// lines in debug mode only.
+	Logger Logger `yaml:"-"`
+	// FuncFactories are template funcs made for each HTML render from its Scope, to keep per request state out of
+	// FuncMap. The renders execute pooled clones of the templates, which get the funcs of the factories.
+	FuncFactories map[string]FuncFactory `yaml:"-"`
+}
+
+// JSONEncoder marshals values to JSON, an interface met by the encoding/json compatible APIs of libraries such as
+// jsoniter or sonic:
+//
+//	render.Init(render.Options{JSONEncoder: jsoniter.ConfigCompatibleWithStandardLibrary})
+type JSONEncoder interface {
+	Marshal(v interface{}) ([]byte, error)
+	MarshalIndent(v interface{}, prefix, indent string) ([]byte, error)
+}
+
+// standardJSON is the JSONEncoder of encoding/json
+type standardJSON struct{}
+
+func (standardJSON) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (standardJSON) MarshalIndent(v interface{}, prefix, indent string) ([]byte, error) {
+	return json.MarshalIndent(v, prefix, indent)
 }
 
 // HTMLOptions is a struct for overriding some rendering Options for specific HTML call
 type HTMLOptions struct {
-	// Layout template name. Overrides Options.Layout.
+	// Layout template name. Overrides Options.Layout, which applies with Options.Extends when none of Layout,
+	// Layouts and Extends is set, whatever the other fields are: HTMLOptions{} renders with the layout of Options.
 	Layout string
+	// Render without a layout nor a template to extend, whatever Layout, Layouts, Extends and the ones of Options are.
+	// Set it where an empty HTMLOptions{} used to render without the layout of Options.
+	NoLayout bool
+	// Data the layouts are executed with instead of the binding of the page.
+	LayoutData interface{}
+	// Nested layout template names, the outermost first, such as {"layouts/base", "layouts/admin"}: the page is
+	// rendered by the yield of the last layout, which is rendered by the yield of the one before. Overrides Layout.
+	Layouts []string
+	// Template the page extends, whose block actions the define actions of the page override. Used instead of
+	// Layout. Overrides Options.Extends.
+	Extends string
+	// Locale template tree to render from, such as "zh-CN". Negotiated among Options.Locales with the
+	// Accept-Language header of Request when empty.
+	Locale string
+	// The request being answered, needed for conditional responses.
+	Request *http.Request
+	// Response to Request, on which the flash cookies are cleared once the render showed them, when the render writes
+	// elsewhere such as HTMLString. Without it, HTMLString leaves the flash of RedirectWithData and the flash messages
+	// for the response of the request.
+	ResponseWriter http.ResponseWriter
+	// User the page is rendered for, given to Options.FuncFactories with the Scope.
+	User interface{}
+	// Tenant whose templates of Options.TenantDirectory are rendered, over the shared ones. Does not apply with
+	// Extends.
+	Tenant string
+	// Time zone the format funcs write times in. Defaults to the one of the request context, see WithTimeZone, or
+	// else the one of each time.
+	TimeZone *time.Location
+	// Generate an ETag even if Options.GenerateETags is false.
+	GenerateETag bool
+	// Do not generate an ETag even if Options.GenerateETags is true.
+	NoETag bool
+	// Send the body uncompressed, even if Options.Compression is enabled.
+	NoCompress bool
+	// Time each write of HTMLStream may take, the write deadline of the response being pushed back before each one
+	// so that a long stream outlives the WriteTimeout of the server. Defaults to Options.StreamWriteTimeout.
+	WriteTimeout time.Duration
+	// Content type replacing the default one, such as "application/xhtml+xml".
+	ContentType string
+	// Charset of the Content-Type header. Overrides Options.Charset.
+	Charset string
+	// Headers set on the response.
+	Header http.Header
+}
+
+// JSONOptions is a struct for overriding some rendering Options for specific JSON call
+type JSONOptions struct {
+	// The request being answered, needed for conditional responses.
+	Request *http.Request
+	// Generate an ETag even if Options.GenerateETags is false.
+	GenerateETag bool
+	// Do not generate an ETag even if Options.GenerateETags is true.
+	NoETag bool
+	// Send the body uncompressed, even if Options.Compression is enabled.
+	NoCompress bool
+	// Do not escape <, > and & in strings, like Options.JSONNoEscapeHTML.
+	NoEscapeHTML bool
+	// End the body with a newline, like Options.JSONTrailingNewline.
+	TrailingNewline bool
+	// Content type replacing the default one, such as "application/vnd.myco.v2+json".
+	ContentType string
+	// Charset of the Content-Type header. Overrides Options.Charset.
+	Charset string
+	// Headers set on the response.
+	Header http.Header
+}
+
+// XMLOptions is a struct for overriding some rendering Options for specific XML call
+type XMLOptions struct {
+	// The request being answered, needed for conditional responses.
+	Request *http.Request
+	// Generate an ETag even if Options.GenerateETags is false.
+	GenerateETag bool
+	// Do not generate an ETag even if Options.GenerateETags is true.
+	NoETag bool
+	// Send the body uncompressed, even if Options.Compression is enabled.
+	NoCompress bool
+	// Start the document with the XML declaration, like Options.XMLDeclaration.
+	Declaration bool
+	// Name of an element wrapping the value, which gives a root element to slices.
+	Root string
+	// Namespaces declared on the root element, by prefix. The prefix "" declares the default namespace.
+	Namespaces map[string]string
+	// Content type replacing the default one, such as "application/atom+xml".
+	ContentType string
+	// Charset of the Content-Type header. Overrides Options.Charset.
+	Charset string
+	// Headers set on the response.
+	Header http.Header
+}
+
+// TextOptions is a struct for overriding some rendering Options for specific Text call
+type TextOptions struct {
+	// Content type replacing the default one, such as "text/csv".
+	ContentType string
+	// Charset of the Content-Type header. Overrides Options.Charset.
+	Charset string
+	// Headers set on the response.
+	Header http.Header
+	// Encoder of the text for clients which do not take UTF-8, such as render.GSM7 for an SMS gateway. Its charset
+	// is the one of the Content-Type header unless Charset is set.
+	Encoder TextEncoder
+	// Maximum length of the body in bytes, the text is truncated to fit. Defaults to no limit.
+	MaxLength int
+}
+
+// ErrorOptions is a struct for specifying the request answered by an Error call
+type ErrorOptions struct {
+	// The request being answered, its Accept header selects the format of the message.
+	Request *http.Request
+}
+
+// callOptions are the per call options shared by the buffered renderers
+type callOptions struct {
+	request *http.Request
+	etag    bool
+	header  http.Header
+	// Send the body uncompressed, even if Options.Compression is enabled
+	noCompress bool
+	// JSON only
+	noEscapeHTML bool
+	newline      bool
 }
 
 // Init is a external rendering. An single variadic render.Options struct can be optionally provided to configure HTML
 // rendering. The default directory for templates is "templates" and the default file extension is ".tmpl".
 func Init(o Options) {
+	// Bomb out if parse fails. When the server starts.
+	if err := InitE(o); err != nil {
+		panic(err)
+	}
+}
+
+// InitE is Init returning the ParseErrors of the template files, or the FuncCollisions in strict mode, instead of
+// panicking. The templates which parsed are rendered nonetheless.
+func InitE(o Options) error {
+	stopWatcher()
 	render.options = prepareOptions(o)
-	render.template = createTemplate()
+	resetRedactTypes()
+	resetCompression()
+	resetRenderSlots()
+	resetGlobalData()
+	resetLocaleFormats()
+	resetShutdown()
 	render.buffer = helper.NewBufferPool(render.options.BufferPool)
+	loadedSet.Store(nil)
+	if render.options.DebugMode {
+		logDebug("render: running in debug mode, please do not use in production. Change to production mode in render.Options.")
+	}
+
+	err := loadTemplates()
+	resetHealth(err)
+	startWatcher()
+	if err != nil {
+		return err
+	}
+
+	collisions := funcCollisions(render.options, o.FuncFactories)
+	if len(collisions) > 0 && render.options.StrictFuncs {
+		return collisions
+	}
+	for _, collision := range collisions {
+		logError("render: template func collision", "err", collision.String())
+	}
+
+	return nil
 }
 
 func Render(o Options) {
@@ -124,14 +536,47 @@ func prepareCharset(charset string) string {
 	return "; charset=" + defaultCharset
 }
 
+// callContentType returns the Content-Type of a call: mediaType, or the override of the call, with the charset of
+// the call or Options.Charset
+func callContentType(mediaType, override, charset string) string {
+	if len(override) > 0 {
+		if strings.Contains(strings.ToLower(override), "charset=") {
+			return override
+		}
+		mediaType = override
+	}
+	if len(charset) == 0 {
+		charset = render.options.Charset
+	}
+
+	return mediaType + prepareCharset(charset)
+}
+
+// setHeader sets the headers of a call on the response, and the ones of Options.SecurityHeaders
+func setHeader(w http.ResponseWriter, header http.Header) {
+	for key, values := range header {
+		w.Header()[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+	}
+	setSecurityHeaders(w.Header())
+}
+
 func prepareOptions(options Options) Options {
 	// Defaults
+	if len(options.Directory) == 0 && len(options.Directories) > 0 {
+		options.Directory = options.Directories[len(options.Directories)-1]
+	}
 	if len(options.Directory) == 0 {
 		options.Directory = "templates"
 	}
+	if len(options.Directories) == 0 {
+		options.Directories = []string{options.Directory}
+	}
 	if len(options.Extensions) == 0 {
 		options.Extensions = []string{".tmpl"}
 	}
+	if len(options.TextExtensions) == 0 {
+		options.TextExtensions = []string{".txt.tmpl"}
+	}
 	if len(options.HTMLContentType) == 0 {
 		options.HTMLContentType = ContentHTML
 	}
@@ -139,57 +584,204 @@ func prepareOptions(options Options) Options {
 	if options.BufferPool == 0 {
 		options.BufferPool = 128
 	}
+	if options.ReloadInterval <= 0 {
+		options.ReloadInterval = defaultReloadInterval
+	}
+	if options.JSONEncoder == nil {
+		options.JSONEncoder = standardJSON{}
+	}
+
+	options.Compression = prepareCompression(options.Compression)
+	options.TenantCache = prepareTenantCache(options.TenantCache)
+
+	if options.FlashMaxAge <= 0 {
+		options.FlashMaxAge = time.Minute
+	}
+	if options.FlashStore == nil && len(options.FlashSecret) > 0 {
+		options.FlashStore = cookieFlashStore{}
+	}
+	if len(options.CSRFFieldName) == 0 {
+		options.CSRFFieldName = defaultCSRFFieldName
+	}
+
+	// the translation, flash, CSRF and format funcs are made for the locale and the request of each render
+	factories := map[string]FuncFactory{}
+	if len(options.MessagesDirectory) > 0 || len(options.Catalogs) > 0 {
+		for name, factory := range translationFactories {
+			factories[name] = factory
+		}
+	}
+	if len(options.FlashSecret) > 0 {
+		factories["flash"] = flashFactory
+	}
+	if options.FlashStore != nil {
+		factories["flashes"] = flashesFactory
+	}
+	if options.ContextData != nil {
+		factories["context"] = contextFactory
+	}
+	if options.CSRFTokenFunc != nil {
+		for name, factory := range csrfFactories {
+			factories[name] = factory
+		}
+	}
+	if options.EnableFormatFuncs {
+		for name, factory := range formatFactories {
+			factories[name] = factory
+		}
+	}
+	if len(factories) > 0 {
+		for name, factory := range options.FuncFactories {
+			factories[name] = factory
+		}
+		options.FuncFactories = factories
+	}
 
 	return options
 }
 
-func createTemplate() *template.Template {
+// loadTemplates parses the template files. When some fail to parse, the templates loaded before are kept, if any.
+func loadTemplates() error {
+	stamps := snapshotFiles()
+	t, text, mustache, engines, sources, manifest, parseErrors := createTemplate()
+	catalogs, catalogErrors := loadCatalogs()
+	assets, assetErrors := loadAssets()
+	vite, viteErrors := loadViteManifest()
+	parseErrors = append(append(append(parseErrors, catalogErrors...), assetErrors...), viteErrors...)
+	if len(parseErrors) > 0 && loadedSet.Load() != nil {
+		return parseErrors
+	}
+
+	loadedSet.Store(&templateSet{
+		template:   template.Must(t.Clone()),
+		pristine:   t,
+		clones:     newTemplatePool(t),
+		sources:    sources,
+		text:       text,
+		mustache:   mustache,
+		engines:    engines,
+		catalogs:   catalogs,
+		locales:    negotiableLocales(catalogs),
+		manifest:   manifest,
+		assets:     assets,
+		assetFiles: assetFiles(assets),
+		vite:       vite,
+	})
+	watchedFiles = stamps
+	resetInheritedSets()
+	resetTenantSets()
+
+	if len(parseErrors) > 0 {
+		return parseErrors
+	}
+
+	return nil
+}
+
+func createTemplate() (*template.Template, *texttemplate.Template, map[string]*mustacheTemplate, map[string]engineTemplate, map[string]string, []TemplateInfo, ParseErrors) {
 	dir := render.options.Directory
 
 	t := template.New(dir)
 	t.Delims(render.options.Delimiter.Left, render.options.Delimiter.Right)
+	for _, funcs := range htmlFuncMaps() {
+		t.Funcs(funcs)
+	}
 
-	// check template file error
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		relativePath, err := filepath.Rel(dir, path)
-		if err != nil {
-			return err
-		}
+	text := texttemplate.New(dir)
+	text.Delims(render.options.Delimiter.Left, render.options.Delimiter.Right)
+	for _, funcs := range textFuncMaps() {
+		text.Funcs(funcs)
+	}
 
-		ext := getExt(relativePath)
+	// Markdown templates are text templates
+	textExtensions := append(append([]string{}, render.options.TextExtensions...), render.options.MarkdownExtensions...)
+	var files []templateFile
+	var err error
+	if render.options.Loader != nil {
+		files = loaderFiles(render.options.Loader, textExtensions)
+	} else if len(precompiled) > 0 {
+		files = precompiledFiles(textExtensions)
+	} else {
+		files, err = walkTemplateFiles(textExtensions)
+	}
 
-		for _, extension := range render.options.Extensions {
-			if ext == extension {
+	if err != nil {
+		logError("render: filepath.Walk", "err", err)
+	}
 
-				buf, err := ioutil.ReadFile(path)
-				if err != nil {
-					panic(err)
+	mustache := map[string]*mustacheTemplate{}
+	sources := map[string]string{}
+	var infos []TemplateInfo
+	var parseErrors ParseErrors
+	// the files are parsed in parallel, then added in walk order for the last definition of a template to win
+	files = parseFiles(files)
+	for _, file := range files {
+		if file.err == nil && !file.engine {
+			if file.mustacheTemplate != nil {
+				mustache[file.name] = file.mustacheTemplate
+			} else if file.text {
+				for _, tree := range file.trees {
+					_, file.err = text.AddParseTree(tree.Name, tree)
+				}
+			} else {
+				sources[file.name] = file.source
+				for _, tree := range file.trees {
+					_, file.err = t.AddParseTree(tree.Name, tree)
 				}
+			}
+		}
+		if file.err == nil {
+			file.err = runTemplateHooks(file.trees)
+		}
+		if file.err != nil {
+			parseErrors = append(parseErrors, newParseError(file.path, file.name, file.err, file.source))
+			continue
+		}
+		infos = append(infos, templateInfo(file))
+	}
 
-				name := relativePath[0 : len(relativePath)-len(ext)]
-				tmpl := t.New(filepath.ToSlash(name))
+	engines, engineErrors := parseEngineFiles(files)
 
-				tmpl.Funcs(render.options.FuncMap)
+	return t, text, mustache, engines, sources, mergeManifest(nil, infos), append(parseErrors, engineErrors...)
+}
 
-				// Bomb out if parse fails. When the server starts.
-				template.Must(tmpl.Funcs(helperFuncs).Parse(string(buf)))
-				break
-			}
-		}
+// htmlFuncMaps returns the funcs of the HTML templates, the later maps overriding the former
+func htmlFuncMaps() []template.FuncMap {
+	funcs := []template.FuncMap{scopedFuncs(), providerFuncs, markdownFuncs, stateFuncs, assetFuncs, viteFuncs, sanitizeFuncs, globalFuncs, factoryPlaceholders()}
+	if render.options.EnableHelperFuncs {
+		funcs = append(funcs, libraryFuncs())
+	}
 
-		return nil
-	})
+	return append(funcs, render.options.FuncMap, helperFuncs)
+}
 
-	if err != nil {
-		message := fmt.Sprintf("render filepath.Walk: %s", err.Error())
-		if logger.Initialized() {
-			logger.Error(message)
-		} else {
-			logger.DefaultConsoleLogger().Error(message)
+// textFuncMaps returns the funcs of the text templates, the later maps overriding the former
+func textFuncMaps() []texttemplate.FuncMap {
+	funcs := []texttemplate.FuncMap{scopedFuncs(), providerFuncs, texttemplate.FuncMap(assetFuncs), texttemplate.FuncMap(globalFuncs)}
+	if render.options.EnableHelperFuncs {
+		funcs = append(funcs, libraryFuncs())
+	}
+
+	return append(funcs, texttemplate.FuncMap(render.options.FuncMap))
+}
+
+// templateName returns the name of the template file at relativePath, its slash-separated path without its extension
+// ext, the directory of Options.PartialPrefixes replaced by its prefix
+func templateName(relativePath, ext string) string {
+	name := filepath.ToSlash(relativePath[0 : len(relativePath)-len(ext)])
+
+	dir, prefix := "", ""
+	for d, p := range render.options.PartialPrefixes {
+		d = strings.TrimSuffix(filepath.ToSlash(d), "/") + "/"
+		if strings.HasPrefix(name, d) && len(d) > len(dir) {
+			dir, prefix = d, p
 		}
 	}
+	if len(dir) == 0 {
+		return name
+	}
 
-	return t
+	return prefix + name[len(dir):]
 }
 
 func getExt(s string) string {
@@ -199,97 +791,439 @@ func getExt(s string) string {
 	return "." + strings.Join(strings.Split(s, ".")[1:], ".")
 }
 
-func JSON(w http.ResponseWriter, status int, v interface{}) {
+func JSON(w http.ResponseWriter, status int, v interface{}, jsonOptions ...JSONOptions) {
+	if err := JSONE(w, status, v, jsonOptions...); err != nil {
+		renderError(w, prepareJSONOptions(jsonOptions).Request, err)
+	}
+}
+
+// JSONE is JSON returning the marshal error instead of answering it with a 500, nothing is written then
+func JSONE(w http.ResponseWriter, status int, v interface{}, jsonOptions ...JSONOptions) (err error) {
+	// already serialized
+	if raw, ok := v.(json.RawMessage); ok {
+		return RawJSONE(w, status, raw, jsonOptions...)
+	}
+
+	w, done := observeRender(w, prepareJSONOptions(jsonOptions).Request, FormatJSON, "")
+	defer done(&err)
+
+	option := prepareJSONOptions(jsonOptions)
+
+	return renderJSON(w, status, callContentType(ContentJSON, option.ContentType, option.Charset), v, jsonCall(option))
+}
+
+func renderJSON(w http.ResponseWriter, status int, contentType string, v interface{}, call callOptions) error {
+	buf := render.buffer.Get()
+	// Set buffer in BufferPool
+	defer render.buffer.Set(buf)
+
+	if err := encodeJSON(limitWriter(buf), v, call); err != nil {
+		return err
+	}
+	result := buf.Bytes()
+	var newline []byte
+	if call.newline {
+		newline = []byte{'\n'}
+	}
+	// the prefix and the newline count in the limit of the body
+	prefix := hijackPrefix(result)
+	if err := checkSize(len(prefix) + len(result) + len(newline)); err != nil {
+		return err
+	}
+
+	// json rendered fine, write out the result
+	return writeResponse(w, status, contentType, call, prefix, result, newline)
+}
+
+// jsonCall merges the options of a JSON call with Options
+func jsonCall(option JSONOptions) callOptions {
+	return callOptions{
+		request:      option.Request,
+		etag:         etagEnabled(option.GenerateETag, option.NoETag),
+		header:       option.Header,
+		noCompress:   option.NoCompress,
+		noEscapeHTML: option.NoEscapeHTML || render.options.JSONNoEscapeHTML,
+		newline:      option.TrailingNewline || render.options.JSONTrailingNewline,
+	}
+}
+
+// RawJSON writes b, which already holds serialized JSON, as is. b is checked to be well-formed when
+// Options.ValidateRawJSON is true.
+func RawJSON(w http.ResponseWriter, status int, b []byte, jsonOptions ...JSONOptions) {
+	if err := RawJSONE(w, status, b, jsonOptions...); err != nil {
+		renderError(w, prepareJSONOptions(jsonOptions).Request, err)
+	}
+}
+
+// RawJSONE is RawJSON returning the error instead of answering it with a 500, nothing is written then
+func RawJSONE(w http.ResponseWriter, status int, b []byte, jsonOptions ...JSONOptions) (err error) {
+	w, done := observeRender(w, prepareJSONOptions(jsonOptions).Request, FormatJSON, "")
+	defer done(&err)
+
+	option := prepareJSONOptions(jsonOptions)
+	call := jsonCall(option)
+
+	prefix := hijackPrefix(b)
+	err = checkSize(len(prefix) + len(b))
+	if err == nil && render.options.ValidateRawJSON && !json.Valid(b) {
+		err = errInvalidJSON
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeResponse(w, status, callContentType(ContentJSON, option.ContentType, option.Charset), call, prefix, b)
+}
+
+// jsonEncoder returns Options.JSONEncoder, encoding/json before Init
+func jsonEncoder() JSONEncoder {
+	if render.options.JSONEncoder == nil {
+		return standardJSON{}
+	}
+
+	return render.options.JSONEncoder
+}
+
+// encodeJSON writes v redacted to w, which is limited to Options.MaxResponseBytes. encoding/json encodes it straight
+// into w, the other JSONEncoders marshal it first.
+func encodeJSON(w io.Writer, v interface{}, call callOptions) error {
+	v = redact(v)
+
+	encoder := jsonEncoder()
+	if _, ok := encoder.(standardJSON); ok {
+		e := json.NewEncoder(jsonEncoderOutput{w})
+		e.SetEscapeHTML(!call.noEscapeHTML)
+		if render.options.IndentJSON {
+			e.SetIndent("", "  ")
+		}
+		return e.Encode(v)
+	}
+
 	var result []byte
 	var err error
 	if render.options.IndentJSON {
-		result, err = json.MarshalIndent(v, "", "  ")
+		result, err = encoder.MarshalIndent(v, "", "  ")
 	} else {
-		result, err = json.Marshal(v)
+		result, err = encoder.Marshal(v)
 	}
 	if err != nil {
-		http.Error(w, err.Error(), 500)
-		return
+		return err
 	}
+	if call.noEscapeHTML {
+		result = unescapeJSONHTML(result)
+	}
+	_, err = w.Write(result)
 
-	// json rendered fine, write out the result
-	w.Header().Set(ContentType, ContentJSON+prepareCharset(render.options.Charset))
-	w.WriteHeader(status)
-	if len(render.options.PrefixJSON) > 0 {
-		w.Write(render.options.PrefixJSON)
+	return err
+}
+
+// jsonEncoderOutput drops the newline json.Encoder ends the document with in its single write,
+// Options.JSONTrailingNewline adding one when wanted
+type jsonEncoderOutput struct {
+	w io.Writer
+}
+
+func (j jsonEncoderOutput) Write(p []byte) (int, error) {
+	if _, err := j.w.Write(bytes.TrimSuffix(p, []byte{'\n'})); err != nil {
+		return 0, err
 	}
-	w.Write(result)
+
+	return len(p), nil
+}
+
+// unescapeJSONHTML reverts the escaping of <, > and & in the strings of the JSON document b, as done by
+// json.Encoder.SetEscapeHTML(false). It works with any JSONEncoder.
+func unescapeJSONHTML(b []byte) []byte {
+	if !bytes.Contains(b, []byte(`\u00`)) {
+		return b
+	}
+
+	result := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		if b[i] != '\\' || i+1 == len(b) {
+			result = append(result, b[i])
+			continue
+		}
+
+		if b[i+1] == 'u' && i+6 <= len(b) {
+			switch string(b[i+2 : i+6]) {
+			case "003c", "003C":
+				result = append(result, '<')
+				i += 5
+				continue
+			case "003e", "003E":
+				result = append(result, '>')
+				i += 5
+				continue
+			case "0026":
+				result = append(result, '&')
+				i += 5
+				continue
+			}
+		}
+
+		// keep any other escape sequence, including an escaped backslash
+		result = append(result, b[i], b[i+1])
+		i++
+	}
+
+	return result
 }
 
 func HTML(w http.ResponseWriter, status int, name string, binding interface{}, htmlOptions ...HTMLOptions) {
-	if render.options.DebugMode {
-		logger.Debug("You are running in debug mode, please do not use in production. Change to production mode in render.Options.")
-		render.template = createTemplate()
+	if err := HTMLE(w, status, name, binding, htmlOptions...); err != nil {
+		renderError(w, prepareHTMLOptions(htmlOptions).Request, err)
 	}
+}
+
+// HTMLE is HTML returning the template error instead of answering it with a 500, nothing is written then. An
+// ErrOverloaded error is returned when Options.MaxConcurrentRenders is reached.
+func HTMLE(w http.ResponseWriter, status int, name string, binding interface{}, htmlOptions ...HTMLOptions) (err error) {
+	w, done := observeRender(w, prepareHTMLOptions(htmlOptions).Request, FormatHTML, name)
+	defer done(&err)
+
 	option := prepareHTMLOptions(htmlOptions)
-	// assign a layout if there is one
-	if len(option.Layout) > 0 {
-		addYield(name, binding)
-		name = option.Layout
+	defer startTrace(w, option.Request, name)()
+	buf, err := renderHTML(w, name, binding, option)
+	if buf != nil {
+		defer buf.release()
+	}
+	if err == ErrForbidden {
+		return renderForbidden(w, name, option)
 	}
-
-	buf, err := execute(name, binding)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return err
+	}
+
+	call := callOptions{
+		request:    option.Request,
+		etag:       etagEnabled(option.GenerateETag, option.NoETag),
+		header:     option.Header,
+		noCompress: option.NoCompress,
 	}
 
 	// template rendered fine, write out the result
-	w.Header().Set(ContentType, render.options.HTMLContentType+prepareCharset(render.options.Charset))
-	w.WriteHeader(status)
-	io.Copy(w, buf)
-	// Set buffer in BufferPool
-	render.buffer.Set(buf)
+	return writeRendered(w, status, callContentType(render.options.HTMLContentType, option.ContentType, option.Charset), call, buf)
 }
 
-func XML(w http.ResponseWriter, status int, v interface{}) {
-	var result []byte
-	var err error
-	if render.options.IndentXML {
-		result, err = xml.MarshalIndent(v, "", "  ")
+// renderHTML executes the template name with its layouts, setting the headers of the locale and of the flash
+// message into w. The output is nil when the template could not be executed, ErrForbidden when its guards deny it.
+func renderHTML(w http.ResponseWriter, name string, binding interface{}, option HTMLOptions) (*spoolBuffer, error) {
+	if err := refresh(); err != nil {
+		return nil, err
+	}
+	if err := checkGuards(option.Request, name); err != nil {
+		return nil, err
+	}
+	name = selectVariant(w, option.Request, name)
+	if err := checkBinding(name, binding); err != nil {
+		return nil, err
+	}
+	release, err := acquireRender(option.Request)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// the render reads the templates of a single load, whatever reloads meanwhile
+	set := loaded()
+	locale := prepareLocale(w, option)
+	name = localizeTemplate(name, locale)
+	option.Request = withContextData(clearFlash(flashWriter(w, option), option.Request))
+	binding = mergeData(option.Request, binding)
+
+	// the templates of Options.Engines are rendered within the html/template layouts
+	if engine, ok := set.engines[name]; ok && !definedTemplate(name) {
+		t, releaseTemplate, err := scopedTemplate(set, option, locale)
+		if err != nil {
+			return nil, err
+		}
+		defer releaseTemplate()
+		return executeLayoutsWith(t, binding, layoutData(option, binding), layoutChain(option, locale), name, func(out io.Writer) error {
+			return engine.templates.Execute(out, engine.path, binding)
+		})
+	}
+
+	var t *template.Template
+	var releaseTemplate func()
+	if len(option.Extends) > 0 {
+		parent := localizeTemplate(option.Extends, locale)
+		t, releaseTemplate, err = inheritedTemplate(set, parent, name, option, locale)
+		name = parent
 	} else {
-		result, err = xml.Marshal(v)
+		t, releaseTemplate, err = scopedTemplate(set, option, locale)
 	}
 	if err != nil {
-		http.Error(w, err.Error(), 500)
-		return
+		return nil, err
+	}
+	defer releaseTemplate()
+
+	var layouts []string
+	if len(option.Extends) == 0 {
+		layouts = layoutChain(option, locale)
+	}
+
+	return executeLayouts(t, binding, layoutData(option, binding), layouts, name)
+}
+
+// Fragment renders the template name without a layout nor a template to extend, whatever the ones of Options and
+// HTMLOptions are. Suited to partial page updates of AJAX and htmx requests.
+func Fragment(w http.ResponseWriter, status int, name string, binding interface{}, htmlOptions ...HTMLOptions) {
+	option := prepareHTMLOptions(htmlOptions)
+	option.NoLayout = true
+
+	HTML(w, status, name, binding, option)
+}
+
+func XML(w http.ResponseWriter, status int, v interface{}, xmlOptions ...XMLOptions) {
+	if err := XMLE(w, status, v, xmlOptions...); err != nil {
+		renderError(w, prepareXMLOptions(xmlOptions).Request, err)
+	}
+}
+
+// XMLE is XML returning the marshal error instead of answering it with a 500, nothing is written then
+func XMLE(w http.ResponseWriter, status int, v interface{}, xmlOptions ...XMLOptions) (err error) {
+	w, done := observeRender(w, prepareXMLOptions(xmlOptions).Request, FormatXML, "")
+	defer done(&err)
+
+	option := prepareXMLOptions(xmlOptions)
+
+	buf := render.buffer.Get()
+	// Set buffer in BufferPool
+	defer render.buffer.Set(buf)
+
+	// the declaration and the prefix count in the limit of the body
+	out := limitWriter(buf)
+	if option.Declaration || render.options.XMLDeclaration {
+		if _, err := out.Write(xmlDeclaration()); err != nil {
+			return err
+		}
+	}
+	if _, err := out.Write(render.options.PrefixXML); err != nil {
+		return err
+	}
+	head := buf.Len()
+	if err := encodeXML(out, redact(v), option); err != nil {
+		return err
+	}
+	body := buf.Bytes()
+	if len(option.Namespaces) > 0 {
+		body = append(body[:head:head], declareXMLNamespaces(body[head:], option.Namespaces)...)
+		if err := checkSize(len(body)); err != nil {
+			return err
+		}
+	}
+
+	call := callOptions{
+		request:    option.Request,
+		etag:       etagEnabled(option.GenerateETag, option.NoETag),
+		header:     option.Header,
+		noCompress: option.NoCompress,
 	}
 
 	// XML rendered fine, write out the result
-	w.Header().Set(ContentType, ContentXML+prepareCharset(render.options.Charset))
-	w.WriteHeader(status)
-	if len(render.options.PrefixXML) > 0 {
-		w.Write(render.options.PrefixXML)
+	return writeResponse(w, status, callContentType(ContentXML, option.ContentType, option.Charset), call, body)
+}
+
+// Gob writes v encoded with encoding/gob, for Go clients. Interface values have to be registered with gob.Register.
+func Gob(w http.ResponseWriter, status int, v interface{}) {
+	if err := GobE(w, status, v); err != nil {
+		renderError(w, nil, err)
 	}
-	w.Write(result)
+}
+
+// GobE is Gob returning the encoding error instead of answering it with a 500, nothing is written then
+func GobE(w http.ResponseWriter, status int, v interface{}) (err error) {
+	w, done := observeRender(w, nil, FormatGob, "")
+	defer done(&err)
+
+	buf := render.buffer.Get()
+	// Set buffer in BufferPool
+	defer render.buffer.Set(buf)
+
+	err = gob.NewEncoder(limitWriter(buf)).Encode(redact(v))
+	if err != nil {
+		return err
+	}
+
+	// gob rendered fine, write out the result
+	return writeResponse(w, status, ContentGob, callOptions{etag: render.options.GenerateETags}, buf.Bytes())
 }
 
 func Data(w http.ResponseWriter, status int, v []byte) {
 	if w.Header().Get(ContentType) == "" {
 		w.Header().Set(ContentType, ContentBinary)
 	}
+	setSecurityHeaders(w.Header())
 	w.WriteHeader(status)
 	w.Write(v)
 }
 
-func Text(w http.ResponseWriter, status int, v string) {
-	if w.Header().Get(ContentType) == "" {
-		w.Header().Set(ContentType, ContentText+prepareCharset(render.options.Charset))
+func Text(w http.ResponseWriter, status int, v string, textOptions ...TextOptions) {
+	w, done := observeRender(w, nil, FormatText, "")
+	defer done(nil)
+
+	writeText(w, status, v, prepareTextOptions(textOptions))
+}
+
+// writeText writes the text v as Text does
+func writeText(w http.ResponseWriter, status int, v string, option TextOptions) {
+	charset := option.Charset
+	if option.Encoder != nil && len(charset) == 0 {
+		charset = option.Encoder.Charset()
+	}
+
+	setHeader(w, option.Header)
+	if w.Header().Get(ContentType) == "" || len(option.ContentType) > 0 || len(charset) > 0 {
+		w.Header().Set(ContentType, callContentType(ContentText, option.ContentType, charset))
 	}
 	w.WriteHeader(status)
-	w.Write([]byte(v))
+	if option.Encoder == nil && option.MaxLength <= 0 {
+		w.Write([]byte(v))
+		return
+	}
+	w.Write(encodeText(v, option.Encoder, option.MaxLength))
 }
 
-// Error writes the given HTTP status to the current ResponseWriter
-func Error(w http.ResponseWriter, status int, v []byte) {
+// Error writes the given HTTP status to the current ResponseWriter, with the message v as text/plain, or as a JSON
+// document {"status": status, "message": v} when the Accept header of ErrorOptions.Request prefers JSON. An empty
+// message is replaced by the status text.
+func Error(w http.ResponseWriter, status int, v []byte, errorOptions ...ErrorOptions) {
+	option := prepareErrorOptions(errorOptions)
+
+	message := string(v)
+	if len(message) == 0 {
+		message = http.StatusText(status)
+	}
+
+	accept := ""
+	if option.Request != nil {
+		accept = option.Request.Header.Get("Accept")
+	}
+
+	w.Header().Del(ContentLength)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	setSecurityHeaders(w.Header())
+	addVary(w.Header(), "Accept")
+
+	if negotiate.Type(accept, ContentText, ContentJSON) == ContentJSON {
+		result, err := json.Marshal(errorDocument{Status: status, Message: message})
+		if err == nil {
+			w.Header().Set(ContentType, ContentJSON+prepareCharset(render.options.Charset))
+			w.WriteHeader(status)
+			w.Write(result)
+			return
+		}
+	}
+
+	w.Header().Set(ContentType, ContentText+prepareCharset(render.options.Charset))
 	w.WriteHeader(status)
-	w.Write(v)
+	w.Write([]byte(message))
+}
 
+type errorDocument struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
 }
 
 func Status(w http.ResponseWriter, status int) {
@@ -302,40 +1236,210 @@ func Redirect(w http.ResponseWriter, r *http.Request, status int, location strin
 		code = status
 	}
 
+	setSecurityHeaders(w.Header())
 	http.Redirect(w, r, location, code)
 }
 
+// refresh returns the error of the last reload of the debug mode watcher, for the renders to show it
+func refresh() error {
+	if render.options.DebugMode {
+		return staleError(watchError())
+	}
+
+	return nil
+}
+
 func Template() *template.Template {
-	return render.template
+	return loaded().template
+}
+
+// TextTemplate returns the text/template set parsed from the files with Options.TextExtensions
+func TextTemplate() *texttemplate.Template {
+	return loaded().text
 }
 
-func execute(name string, binding interface{}) (*bytes.Buffer, error) {
+func execute(t *template.Template, name string, binding interface{}) (*bytes.Buffer, error) {
 	// Get buffer in BufferPool
 	buf := render.buffer.Get()
 
-	return buf, render.template.ExecuteTemplate(buf, name, binding)
+	return buf, t.ExecuteTemplate(limitWriter(buf), name, binding)
+}
+
+// writeResponse writes the headers and the body parts of a buffered render. Nothing is written when it fails.
+func writeResponse(w http.ResponseWriter, status int, contentType string, call callOptions, body ...[]byte) error {
+	coding := negotiateCoding(w, contentType, call, body)
+	if len(coding) > 0 {
+		traceEvent(call.request, TraceEvent{Kind: TraceCache, Name: "compression", Detail: coding})
+	}
+
+	etag := ""
+	if call.etag && status >= 200 && status < 300 {
+		etag = computeETag(body...)
+		if len(coding) > 0 {
+			etag = codingETag(etag, coding)
+		}
+
+		if status == http.StatusOK && notModified(call.request, etag) {
+			traceEvent(call.request, TraceEvent{Kind: TraceCache, Name: "etag", Detail: "not modified"})
+			setHeader(w, call.header)
+			w.Header().Set(ETag, etag)
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+	}
+
+	if len(coding) > 0 {
+		buf, err := compressBody(coding, body)
+		if err != nil {
+			return err
+		}
+		// Set buffer in BufferPool
+		defer render.buffer.Set(buf)
+
+		body = [][]byte{buf.Bytes()}
+		w.Header().Set(ContentEncoding, coding)
+		w.Header().Set(ContentLength, strconv.Itoa(buf.Len()))
+	}
+
+	setHeader(w, call.header)
+	if len(etag) > 0 {
+		w.Header().Set(ETag, etag)
+	}
+	w.Header().Set(ContentType, contentType)
+	w.WriteHeader(status)
+	for _, b := range body {
+		if len(b) > 0 {
+			w.Write(b)
+		}
+	}
+
+	return nil
+}
+
+// renderError answers a failed render with Options.ErrorHandler, or else with the status text, replaced by the error
+// message in debug mode
+func renderError(w http.ResponseWriter, r *http.Request, err error) {
+	if render.options.ErrorHandler != nil {
+		render.options.ErrorHandler(w, r, err)
+		return
+	}
+
+	if m, ok := w.(*metricsWriter); ok {
+		m.err = err
+	}
+
+	status := ErrorStatus(err)
+	logError("render: error answered", "status", status, "err", err)
+
+	if render.options.DebugMode && writeDiagnostic(w, r, status, err) {
+		return
+	}
+
+	message := http.StatusText(status)
+	if render.options.DebugMode {
+		message = err.Error()
+	}
+	setSecurityHeaders(w.Header())
+	http.Error(w, message, status)
 }
 
-func addYield(name string, binding interface{}) {
-	funcs := template.FuncMap{
-		"yield": func() (template.HTML, error) {
-			buf, err := execute(name, binding)
-			// return safe html here since we are rendering our own template
-			return template.HTML(buf.String()), err
-		},
-		"current": func() (string, error) {
-			return name, nil
-		},
+// ErrorStatus returns the status a render error is answered with, 503 Service Unavailable for ErrOverloaded and
+// ErrShuttingDown, 403 Forbidden for ErrForbidden, 409 Conflict for ErrIdempotencyKeyInUse, 500 Internal Server
+// Error otherwise
+func ErrorStatus(err error) int {
+	if errors.Is(err, ErrOverloaded) || errors.Is(err, ErrShuttingDown) {
+		return http.StatusServiceUnavailable
 	}
-	render.template.Funcs(funcs)
+	if errors.Is(err, ErrForbidden) {
+		return http.StatusForbidden
+	}
+	if errors.Is(err, ErrIdempotencyKeyInUse) {
+		return http.StatusConflict
+	}
+
+	return http.StatusInternalServerError
 }
 
+func checkSize(n int) error {
+	if render.options.MaxResponseBytes > 0 && int64(n) > render.options.MaxResponseBytes {
+		return ErrResponseTooLarge
+	}
+
+	return nil
+}
+
+// limitedWriter fails with ErrResponseTooLarge once more than n bytes are written
+type limitedWriter struct {
+	w io.Writer
+	n int64
+}
+
+func limitWriter(w io.Writer) io.Writer {
+	if render.options.MaxResponseBytes <= 0 {
+		return w
+	}
+
+	return &limitedWriter{w: w, n: render.options.MaxResponseBytes}
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > l.n {
+		return 0, ErrResponseTooLarge
+	}
+	l.n -= int64(len(p))
+
+	return l.w.Write(p)
+}
+
+func prepareJSONOptions(jsonOptions []JSONOptions) JSONOptions {
+	if len(jsonOptions) > 0 {
+		return jsonOptions[0]
+	}
+
+	return JSONOptions{}
+}
+
+func prepareXMLOptions(xmlOptions []XMLOptions) XMLOptions {
+	if len(xmlOptions) > 0 {
+		return xmlOptions[0]
+	}
+
+	return XMLOptions{}
+}
+
+func prepareTextOptions(textOptions []TextOptions) TextOptions {
+	if len(textOptions) > 0 {
+		return textOptions[0]
+	}
+
+	return TextOptions{}
+}
+
+func prepareErrorOptions(errorOptions []ErrorOptions) ErrorOptions {
+	if len(errorOptions) > 0 {
+		return errorOptions[0]
+	}
+
+	return ErrorOptions{}
+}
+
+// prepareHTMLOptions returns the options of an HTML call, with Options.Layout and Options.Extends unless they name
+// layouts or a template to extend themselves
 func prepareHTMLOptions(htmlOptions []HTMLOptions) HTMLOptions {
+	var option HTMLOptions
 	if len(htmlOptions) > 0 {
-		return htmlOptions[0]
+		option = htmlOptions[0]
 	}
 
-	return HTMLOptions{
-		Layout: render.options.Layout,
+	if len(option.Layout) == 0 && len(option.Layouts) == 0 && len(option.Extends) == 0 {
+		option.Layout = render.options.Layout
+		option.Extends = render.options.Extends
 	}
+	if option.NoLayout {
+		option.Layout = ""
+		option.Layouts = nil
+		option.Extends = ""
+	}
+
+	return option
 }
@@ -0,0 +1,93 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// initTemplates writes the template files to a temporary directory and initializes the package with it
+func initTemplates(t testing.TB, files map[string]string, options Options) {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, source := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	options.Directory = dir
+	if err := InitE(options); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMaxResponseBytesOfTheWholeBody(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		size   int
+		render func(w http.ResponseWriter) error
+	}{
+		{"JSON with its hijack prefix and newline", len(")]}',\n[1,2]\n"), func(w http.ResponseWriter) error {
+			return JSONE(w, 200, []int{1, 2}, JSONOptions{TrailingNewline: true})
+		}},
+		{"XML with its declaration and namespaces", len(xmlDeclaration()) + len(`<int xmlns="urn:n">1</int>`), func(w http.ResponseWriter) error {
+			return XMLE(w, 200, 1, XMLOptions{Declaration: true, Namespaces: map[string]string{"": "urn:n"}})
+		}},
+		{"HTML with its layout", len("<main>page</main>"), func(w http.ResponseWriter) error {
+			return HTMLE(w, 200, "page", nil, HTMLOptions{Layout: "layout"})
+		}},
+	} {
+		for _, limit := range []int{c.size - 1, c.size} {
+			initTemplates(t, map[string]string{
+				"page.tmpl":   "page",
+				"layout.tmpl": "<main>{{yield}}</main>",
+			}, Options{MaxResponseBytes: int64(limit), JSONHijackProtection: HijackAngular})
+
+			w := httptest.NewRecorder()
+			err := c.render(w)
+			if limit < c.size && err != ErrResponseTooLarge {
+				t.Errorf("%s over the limit: got %v", c.name, err)
+			}
+			if limit == c.size && (err != nil || w.Body.Len() != c.size) {
+				t.Errorf("%s within the limit: got %v, %q", c.name, err, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestMarkdownAndMustacheAreOptIn(t *testing.T) {
+	initTemplates(t, map[string]string{
+		"index.tmpl":        "index",
+		"README.md":         "Write {{ .Name }} or {{ to call a func",
+		"mail/tpl.mustache": "{{#unclosed}}",
+	}, Options{})
+
+	w := httptest.NewRecorder()
+	if err := HTMLE(w, 200, "index", nil); err != nil || w.Body.String() != "index" {
+		t.Errorf("got %v, %q", err, w.Body.String())
+	}
+	if err := MarkdownE(httptest.NewRecorder(), 200, "README", nil); err == nil {
+		t.Error("README.md was parsed without Options.MarkdownExtensions")
+	}
+}
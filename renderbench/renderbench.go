@@ -0,0 +1,239 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+// Package renderbench provides reusable benchmark scenarios for render, so that configurations can be compared
+// with the same workloads:
+//
+//	func BenchmarkRender(b *testing.B) {
+//		renderbench.RunAll(b, render.Options{IndentJSON: true})
+//	}
+package renderbench
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ronzxy/go-render"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Scenario is a benchmarked render call
+type Scenario struct {
+	Name string
+	// Template files written to a temporary Options.Directory, by path relative to the directory
+	Templates map[string]string
+	// Layout set to Options.Layout when Templates are given
+	Layout string
+	// Render writes one response
+	Render func(w http.ResponseWriter)
+}
+
+type record struct {
+	ID      int               `json:"id" xml:"id"`
+	Name    string            `json:"name" xml:"name"`
+	Email   string            `json:"email" xml:"email"`
+	Active  bool              `json:"active" xml:"active"`
+	Score   float64           `json:"score" xml:"score"`
+	Tags    []string          `json:"tags" xml:"tags"`
+	Created time.Time         `json:"created" xml:"created"`
+	Attrs   map[string]string `json:"attrs" xml:"-"`
+}
+
+var (
+	records     []record
+	recordsOnce sync.Once
+)
+
+// Records shared by the scenarios, built once
+func dataset() []record {
+	recordsOnce.Do(func() {
+		records = make([]record, 10000)
+		for i := range records {
+			records[i] = record{
+				ID:      i,
+				Name:    fmt.Sprintf("user %d", i),
+				Email:   fmt.Sprintf("user%d@example.com", i),
+				Active:  i%3 == 0,
+				Score:   float64(i) / 7,
+				Tags:    []string{"alpha", "beta", "gamma"},
+				Created: time.Date(2018, 1, 1, 0, 0, i, 0, time.UTC),
+				Attrs:   map[string]string{"plan": "free", "region": "eu"},
+			}
+		}
+	})
+
+	return records
+}
+
+// Scenarios returns the built-in scenarios: small and huge JSON, layout heavy HTML and streamed NDJSON
+func Scenarios() []Scenario {
+	return []Scenario{
+		SmallJSON(),
+		HugeJSON(),
+		LayoutHTML(),
+		StreamedNDJSON(),
+	}
+}
+
+// SmallJSON renders a single record
+func SmallJSON() Scenario {
+	return Scenario{
+		Name: "SmallJSON",
+		Render: func(w http.ResponseWriter) {
+			render.JSON(w, http.StatusOK, dataset()[0])
+		},
+	}
+}
+
+// HugeJSON renders ten thousand records
+func HugeJSON() Scenario {
+	return Scenario{
+		Name: "HugeJSON",
+		Render: func(w http.ResponseWriter) {
+			render.JSON(w, http.StatusOK, dataset())
+		},
+	}
+}
+
+// LayoutHTML renders a page of two hundred rows with partials inside a layout
+func LayoutHTML() Scenario {
+	return Scenario{
+		Name: "LayoutHTML",
+		Templates: map[string]string{
+			"layout.tmpl":      `<!doctype html><html><head>{{template "shared/head" .}}</head><body>{{ yield }}</body></html>`,
+			"shared/head.tmpl": `<title>{{.Title}}</title><meta charset="utf-8">`,
+			"shared/row.tmpl":  `<tr><td>{{.ID}}</td><td>{{.Name}}</td><td><a href="mailto:{{.Email}}">{{.Email}}</a></td><td>{{range .Tags}}<span>{{.}}</span>{{end}}</td></tr>`,
+			"index.tmpl":       `<h1>{{.Title}}</h1><table>{{range .Rows}}{{template "shared/row" .}}{{end}}</table>`,
+		},
+		Layout: "layout",
+		Render: func(w http.ResponseWriter) {
+			render.HTML(w, http.StatusOK, "index", map[string]interface{}{
+				"Title": "Users <& friends>",
+				"Rows":  dataset()[:200],
+			})
+		},
+	}
+}
+
+// StreamedNDJSON streams ten thousand records as newline delimited JSON
+func StreamedNDJSON() Scenario {
+	return Scenario{
+		Name: "StreamedNDJSON",
+		Render: func(w http.ResponseWriter) {
+			r, pw := io.Pipe()
+			go func() {
+				encoder := json.NewEncoder(pw)
+				for _, v := range dataset() {
+					if err := encoder.Encode(v); err != nil {
+						pw.CloseWithError(err)
+						return
+					}
+				}
+				pw.Close()
+			}()
+			render.Stream(w, http.StatusOK, "application/x-ndjson", r, -1)
+		},
+	}
+}
+
+// RunAll runs every built-in scenario as a sub-benchmark with the options o
+func RunAll(b *testing.B, o render.Options) {
+	for _, s := range Scenarios() {
+		s := s
+		b.Run(s.Name, func(b *testing.B) {
+			Run(b, o, s)
+		})
+	}
+}
+
+// Run initializes render with the options o and benchmarks the scenario s
+func Run(b *testing.B, o render.Options, s Scenario) {
+	if len(s.Templates) > 0 {
+		dir, err := ioutil.TempDir("", "renderbench")
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		for name, content := range s.Templates {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				b.Fatal(err)
+			}
+			if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		o.Directory = dir
+		o.Extensions = []string{".tmpl"}
+		o.Layout = s.Layout
+	}
+	render.Init(o)
+	dataset()
+
+	w := newDiscard()
+	s.Render(w)
+	if w.status != http.StatusOK {
+		b.Fatalf("%s: status %d", s.Name, w.status)
+	}
+	b.SetBytes(w.written)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.reset()
+		s.Render(w)
+	}
+}
+
+// discard is a http.ResponseWriter counting and dropping the body
+type discard struct {
+	header  http.Header
+	status  int
+	written int64
+}
+
+func newDiscard() *discard {
+	return &discard{header: http.Header{}}
+}
+
+func (d *discard) Header() http.Header {
+	return d.header
+}
+
+func (d *discard) WriteHeader(status int) {
+	d.status = status
+}
+
+func (d *discard) Write(p []byte) (int, error) {
+	if d.status == 0 {
+		d.status = http.StatusOK
+	}
+	d.written += int64(len(p))
+
+	return len(p), nil
+}
+
+func (d *discard) reset() {
+	for key := range d.header {
+		delete(d.header, key)
+	}
+	d.status = 0
+	d.written = 0
+}
@@ -0,0 +1,494 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+// Package rendertest compares rendered responses, such as the ones of the old and of the new configuration of a
+// canary, structurally: JSON bodies value by value and HTML bodies tag by tag, so that reformatting is not reported.
+//
+//	diff := rendertest.Compare(*old, *canary, rendertest.IgnoreHeaders("Date", "ETag"),
+//		rendertest.IgnoreJSONFields("meta.requestId"))
+//	if !diff.Equal() {
+//		log.Print(diff)
+//	}
+package rendertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/ronzxy/go-render"
+	"mime"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Largest number of token pairs the bodies are aligned on, beyond which the differing part is reported at once
+const maxAlignment = 4 << 20
+
+// Change is a difference between two responses
+type Change struct {
+	// Where the responses differ: "status", "header Name", a JSON path such as "$.items[2].name", the elements of an
+	// HTML tag such as "html > body > ul > li[3]" or a line such as "line 12"
+	Path string
+	// Values of the first and of the second response, "" when missing
+	A, B string
+}
+
+// Diff is the list of the differences between two responses
+type Diff struct {
+	Changes []Change
+}
+
+// Equal tells whether the responses are the same
+func (d Diff) Equal() bool {
+	return len(d.Changes) == 0
+}
+
+func (d Diff) String() string {
+	var b strings.Builder
+	for _, change := range d.Changes {
+		fmt.Fprintf(&b, "%s:\n- %s\n+ %s\n", change.Path, change.A, change.B)
+	}
+
+	return b.String()
+}
+
+// Normalizer removes the parts of a response which are expected to differ, such as dates and request IDs
+type Normalizer func(response *render.CapturedResponse)
+
+// IgnoreHeaders removes the headers
+func IgnoreHeaders(names ...string) Normalizer {
+	return func(response *render.CapturedResponse) {
+		for _, name := range names {
+			response.Header.Del(name)
+		}
+	}
+}
+
+// ReplaceBody replaces the matches of pattern in the body with repl, such as CSRF tokens
+func ReplaceBody(pattern *regexp.Regexp, repl string) Normalizer {
+	return func(response *render.CapturedResponse) {
+		response.Body = pattern.ReplaceAll(response.Body, []byte(repl))
+	}
+}
+
+// IgnoreJSONFields removes the fields of a JSON body at the dotted paths, such as "meta.requestId", "*" matching
+// any field or item: "items.*.updatedAt"
+func IgnoreJSONFields(paths ...string) Normalizer {
+	return func(response *render.CapturedResponse) {
+		v, ok := decodeJSON(response.Body)
+		if !ok {
+			return
+		}
+		for _, path := range paths {
+			v = removeJSONField(v, strings.Split(path, "."))
+		}
+		if body, err := json.Marshal(v); err == nil {
+			response.Body = body
+		}
+	}
+}
+
+func removeJSONField(v interface{}, path []string) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if path[0] != "*" && path[0] != key {
+				continue
+			}
+			if len(path) == 1 {
+				delete(value, key)
+			} else {
+				value[key] = removeJSONField(field, path[1:])
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			if path[0] == "*" || path[0] == strconv.Itoa(i) {
+				if len(path) > 1 {
+					value[i] = removeJSONField(item, path[1:])
+				}
+			}
+		}
+	}
+
+	return v
+}
+
+// Compare returns the differences between the responses a and b once normalized: of their status, of their headers
+// and of their bodies, compared as JSON, HTML or lines of text after the Content-Type of a
+func Compare(a, b render.CapturedResponse, normalizers ...Normalizer) Diff {
+	a, b = normalize(a, normalizers), normalize(b, normalizers)
+
+	var diff Diff
+	if a.Status != b.Status {
+		diff.Changes = append(diff.Changes, Change{Path: "status", A: strconv.Itoa(a.Status), B: strconv.Itoa(b.Status)})
+	}
+	diff.Changes = append(diff.Changes, compareHeaders(a.Header, b.Header)...)
+
+	mediaType, _, _ := mime.ParseMediaType(a.Header.Get(render.ContentType))
+	switch {
+	case strings.HasSuffix(mediaType, "json"):
+		va, okA := decodeJSON(a.Body)
+		vb, okB := decodeJSON(b.Body)
+		if okA && okB {
+			diff.Changes = append(diff.Changes, compareJSON("$", va, vb)...)
+			return diff
+		}
+	case mediaType == render.ContentHTML || mediaType == render.ContentXHTML:
+		diff.Changes = append(diff.Changes, compareHTML(a.Body, b.Body)...)
+		return diff
+	}
+	diff.Changes = append(diff.Changes, compareLines(a.Body, b.Body)...)
+
+	return diff
+}
+
+// normalize returns a copy of response the normalizers were applied to
+func normalize(response render.CapturedResponse, normalizers []Normalizer) render.CapturedResponse {
+	response.Header = response.Header.Clone()
+	if response.Header == nil {
+		response.Header = http.Header{}
+	}
+	response.Body = append([]byte(nil), response.Body...)
+	for _, normalizer := range normalizers {
+		normalizer(&response)
+	}
+
+	return response
+}
+
+func compareHeaders(a, b http.Header) []Change {
+	names := map[string]bool{}
+	for name := range a {
+		names[name] = true
+	}
+	for name := range b {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var changes []Change
+	for _, name := range sorted {
+		va, vb := strings.Join(a[name], ", "), strings.Join(b[name], ", ")
+		if va != vb {
+			changes = append(changes, Change{Path: "header " + name, A: va, B: vb})
+		}
+	}
+
+	return changes
+}
+
+func decodeJSON(body []byte) (interface{}, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, false
+	}
+
+	return v, true
+}
+
+// compareJSON returns the differences between the JSON values at path, field by field and item by item
+func compareJSON(path string, a, b interface{}) []Change {
+	switch va := a.(type) {
+	case map[string]interface{}:
+		vb, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := map[string]bool{}
+		for key := range va {
+			keys[key] = true
+		}
+		for key := range vb {
+			keys[key] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+
+		var changes []Change
+		for _, key := range sorted {
+			fieldA, okA := va[key]
+			fieldB, okB := vb[key]
+			fieldPath := path + "." + key
+			switch {
+			case !okA:
+				changes = append(changes, Change{Path: fieldPath, B: jsonString(fieldB)})
+			case !okB:
+				changes = append(changes, Change{Path: fieldPath, A: jsonString(fieldA)})
+			default:
+				changes = append(changes, compareJSON(fieldPath, fieldA, fieldB)...)
+			}
+		}
+		return changes
+	case []interface{}:
+		vb, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		var changes []Change
+		for i := 0; i < len(va) || i < len(vb); i++ {
+			itemPath := path + "[" + strconv.Itoa(i) + "]"
+			switch {
+			case i >= len(va):
+				changes = append(changes, Change{Path: itemPath, B: jsonString(vb[i])})
+			case i >= len(vb):
+				changes = append(changes, Change{Path: itemPath, A: jsonString(va[i])})
+			default:
+				changes = append(changes, compareJSON(itemPath, va[i], vb[i])...)
+			}
+		}
+		return changes
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return nil
+	}
+
+	return []Change{{Path: path, A: jsonString(a), B: jsonString(b)}}
+}
+
+func jsonString(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(b)
+}
+
+var (
+	htmlTokenPattern   = regexp.MustCompile(`(?s)<!--.*?-->|<[^>]*>|[^<]+`)
+	htmlTagNamePattern = regexp.MustCompile(`^</?\s*([a-zA-Z][a-zA-Z0-9-]*)`)
+	htmlAttrPattern    = regexp.MustCompile(`([^\s"'>/=]+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+)))?`)
+	whitespacePattern  = regexp.MustCompile(`\s+`)
+)
+
+// Elements without end tag
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true, "input": true,
+	"link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// htmlToken is a tag or a text of an HTML body, with the elements it is within
+type htmlToken struct {
+	value string
+	path  string
+}
+
+// htmlTokens splits the HTML into tags, with their attributes sorted, and texts, with their whitespace collapsed.
+// The comments and the whitespace between tags are dropped.
+func htmlTokens(body []byte) []htmlToken {
+	type element struct {
+		// Tag name, and name in the path with the index of the element among its siblings of that name
+		tag, name string
+		children  map[string]int
+	}
+	stack := []element{{children: map[string]int{}}}
+	path := func() string {
+		var names []string
+		for _, e := range stack[1:] {
+			names = append(names, e.name)
+		}
+		return strings.Join(names, " > ")
+	}
+
+	var tokens []htmlToken
+	for _, token := range htmlTokenPattern.FindAllString(string(body), -1) {
+		switch {
+		case strings.HasPrefix(token, "<!--"):
+		case strings.HasPrefix(token, "</"):
+			m := htmlTagNamePattern.FindStringSubmatch(token)
+			if m == nil {
+				continue
+			}
+			name := strings.ToLower(m[1])
+			tokens = append(tokens, htmlToken{value: "</" + name + ">", path: path()})
+			for i := len(stack) - 1; i > 0; i-- {
+				if stack[i].tag == name {
+					stack = stack[:i]
+					break
+				}
+			}
+		case strings.HasPrefix(token, "<"):
+			m := htmlTagNamePattern.FindStringSubmatch(token)
+			if m == nil {
+				tokens = append(tokens, htmlToken{value: token, path: path()})
+				continue
+			}
+			tag := strings.ToLower(m[1])
+			parent := stack[len(stack)-1]
+			name := tag
+			if index := parent.children[tag]; index > 0 {
+				name += "[" + strconv.Itoa(index) + "]"
+			}
+			parent.children[tag]++
+			stack = append(stack, element{tag: tag, name: name, children: map[string]int{}})
+			tokens = append(tokens, htmlToken{value: normalizeTag(token, m[0], tag), path: path()})
+			if voidElements[tag] || strings.HasSuffix(token, "/>") {
+				stack = stack[:len(stack)-1]
+			}
+		default:
+			if text := strings.TrimSpace(whitespacePattern.ReplaceAllString(token, " ")); len(text) > 0 {
+				tokens = append(tokens, htmlToken{value: text, path: path()})
+			}
+		}
+	}
+
+	return tokens
+}
+
+// normalizeTag returns the start tag with its attributes sorted
+func normalizeTag(tag, start, name string) string {
+	attributes := strings.TrimSuffix(strings.TrimSuffix(tag[len(start):], ">"), "/")
+	var attrs []string
+	for _, m := range htmlAttrPattern.FindAllStringSubmatch(attributes, -1) {
+		attr := strings.ToLower(m[1])
+		if value := m[2] + m[3] + m[4]; len(value) > 0 || strings.Contains(m[0], "=") {
+			attr += `="` + value + `"`
+		}
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+
+	if len(attrs) == 0 {
+		return "<" + name + ">"
+	}
+	return "<" + name + " " + strings.Join(attrs, " ") + ">"
+}
+
+func compareHTML(a, b []byte) []Change {
+	ta, tb := htmlTokens(a), htmlTokens(b)
+	va, vb := make([]string, len(ta)), make([]string, len(tb))
+	for i, token := range ta {
+		va[i] = token.value
+	}
+	for i, token := range tb {
+		vb[i] = token.value
+	}
+
+	var changes []Change
+	for _, hunk := range align(va, vb) {
+		path := "body"
+		switch {
+		case hunk.a < len(ta):
+			path = ta[hunk.a].path
+		case hunk.b < len(tb):
+			path = tb[hunk.b].path
+		}
+		if len(path) == 0 {
+			path = "document"
+		}
+		changes = append(changes, Change{
+			Path: path,
+			A:    strings.Join(va[hunk.a:hunk.a+hunk.lenA], ""),
+			B:    strings.Join(vb[hunk.b:hunk.b+hunk.lenB], ""),
+		})
+	}
+
+	return changes
+}
+
+func compareLines(a, b []byte) []Change {
+	la, lb := strings.Split(string(a), "\n"), strings.Split(string(b), "\n")
+
+	var changes []Change
+	for _, hunk := range align(la, lb) {
+		changes = append(changes, Change{
+			Path: "line " + strconv.Itoa(hunk.a+1),
+			A:    strings.Join(la[hunk.a:hunk.a+hunk.lenA], "\n"),
+			B:    strings.Join(lb[hunk.b:hunk.b+hunk.lenB], "\n"),
+		})
+	}
+
+	return changes
+}
+
+// hunk is a differing part of two sequences: lenA items from a replaced by lenB items from b
+type hunk struct {
+	a, lenA int
+	b, lenB int
+}
+
+// align returns the hunks of a and b out of their longest common subsequence. When the differing part is too large
+// to be aligned, it is returned as a single hunk.
+func align(a, b []string) []hunk {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(ma) == 0 && len(mb) == 0 {
+		return nil
+	}
+	if len(ma)*len(mb) > maxAlignment || len(ma) == 0 || len(mb) == 0 {
+		return []hunk{{a: prefix, lenA: len(ma), b: prefix, lenB: len(mb)}}
+	}
+
+	// lengths of the longest common subsequences of the suffixes
+	lcs := make([][]int32, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var hunks []hunk
+	var current *hunk
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		if i < len(ma) && j < len(mb) && ma[i] == mb[j] {
+			current = nil
+			i++
+			j++
+			continue
+		}
+		if current == nil {
+			hunks = append(hunks, hunk{a: prefix + i, b: prefix + j})
+			current = &hunks[len(hunks)-1]
+		}
+		if j >= len(mb) || i < len(ma) && lcs[i+1][j] >= lcs[i][j+1] {
+			current.lenA++
+			i++
+		} else {
+			current.lenB++
+			j++
+		}
+	}
+
+	return hunks
+}
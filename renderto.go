@@ -0,0 +1,114 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Page is the value RenderTo renders in FormatHTML, FormatMarkdown and FormatMustache: the template Name executed with
+// Binding. In FormatText, the text template Name is executed.
+type Page struct {
+	Name    string
+	Binding interface{}
+	// Options of the render, Request being usually nil. Defaults to the layout of Options, like HTML without options.
+	Options *HTMLOptions
+}
+
+// RenderTo writes v in format to w, such as a file, a websocket or a message queue, with the same Options as the
+// responses: the JSON encoder, the prefixes, the redacted fields, the layouts. v is a Page for FormatHTML,
+// FormatMarkdown and FormatMustache, a string, a []byte or the Page of a text template for FormatText. The body is
+// never compressed.
+//
+//	f, _ := os.Create("public/index.html")
+//	defer f.Close()
+//	err := render.RenderTo(f, render.FormatHTML, render.Page{Name: "home", Binding: data})
+func RenderTo(w io.Writer, format Format, v interface{}) error {
+	out := &writerResponse{w: w, header: http.Header{}}
+
+	var err error
+	switch format {
+	case FormatHTML, FormatMarkdown, FormatMustache:
+		page, ok := v.(Page)
+		if p, isPointer := v.(*Page); isPointer && p != nil {
+			page, ok = *p, true
+		}
+		if !ok {
+			return fmt.Errorf("render: RenderTo %s takes a Page, not %T", format, v)
+		}
+		var htmlOptions []HTMLOptions
+		if page.Options != nil {
+			htmlOptions = append(htmlOptions, *page.Options)
+		}
+		switch format {
+		case FormatHTML:
+			err = HTMLE(out, http.StatusOK, page.Name, page.Binding, htmlOptions...)
+		case FormatMarkdown:
+			err = MarkdownE(out, http.StatusOK, page.Name, page.Binding, htmlOptions...)
+		default:
+			err = MustacheE(out, http.StatusOK, page.Name, page.Binding, htmlOptions...)
+		}
+	case FormatJSON:
+		err = JSONE(out, http.StatusOK, v)
+	case FormatXML:
+		err = XMLE(out, http.StatusOK, v)
+	case FormatGob:
+		err = GobE(out, http.StatusOK, v)
+	case FormatText:
+		switch s := v.(type) {
+		case string:
+			Text(out, http.StatusOK, s)
+		case []byte:
+			Text(out, http.StatusOK, string(s))
+		case Page:
+			err = RenderTextE(out, http.StatusOK, s.Name, s.Binding)
+		case *Page:
+			err = RenderTextE(out, http.StatusOK, s.Name, s.Binding)
+		default:
+			return fmt.Errorf("render: RenderTo text takes a string, a []byte or a Page, not %T", v)
+		}
+	default:
+		return fmt.Errorf("render: RenderTo: unknown format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	return out.err
+}
+
+// writerResponse is a ResponseWriter writing the body to w, dropping the status and the headers
+type writerResponse struct {
+	w      io.Writer
+	header http.Header
+	// First error of w
+	err error
+}
+
+func (r *writerResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *writerResponse) WriteHeader(int) {}
+
+func (r *writerResponse) Write(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.w.Write(p)
+	r.err = err
+
+	return n, err
+}
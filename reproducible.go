@@ -0,0 +1,77 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"time"
+)
+
+// RenderDigest is the header of the SHA-256 digest of reproducible HTML bodies, such as "sha-256=:X48E...=:". It is
+// computed before compression, so equal for all content codings.
+const RenderDigest = "Render-Digest"
+
+// Time the now func of the helper library returns in reproducible mode
+var reproducibleNow = time.Unix(0, 0).UTC()
+
+// libraryFuncs returns the helper library, with a frozen clock in reproducible mode
+func libraryFuncs() map[string]interface{} {
+	if !render.options.Reproducible {
+		return helperLibrary
+	}
+
+	funcs := make(map[string]interface{}, len(helperLibrary))
+	for name, f := range helperLibrary {
+		funcs[name] = f
+	}
+	funcs["now"] = func() time.Time { return reproducibleNow }
+	funcs["ago"] = func(t time.Time) string { return reproducibleNow.Sub(t).Round(time.Second).String() }
+
+	return funcs
+}
+
+// reproducibleBody normalizes the line endings of an HTML body and adds its digest to the call headers, in
+// reproducible mode
+func reproducibleBody(call *callOptions, body []byte) []byte {
+	if !render.options.Reproducible {
+		return body
+	}
+
+	body = normalizeLines(body)
+
+	sum := sha256.Sum256(body)
+	header := http.Header{}
+	for key, values := range call.header {
+		header[key] = values
+	}
+	header.Set(RenderDigest, "sha-256=:"+base64.StdEncoding.EncodeToString(sum[:])+":")
+	call.header = header
+
+	return body
+}
+
+// normalizeLines turns CRLF and CR line endings into LF and drops the spaces and tabs ending the lines
+func normalizeLines(body []byte) []byte {
+	body = bytes.Replace(body, []byte("\r\n"), []byte("\n"), -1)
+	body = bytes.Replace(body, []byte("\r"), []byte("\n"), -1)
+
+	lines := bytes.Split(body, []byte("\n"))
+	for i, line := range lines {
+		lines[i] = bytes.TrimRight(line, " \t")
+	}
+
+	return bytes.Join(lines, []byte("\n"))
+}
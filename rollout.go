@@ -0,0 +1,154 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Buckets of the clients of a Rollout, a hundredth of a percent each
+const rolloutBuckets = 10000
+
+// Default lifetime of the cookie of a Rollout
+const defaultRolloutMaxAge = 30 * 24 * time.Hour
+
+// VariantSelector returns the template to render instead of name for the request r, name or "" to render name
+// itself, such as to run an experiment of an external system
+type VariantSelector func(r *http.Request, name string) string
+
+// Variant is a template served to a share of the clients by a Rollout
+type Variant struct {
+	// Template rendered instead of the one of the render, such as "home-v2"
+	Template string
+	// Percent of the clients served Template, from 0 to 100
+	Percent float64
+}
+
+// Rollout serves variants of a template to shares of the clients, the clients left render the template itself.
+// The assignment of a client is sticky: the value of Header, such as a user ID, or else a random number kept in a
+// cookie, puts the client in a bucket. Raising the percents moves clients gradually, the ones already served a
+// variant keep it.
+//
+//	render.Options{Rollouts: map[string]render.Rollout{
+//		"home": {Variants: []render.Variant{{Template: "home-v2", Percent: 10}}, Header: "X-User-ID"},
+//	}}
+type Rollout struct {
+	Variants []Variant
+	// Header identifying the client. Defaults to none, the cookie is used.
+	Header string
+	// Cookie keeping the bucket of the clients without Header. Defaults to "render_rollout_" followed by the name of
+	// the template.
+	Cookie string
+	// Lifetime of the cookie. Defaults to 30 days.
+	MaxAge time.Duration
+}
+
+// selectVariant returns the template to render instead of name, of Options.SelectVariant or of Options.Rollouts.
+// The variants which are not defined are ignored.
+func selectVariant(w http.ResponseWriter, r *http.Request, name string) string {
+	variant := selectedVariant(w, r, name)
+	if variant != name {
+		traceEvent(r, TraceEvent{Kind: TraceCache, Name: "variant", Detail: variant})
+	}
+
+	return variant
+}
+
+func selectedVariant(w http.ResponseWriter, r *http.Request, name string) string {
+	if selector := render.options.SelectVariant; selector != nil && r != nil {
+		if variant := selector(r, name); len(variant) > 0 && variant != name && definedTemplate(variant) {
+			return variant
+		}
+	}
+
+	rollout, ok := render.options.Rollouts[name]
+	if !ok || r == nil || len(rollout.Variants) == 0 {
+		return name
+	}
+
+	bucket := rolloutBucket(w, r, name, rollout)
+	limit := 0.0
+	for _, variant := range rollout.Variants {
+		limit += variant.Percent * rolloutBuckets / 100
+		if float64(bucket) < limit {
+			if definedTemplate(variant.Template) {
+				return variant.Template
+			}
+			break
+		}
+	}
+
+	return name
+}
+
+// rolloutBucket returns the bucket of the client, from the hash of its header or from its cookie, which is set with
+// a random bucket when there is none
+func rolloutBucket(w http.ResponseWriter, r *http.Request, name string, rollout Rollout) int {
+	if len(rollout.Header) > 0 {
+		addVary(w.Header(), rollout.Header)
+		if value := r.Header.Get(rollout.Header); len(value) > 0 {
+			h := fnv.New32a()
+			h.Write([]byte(name + "\x00" + value))
+			return int(h.Sum32() % rolloutBuckets)
+		}
+	}
+
+	cookie := rollout.Cookie
+	if len(cookie) == 0 {
+		cookie = "render_rollout_" + cookieName(name)
+	}
+	addVary(w.Header(), "Cookie")
+	if c, err := r.Cookie(cookie); err == nil {
+		if bucket, err := strconv.Atoi(c.Value); err == nil && bucket >= 0 && bucket < rolloutBuckets {
+			return bucket
+		}
+	}
+
+	maxAge := rollout.MaxAge
+	if maxAge <= 0 {
+		maxAge = defaultRolloutMaxAge
+	}
+	bucket := rand.Intn(rolloutBuckets)
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookie,
+		Value:    strconv.Itoa(bucket),
+		Path:     "/",
+		MaxAge:   int(maxAge / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return bucket
+}
+
+// cookieName replaces the characters of name which are not allowed in a cookie name
+func cookieName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			b[i] = '_'
+		}
+	}
+
+	return string(b)
+}
+
+// definedTemplate tells whether the HTML template name is defined
+func definedTemplate(name string) bool {
+	t := loaded().template.Lookup(name)
+	return t != nil && t.Tree != nil
+}
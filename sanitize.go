@@ -0,0 +1,64 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"fmt"
+	"github.com/microcosm-cc/bluemonday"
+	"html/template"
+)
+
+// Name of the policy sanitize uses when none is given
+const defaultSanitizePolicy = "strict"
+
+// Policies of the sanitize func by name: "strict", which strips all the HTML and keeps the text, and "ugc", which
+// keeps the formatting, links and images of user generated content
+var sanitizePolicies = map[string]*bluemonday.Policy{
+	defaultSanitizePolicy: bluemonday.StrictPolicy(),
+	"ugc":                 bluemonday.UGCPolicy(),
+}
+
+var sanitizeFuncs = template.FuncMap{
+	"sanitize": sanitize,
+}
+
+// RegisterSanitizePolicy registers the bluemonday policy the sanitize func applies under name, replacing the
+// built-in "strict" and "ugc" ones of the same name. Policies are meant to be registered at startup, before rendering.
+//
+//	policy := bluemonday.UGCPolicy()
+//	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w-]+$`)).OnElements("code")
+//	render.RegisterSanitizePolicy("comments", policy)
+func RegisterSanitizePolicy(name string, policy *bluemonday.Policy) {
+	sanitizePolicies[name] = policy
+}
+
+// sanitize returns the user generated HTML s cleaned by the policy of the given name, "strict" when none is given,
+// instead of trusting it with a template.HTML conversion:
+//
+//	{{ sanitize .Comment.Body "ugc" }}
+func sanitize(s string, policy ...string) (template.HTML, error) {
+	name := defaultSanitizePolicy
+	if len(policy) > 1 {
+		return "", fmt.Errorf("render: sanitize takes one policy, got %d", len(policy))
+	}
+	if len(policy) == 1 {
+		name = policy[0]
+	}
+
+	p, ok := sanitizePolicies[name]
+	if !ok {
+		return "", fmt.Errorf("render: sanitize policy %q is not registered", name)
+	}
+
+	return template.HTML(p.Sanitize(s)), nil
+}
@@ -0,0 +1,42 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSanitizePolicies(t *testing.T) {
+	initTemplates(t, map[string]string{
+		"strict.tmpl":  `{{sanitize .}}`,
+		"ugc.tmpl":     `{{sanitize . "ugc"}}`,
+		"unknown.tmpl": `{{sanitize . "unknown"}}`,
+		"comment.tmpl": `{{markdown .}}`,
+	}, Options{Markdown: copyMarkdown{}})
+
+	source := `<b>bold</b><script>alert(1)</script>`
+	for name, want := range map[string]string{"strict": "bold", "ugc": "<b>bold</b>", "comment": "<b>bold</b>"} {
+		w := httptest.NewRecorder()
+		HTML(w, 200, name, source)
+		if body := w.Body.String(); !strings.Contains(body, want) || strings.Contains(body, "<script") || name == "strict" && strings.Contains(body, "<b>") {
+			t.Errorf("%s: got %q", name, body)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	if err := HTMLE(w, 200, "unknown", source); err == nil || !strings.Contains(err.Error(), `"unknown" is not registered`) {
+		t.Errorf("unknown policy: got %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Scope is the state of a render given to the factories of Options.FuncFactories
+type Scope struct {
+	// The request being answered, nil when the call was not given it
+	Request *http.Request
+	// Locale the templates are rendered in, "" for the default tree
+	Locale string
+	// HTMLOptions.User
+	User interface{}
+	// HTMLOptions.TimeZone, or the time zone of the request context, nil when there is none
+	TimeZone *time.Location
+}
+
+// FuncFactory makes the func of a template func for a render, such as:
+//
+//	"can": func(s *render.Scope) interface{} {
+//		return func(permission string) bool { return s.User.(*User).Can(permission) }
+//	}
+type FuncFactory func(scope *Scope) interface{}
+
+// factoryPlaceholders declares the template funcs of Options.FuncFactories at parse time
+func factoryPlaceholders() template.FuncMap {
+	funcs := template.FuncMap{}
+	for name := range render.options.FuncFactories {
+		name := name
+		funcs[name] = func() (string, error) {
+			return "", fmt.Errorf("render: func %s called outside of a render", name)
+		}
+	}
+
+	return funcs
+}
+
+// scopeFuncs makes the funcs of Options.FuncFactories for a render
+func scopeFuncs(option HTMLOptions, locale string) template.FuncMap {
+	scope := &Scope{Request: option.Request, Locale: locale, User: option.User, TimeZone: option.TimeZone}
+	if scope.TimeZone == nil && option.Request != nil {
+		scope.TimeZone, _ = option.Request.Context().Value(timeZoneContextKey{}).(*time.Location)
+	}
+
+	funcs := template.FuncMap{}
+	for name, factory := range render.options.FuncFactories {
+		funcs[name] = factory(scope)
+	}
+
+	return funcs
+}
+
+// scopedTemplate returns the templates a render executes, with the funcs of Options.FuncFactories made for the render,
+// and the func giving them back once executed. The templates are the ones of set, or of HTMLOptions.Tenant when it has
+// some.
+func scopedTemplate(set *templateSet, option HTMLOptions, locale string) (*template.Template, func(), error) {
+	clones := set.clones
+	if len(option.Tenant) > 0 {
+		tenant, err := tenantTemplates(option.Tenant)
+		if err != nil {
+			return nil, nil, err
+		}
+		if tenant.clones != nil {
+			clones = tenant.clones
+			traceEvent(option.Request, TraceEvent{Kind: TraceCache, Name: "tenant", Detail: option.Tenant})
+		}
+	}
+
+	return renderTemplate(clones, option, locale)
+}
+
+// renderTemplate returns a clone of the templates of clones with the funcs of Options.FuncFactories made for the
+// render, and the func giving it back once executed. A traced render executes a clone of its own, instrumented.
+func renderTemplate(clones *templatePool, option HTMLOptions, locale string) (*template.Template, func(), error) {
+	scoped := scopeFuncs(option, locale)
+	if trace := requestTrace(option.Request); trace != nil {
+		t, err := clones.pristine.Clone()
+		if err != nil {
+			return nil, nil, err
+		}
+		t.Funcs(scoped)
+		if err := trace.instrument(t, append(htmlFuncMaps(), scoped)); err != nil {
+			return nil, nil, err
+		}
+		return t, func() {}, nil
+	}
+
+	t, err := clones.get()
+	if err != nil {
+		return nil, nil, err
+	}
+	t.Funcs(scoped)
+
+	return t, func() { clones.put(t) }, nil
+}
+
+// templatePool lends the clones of a never executed template set to the renders, one render at a time, since the
+// layouts install the yield, content_for and current funcs of the render on the clone it executes. A clone is made,
+// and its templates escaped, only when the ones made before are all in use.
+type templatePool struct {
+	pristine *template.Template
+	clones   sync.Pool
+}
+
+func newTemplatePool(pristine *template.Template) *templatePool {
+	return &templatePool{pristine: pristine}
+}
+
+// Funcs holding the state of a render, set back to the ones of a render without layout when a clone is reused
+var renderStateFuncs = template.FuncMap{
+	"yield":       helperFuncs["yield"],
+	"content_for": helperFuncs["content_for"],
+	"current":     helperFuncs["current"],
+	"flush":       helperFuncs["flush"],
+}
+
+func (pool *templatePool) get() (*template.Template, error) {
+	if t, ok := pool.clones.Get().(*template.Template); ok {
+		t.Funcs(renderStateFuncs)
+		return t, nil
+	}
+
+	return pool.pristine.Clone()
+}
+
+func (pool *templatePool) put(t *template.Template) {
+	pool.clones.Put(t)
+}
@@ -0,0 +1,85 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SecurityHeaders is a struct for specifying the security headers set on the responses of the renders. A header
+// the response already has, set by a middleware or by the Header of the call options, is left as is.
+type SecurityHeaders struct {
+	// Set X-Content-Type-Options: nosniff, Referrer-Policy, X-Frame-Options and the frame-ancestors of
+	// Content-Security-Policy, along with Strict-Transport-Security when HSTSMaxAge is set.
+	Enabled bool `yaml:"Enabled"`
+	// Referrer-Policy. Defaults to "strict-origin-when-cross-origin".
+	ReferrerPolicy string `yaml:"ReferrerPolicy"`
+	// X-Frame-Options, "DENY" or "SAMEORIGIN", frame-ancestors being 'none' or 'self' accordingly. The
+	// Content-Security-Policy is only set when the response has none. Defaults to "DENY".
+	FrameOptions string `yaml:"FrameOptions"`
+	// max-age of Strict-Transport-Security. Defaults to 0, no HSTS, since it can't be undone for the browsers which
+	// saw it before it expires.
+	HSTSMaxAge time.Duration `yaml:"HSTSMaxAge"`
+	// Add includeSubDomains to Strict-Transport-Security
+	HSTSIncludeSubdomains bool `yaml:"HSTSIncludeSubdomains"`
+	// Add preload to Strict-Transport-Security
+	HSTSPreload bool `yaml:"HSTSPreload"`
+}
+
+// setSecurityHeaders sets the headers of Options.SecurityHeaders the header does not have
+func setSecurityHeaders(header http.Header) {
+	security := render.options.SecurityHeaders
+	if !security.Enabled {
+		return
+	}
+
+	setDefault := func(key, value string) {
+		if len(header.Get(key)) == 0 {
+			header.Set(key, value)
+		}
+	}
+
+	setDefault("X-Content-Type-Options", "nosniff")
+
+	referrerPolicy := security.ReferrerPolicy
+	if len(referrerPolicy) == 0 {
+		referrerPolicy = "strict-origin-when-cross-origin"
+	}
+	setDefault("Referrer-Policy", referrerPolicy)
+
+	frameOptions := "DENY"
+	if security.FrameOptions == "SAMEORIGIN" {
+		frameOptions = "SAMEORIGIN"
+	}
+	setDefault("X-Frame-Options", frameOptions)
+	// frame-ancestors follows X-Frame-Options, which a call may have set, since browsers prefer it
+	switch header.Get("X-Frame-Options") {
+	case "DENY":
+		setDefault("Content-Security-Policy", "frame-ancestors 'none'")
+	case "SAMEORIGIN":
+		setDefault("Content-Security-Policy", "frame-ancestors 'self'")
+	}
+
+	if security.HSTSMaxAge > 0 {
+		hsts := "max-age=" + strconv.FormatInt(int64(security.HSTSMaxAge/time.Second), 10)
+		if security.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if security.HSTSPreload {
+			hsts += "; preload"
+		}
+		setDefault("Strict-Transport-Security", hsts)
+	}
+}
@@ -0,0 +1,74 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecurityHeadersOfEveryResponse(t *testing.T) {
+	initTemplates(t, map[string]string{"page.tmpl": "page"}, Options{
+		FlashSecret:     []byte("secret"),
+		SecurityHeaders: SecurityHeaders{Enabled: true},
+	})
+	file := filepath.Join(t.TempDir(), "app.css")
+	if err := ioutil.WriteFile(file, []byte("body{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	responses := map[string]func(w http.ResponseWriter, r *http.Request){
+		"HTML": func(w http.ResponseWriter, r *http.Request) {
+			HTML(w, 200, "page", nil)
+		},
+		"File": func(w http.ResponseWriter, r *http.Request) {
+			File(w, r, file)
+		},
+		"missing File": func(w http.ResponseWriter, r *http.Request) {
+			File(w, r, file+".missing")
+		},
+		"Proxy": func(w http.ResponseWriter, r *http.Request) {
+			Proxy(w, &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{ContentType: {ContentText}},
+				Body:       ioutil.NopCloser(strings.NewReader("upstream")),
+			}, nil)
+		},
+		"Recover": Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		})).ServeHTTP,
+		"Redirect": func(w http.ResponseWriter, r *http.Request) {
+			Redirect(w, r, 0, "/")
+		},
+		"RedirectWithData": func(w http.ResponseWriter, r *http.Request) {
+			RedirectWithData(w, r, 0, "/", "saved")
+		},
+	}
+
+	for name, respond := range responses {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", ContentProblemJSON)
+		w := httptest.NewRecorder()
+		respond(w, r)
+
+		for _, key := range []string{"X-Content-Type-Options", "Referrer-Policy", "X-Frame-Options", "Content-Security-Policy"} {
+			if len(w.Header().Get(key)) == 0 {
+				t.Errorf("%s: no %s header", name, key)
+			}
+		}
+	}
+}
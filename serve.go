@@ -0,0 +1,93 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// File serves the file at path with support for Range, If-Range, If-Modified-Since and If-None-Match requests
+func File(w http.ResponseWriter, r *http.Request, path string) {
+	f, err := os.Open(path)
+	serveFile(w, r, f, err)
+}
+
+// FileFromFS serves the file name of fsys like File
+func FileFromFS(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string) {
+	f, err := fsys.Open(name)
+	serveFile(w, r, f, err)
+}
+
+func serveFile(w http.ResponseWriter, r *http.Request, f fs.File, err error) {
+	if err != nil {
+		serveFileError(w, err)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		serveFileError(w, err)
+		return
+	}
+	if info.IsDir() {
+		serveFileError(w, fs.ErrNotExist)
+		return
+	}
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		buf, err := ioutil.ReadAll(f)
+		if err != nil {
+			serveFileError(w, err)
+			return
+		}
+		content = bytes.NewReader(buf)
+	}
+
+	if len(w.Header().Get(ContentType)) == 0 {
+		contentType := mime.TypeByExtension(path.Ext(info.Name()))
+		if strings.HasPrefix(contentType, "text/") && !strings.Contains(contentType, "charset=") {
+			contentType += prepareCharset(render.options.Charset)
+		}
+		// content sniffed by http.ServeContent when unknown
+		if len(contentType) > 0 {
+			w.Header().Set(ContentType, contentType)
+		}
+	}
+
+	setSecurityHeaders(w.Header())
+	http.ServeContent(w, r, info.Name(), info.ModTime(), content)
+}
+
+// serveFileError writes the status for err without exposing file system details
+func serveFileError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, fs.ErrNotExist) {
+		status = http.StatusNotFound
+	} else if errors.Is(err, fs.ErrPermission) {
+		status = http.StatusForbidden
+	}
+
+	setSecurityHeaders(w.Header())
+	http.Error(w, http.StatusText(status), status)
+}
@@ -0,0 +1,182 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrShuttingDown is returned for the streams started after Close, it is answered with 503 Service Unavailable. The
+// writes of the streams still running when Close gives up fail with it too.
+var ErrShuttingDown = errors.New("render: shutting down")
+
+// Active streams, and the channels closed when Close is called and when it gives up
+var (
+	streams     = map[*streamState]struct{}{}
+	streamsMu   sync.Mutex
+	streamsDone = make(chan struct{}, 1)
+	closing     = make(chan struct{})
+)
+
+// streamState is an active stream
+type streamState struct {
+	cut chan struct{}
+}
+
+// ShuttingDown returns a channel closed once Close is called. A handler streaming events selects on it to send a
+// final event and return:
+//
+//	done, err := render.TrackStream()
+//	if err != nil {
+//		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+//		return
+//	}
+//	defer done()
+//	for {
+//		select {
+//		case event := <-events:
+//			fmt.Fprintf(w, "data: %s\n\n", event)
+//		case <-render.ShuttingDown():
+//			fmt.Fprint(w, "event: close\ndata: reconnect\n\n")
+//			return
+//		}
+//		http.NewResponseController(w).Flush()
+//	}
+func ShuttingDown() <-chan struct{} {
+	streamsMu.Lock()
+	defer streamsMu.Unlock()
+
+	return closing
+}
+
+// TrackStream counts a stream of the application, such as server-sent events or a long poll, among the streams
+// Close waits for. done is called when the stream ends. It fails with ErrShuttingDown once Close is called. See
+// StartEventStream for server-sent events with write deadlines and heartbeats.
+func TrackStream() (done func(), err error) {
+	s, err := startStream()
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		endStream(s)
+	}, nil
+}
+
+// Close signals the active streams to finish: ShuttingDown is closed and HTMLStream, Stream, Proxy and Archive
+// calls are let to complete. It waits for them up to the deadline of ctx, then cuts the ones still running: their
+// writes fail with ErrShuttingDown. It returns the number of streams cut, with the error of ctx when there are some.
+// The streams started after Close are answered with 503 Service Unavailable, until Init is called again.
+//
+//	srv.RegisterOnShutdown(func() {
+//		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//		defer cancel()
+//		if cut, err := render.Close(ctx); err != nil {
+//			log.Printf("%d streams cut: %s", cut, err)
+//		}
+//	})
+func Close(ctx context.Context) (int, error) {
+	streamsMu.Lock()
+	if !isClosing() {
+		close(closing)
+	}
+	streamsMu.Unlock()
+
+	for {
+		streamsMu.Lock()
+		active := len(streams)
+		streamsMu.Unlock()
+		if active == 0 {
+			return 0, nil
+		}
+
+		select {
+		case <-streamsDone:
+		case <-ctx.Done():
+			streamsMu.Lock()
+			cut := len(streams)
+			for s := range streams {
+				close(s.cut)
+				delete(streams, s)
+			}
+			streamsMu.Unlock()
+			return cut, ctx.Err()
+		}
+	}
+}
+
+// resetShutdown lets the streams start again after Close, for a new Init such as the one of a server restarted
+// within the process
+func resetShutdown() {
+	streamsMu.Lock()
+	defer streamsMu.Unlock()
+
+	if isClosing() {
+		closing = make(chan struct{})
+	}
+}
+
+// isClosing tells whether Close was called, streamsMu being held
+func isClosing() bool {
+	select {
+	case <-closing:
+		return true
+	default:
+		return false
+	}
+}
+
+// startStream registers an active stream, failing with ErrShuttingDown once Close is called
+func startStream() (*streamState, error) {
+	streamsMu.Lock()
+	defer streamsMu.Unlock()
+
+	if isClosing() {
+		return nil, ErrShuttingDown
+	}
+	s := &streamState{cut: make(chan struct{})}
+	streams[s] = struct{}{}
+
+	return s, nil
+}
+
+// endStream unregisters a stream, waking Close up
+func endStream(s *streamState) {
+	streamsMu.Lock()
+	delete(streams, s)
+	streamsMu.Unlock()
+
+	select {
+	case streamsDone <- struct{}{}:
+	default:
+	}
+}
+
+// streamOutput fails with ErrShuttingDown once its stream is cut
+type streamOutput struct {
+	w     io.Writer
+	state *streamState
+}
+
+func (s streamOutput) Write(p []byte) (int, error) {
+	select {
+	case <-s.state.cut:
+		return 0, ErrShuttingDown
+	default:
+	}
+
+	return s.w.Write(p)
+}
@@ -0,0 +1,40 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+package render
+
+import (
+	"context"
+	"testing"
+)
+
+func TestInitAfterClose(t *testing.T) {
+	initTemplates(t, nil, Options{})
+
+	if cut, err := Close(context.Background()); cut != 0 || err != nil {
+		t.Fatalf("got %d, %v", cut, err)
+	}
+	if _, err := TrackStream(); err != ErrShuttingDown {
+		t.Errorf("stream after Close: got %v", err)
+	}
+
+	initTemplates(t, nil, Options{})
+	select {
+	case <-ShuttingDown():
+		t.Error("still shutting down after Init")
+	default:
+	}
+	done, err := TrackStream()
+	if err != nil {
+		t.Fatalf("stream after Init: got %v", err)
+	}
+	done()
+}
@@ -0,0 +1,166 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// spoolBuffer holds the output of an HTML render in a buffer of the BufferPool, moved to a temporary file of
+// Options.SpoolDirectory once it exceeds Options.SpoolThreshold
+type spoolBuffer struct {
+	buf  *bytes.Buffer
+	file *os.File
+	size int64
+}
+
+func newSpoolBuffer() *spoolBuffer {
+	return &spoolBuffer{buf: render.buffer.Get()}
+}
+
+func (s *spoolBuffer) Write(p []byte) (int, error) {
+	threshold := render.options.SpoolThreshold
+	if s.file == nil && threshold > 0 && int64(s.buf.Len()+len(p)) > threshold {
+		f, err := ioutil.TempFile(render.options.SpoolDirectory, "render-spool-")
+		if err != nil {
+			return 0, err
+		}
+		s.file = f
+		if _, err := s.file.Write(s.buf.Bytes()); err != nil {
+			return 0, err
+		}
+		s.buf.Reset()
+	}
+
+	s.size += int64(len(p))
+	if s.file != nil {
+		return s.file.Write(p)
+	}
+
+	return s.buf.Write(p)
+}
+
+// spilled tells whether the output is in a file
+func (s *spoolBuffer) spilled() bool {
+	return s.file != nil
+}
+
+// contents returns the output, read back from the file when it spilled
+func (s *spoolBuffer) contents() (string, error) {
+	if s.file == nil {
+		return s.buf.String(), nil
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	buf, err := ioutil.ReadAll(s.file)
+
+	return string(buf), err
+}
+
+// Reset drops the output, and the file
+func (s *spoolBuffer) Reset() {
+	s.removeFile()
+	s.buf.Reset()
+	s.size = 0
+}
+
+// release sets the buffer in the BufferPool and removes the file
+func (s *spoolBuffer) release() {
+	s.removeFile()
+	render.buffer.Set(s.buf)
+}
+
+func (s *spoolBuffer) removeFile() {
+	if s.file == nil {
+		return
+	}
+	s.file.Close()
+	os.Remove(s.file.Name())
+	s.file = nil
+}
+
+// writeRendered writes the output of an HTML render, from memory as writeResponse does, or streamed from its file
+// when it spilled
+func writeRendered(w http.ResponseWriter, status int, contentType string, call callOptions, s *spoolBuffer) error {
+	detail := "memory"
+	if s.spilled() {
+		detail = "spool"
+	}
+	traceEvent(call.request, TraceEvent{Kind: TraceWrite, Name: "body", Detail: detail, Bytes: s.size})
+
+	if !s.spilled() {
+		body := reproducibleBody(&call, s.buf.Bytes())
+		return writeResponse(w, status, contentType, call, body)
+	}
+
+	coding := ""
+	if !call.noCompress && compressibleType(contentType) {
+		coding = negotiateSizeCoding(w, call.request, s.size)
+	}
+
+	etag := ""
+	if call.etag && status >= 200 && status < 300 {
+		if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		h := sha256.New()
+		if _, err := copyBody(h, s.file); err != nil {
+			return err
+		}
+		etag = `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+		if len(coding) > 0 {
+			etag = codingETag(etag, coding)
+		}
+
+		if status == http.StatusOK && notModified(call.request, etag) {
+			setHeader(w, call.header)
+			w.Header().Set(ETag, etag)
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	setHeader(w, call.header)
+	if len(etag) > 0 {
+		w.Header().Set(ETag, etag)
+	}
+	w.Header().Set(ContentType, contentType)
+	if len(coding) == 0 {
+		w.Header().Set(ContentLength, strconv.FormatInt(s.size, 10))
+		w.WriteHeader(status)
+		copyBody(w, s.file)
+		return nil
+	}
+
+	w.Header().Set(ContentEncoding, coding)
+	w.WriteHeader(status)
+	pool := codecs[coding].compressor
+	c := pool.Get().(Compressor)
+	defer pool.Put(c)
+	c.Reset(w)
+	copyBody(c, s.file)
+	c.Close()
+
+	return nil
+}
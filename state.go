@@ -0,0 +1,46 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+)
+
+var stateFuncs = template.FuncMap{
+	"initialState": initialState,
+}
+
+// initialState marshals v with Options.JSONEncoder for the bootstrapping of a single page app in an inline script:
+//
+//	<script>window.__STATE__ = {{ initialState .Data }};</script>
+//
+// <, >, & and the U+2028 and U+2029 line separators are escaped, so that no string of v closes the script or
+// breaks it. The redacted fields are left out like in JSON.
+func initialState(v interface{}) (template.JS, error) {
+	encoder := render.options.JSONEncoder
+	if encoder == nil {
+		encoder = standardJSON{}
+	}
+
+	b, err := encoder.Marshal(redact(v))
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	json.HTMLEscape(&buf, b)
+
+	return template.JS(buf.String()), nil
+}
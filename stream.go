@@ -0,0 +1,59 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Buffers used to copy streamed bodies
+var copyBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 32*1024)
+		return &buf
+	},
+}
+
+// Stream copies the body from r. Content-Length is set and at most size bytes are copied when size is not negative,
+// pass -1 for an unknown size. The content type defaults to application/octet-stream. Each write is given
+// Options.StreamWriteTimeout.
+func Stream(w http.ResponseWriter, status int, contentType string, r io.Reader, size int64) {
+	stream, err := startStream()
+	if err != nil {
+		renderError(w, nil, err)
+		return
+	}
+	defer endStream(stream)
+
+	if len(contentType) == 0 {
+		contentType = ContentBinary
+	}
+	w.Header().Set(ContentType, contentType)
+	if size >= 0 {
+		w.Header().Set(ContentLength, strconv.FormatInt(size, 10))
+		r = io.LimitReader(r, size)
+	}
+	setSecurityHeaders(w.Header())
+	w.WriteHeader(status)
+	copyBody(streamOutput{writeDeadline(w, 0), stream}, r)
+}
+
+func copyBody(w io.Writer, r io.Reader) (int64, error) {
+	buf := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(buf)
+
+	return io.CopyBuffer(w, r, *buf)
+}
@@ -0,0 +1,262 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"container/list"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// Number of shards of the tenant cache, each with a lock and a share of the limits of its own
+	tenantShards = 16
+	// Default maximum number of compiled tenant template sets
+	defaultTenantMaxSets = 1024
+)
+
+// TenantCache is a struct for specifying the cache of the compiled template sets of the tenants
+type TenantCache struct {
+	// Maximum number of compiled sets. Defaults to 1024.
+	MaxSets int `yaml:"MaxSets"`
+	// Maximum size of the template sources of the compiled sets. A set holds a copy of the shared templates, whose
+	// sources count in the size of every set along with the ones of the tenant. Defaults to 0, no limit.
+	MaxBytes int64 `yaml:"MaxBytes"`
+}
+
+func prepareTenantCache(cache TenantCache) TenantCache {
+	if cache.MaxSets <= 0 {
+		cache.MaxSets = defaultTenantMaxSets
+	}
+
+	return cache
+}
+
+// tenantSet is the template set of a tenant, the shared templates overridden by the ones of its directory
+type tenantSet struct {
+	tenant string
+	// Clones of the set the renders execute, nil when the tenant has no templates of its own
+	clones *templatePool
+	// Size of the sources of the shared templates copied into the set and of the tenant templates
+	size int64
+	err  error
+	// Closed once the set is compiled
+	ready chan struct{}
+}
+
+// tenantShard is a least recently used cache of tenant sets
+type tenantShard struct {
+	mu        sync.Mutex
+	sets      map[string]*list.Element
+	lru       *list.List
+	size      int64
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// Cache shards by tenant hash, replaced when the templates are loaded again
+var (
+	tenantCache   [tenantShards]*tenantShard
+	tenantCacheMu sync.RWMutex
+)
+
+func resetTenantSets() {
+	tenantCacheMu.Lock()
+	for i := range tenantCache {
+		tenantCache[i] = &tenantShard{sets: map[string]*list.Element{}, lru: list.New()}
+	}
+	tenantCacheMu.Unlock()
+}
+
+func tenantShardOf(tenant string) *tenantShard {
+	h := fnv.New32a()
+	h.Write([]byte(tenant))
+
+	tenantCacheMu.RLock()
+	defer tenantCacheMu.RUnlock()
+
+	return tenantCache[h.Sum32()%tenantShards]
+}
+
+// tenantTemplates returns the set of the tenant: the templates of its directory in Options.TenantDirectory,
+// compiled over the shared ones on first use and kept in a least recently used cache of Options.TenantCache. The
+// set has no templates when the tenant has no directory.
+func tenantTemplates(tenant string) (*tenantSet, error) {
+	if len(render.options.TenantDirectory) == 0 {
+		return nil, fmt.Errorf("render: tenant %q without Options.TenantDirectory", tenant)
+	}
+	if tenant == "." || tenant == ".." || strings.ContainsAny(tenant, `/\`) {
+		return nil, fmt.Errorf("render: invalid tenant %q", tenant)
+	}
+
+	set := tenantShardOf(tenant).get(tenant)
+	if set.err != nil {
+		return nil, set.err
+	}
+
+	return set, nil
+}
+
+// get returns the set of the tenant, compiling it when it is not cached. Concurrent renders for a tenant being
+// compiled wait for it.
+func (shard *tenantShard) get(tenant string) *tenantSet {
+	shard.mu.Lock()
+	if e, ok := shard.sets[tenant]; ok {
+		shard.hits++
+		shard.lru.MoveToFront(e)
+		shard.mu.Unlock()
+
+		set := e.Value.(*tenantSet)
+		<-set.ready
+		return set
+	}
+
+	shard.misses++
+	set := &tenantSet{tenant: tenant, ready: make(chan struct{})}
+	e := shard.lru.PushFront(set)
+	shard.sets[tenant] = e
+	shard.mu.Unlock()
+
+	set.compile()
+	close(set.ready)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	// the set may have been evicted while it was compiled
+	if shard.sets[tenant] != e {
+		return set
+	}
+	if set.err != nil {
+		// compile again on the next render, the files may be fixed by then
+		shard.remove(e)
+		return set
+	}
+	shard.size += set.size
+	shard.evict()
+
+	return set
+}
+
+// evict drops the least recently used sets beyond the share of the limits of the shard, keeping the most recent one
+func (shard *tenantShard) evict() {
+	cache := render.options.TenantCache
+	maxSets := (cache.MaxSets + tenantShards - 1) / tenantShards
+	maxBytes := (cache.MaxBytes + tenantShards - 1) / tenantShards
+
+	for shard.lru.Len() > 1 && (shard.lru.Len() > maxSets || maxBytes > 0 && shard.size > maxBytes) {
+		shard.remove(shard.lru.Back())
+		shard.evictions++
+	}
+}
+
+func (shard *tenantShard) remove(e *list.Element) {
+	set := shard.lru.Remove(e).(*tenantSet)
+	delete(shard.sets, set.tenant)
+	select {
+	case <-set.ready:
+		// compiled sets only were added to the size
+		if set.err == nil {
+			shard.size -= set.size
+		}
+	default:
+	}
+}
+
+// TenantCacheStats returns the occupancy of the cache of the compiled tenant template sets, the sets being compiled
+// included
+func TenantCacheStats() CacheStats {
+	cache := render.options.TenantCache
+	stats := CacheStats{MaxEntries: cache.MaxSets, MaxBytes: cache.MaxBytes}
+
+	tenantCacheMu.RLock()
+	defer tenantCacheMu.RUnlock()
+
+	for _, shard := range tenantCache {
+		if shard == nil {
+			continue
+		}
+		shard.mu.Lock()
+		stats.Entries += shard.lru.Len()
+		stats.Bytes += shard.size
+		stats.Hits += shard.hits
+		stats.Misses += shard.misses
+		stats.Evictions += shard.evictions
+		shard.mu.Unlock()
+	}
+
+	return stats
+}
+
+// compile parses the HTML template files of the tenant directory over a clone of the shared templates
+func (set *tenantSet) compile() {
+	dir := filepath.Join(render.options.TenantDirectory, set.tenant)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return
+	}
+
+	shared := loaded()
+	pristine, err := shared.pristine.Clone()
+	if err != nil {
+		set.err = err
+		return
+	}
+	// the clone copies the parse trees of the shared templates
+	for _, source := range shared.sources {
+		set.size += int64(len(source))
+	}
+
+	var parseErrors ParseErrors
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		ext := getExt(relativePath)
+		if info.IsDir() || !containsString(render.options.Extensions, ext) {
+			return nil
+		}
+		name := templateName(relativePath, ext)
+
+		buf, err := ioutil.ReadFile(path)
+		if err == nil {
+			_, err = parseHTMLTemplate(pristine, name, string(buf))
+		}
+		if err != nil {
+			parseErrors = append(parseErrors, newParseError(path, name, err, string(buf)))
+			return nil
+		}
+		set.size += int64(len(buf))
+
+		return nil
+	})
+	if err != nil {
+		set.err = err
+		return
+	}
+	if len(parseErrors) > 0 {
+		set.err = parseErrors
+		return
+	}
+
+	set.clones = newTemplatePool(pristine)
+}
@@ -0,0 +1,63 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"net/http"
+)
+
+// RenderText executes the text template name, a file of Options.TextExtensions such as "mail/welcome.txt.tmpl",
+// without HTML escaping and writes it as Text does, text/plain unless TextOptions.ContentType is set. Suited to
+// plain-text emails, shell scripts and configuration files, which html/template escaping corrupts. RenderFile
+// writes such a template to a file.
+func RenderText(w http.ResponseWriter, status int, name string, binding interface{}, textOptions ...TextOptions) {
+	if err := RenderTextE(w, status, name, binding, textOptions...); err != nil {
+		renderError(w, nil, err)
+	}
+}
+
+// RenderTextE is RenderText returning the template error instead of answering it with a 500,
+// nothing is written then
+func RenderTextE(w http.ResponseWriter, status int, name string, binding interface{}, textOptions ...TextOptions) (err error) {
+	w, done := observeRender(w, nil, FormatText, name)
+	defer done(&err)
+
+	s, err := TextString(name, binding)
+	if err != nil {
+		return err
+	}
+
+	writeText(w, status, s, prepareTextOptions(textOptions))
+	return nil
+}
+
+// TextString returns the output of the text template name, for the plain-text part of an email
+func TextString(name string, binding interface{}) (string, error) {
+	if err := refresh(); err != nil {
+		return "", err
+	}
+	release, err := acquireRender(nil)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	buf, err := executeText(name, mergeData(nil, binding))
+	// Set buffer in BufferPool
+	defer render.buffer.Set(buf)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
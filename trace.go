@@ -0,0 +1,241 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"text/template/parse"
+	"time"
+)
+
+// TraceHeader is the request header asking for the trace of the HTML render of a request in debug mode, and the
+// response header giving its ID
+const TraceHeader = "X-Render-Trace"
+
+// Kinds of TraceEvent
+const (
+	TraceTemplate = "template"
+	TraceFunc     = "func"
+	TraceCache    = "cache"
+	TraceWrite    = "write"
+)
+
+// Number of traces kept for TraceHandler
+const keptTraces = 64
+
+// Name of the func the traced templates call when they are entered
+const traceEnterFunc = "_render_trace_enter"
+
+// TraceEvent is a step of a traced render
+type TraceEvent struct {
+	// TraceTemplate, TraceFunc, TraceCache or TraceWrite
+	Kind string `json:"kind"`
+	// Template entered, func called, cache or output
+	Name   string `json:"name"`
+	Detail string `json:"detail,omitempty"`
+	// Bytes written, for TraceWrite
+	Bytes int64 `json:"bytes,omitempty"`
+	// Time the func took, for TraceFunc
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// RenderTrace is the trace of the HTML render of a request, recorded in debug mode when the request has the
+// TraceHeader header
+type RenderTrace struct {
+	ID       string        `json:"id"`
+	Template string        `json:"template"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Events   []TraceEvent  `json:"events"`
+
+	mu sync.Mutex
+}
+
+func (t *RenderTrace) add(event TraceEvent) {
+	t.mu.Lock()
+	t.Events = append(t.Events, event)
+	t.mu.Unlock()
+}
+
+var (
+	tracesMu sync.Mutex
+	// Traces of the renders in progress, by request
+	activeTraces = map[*http.Request]*RenderTrace{}
+	// Last traces, the oldest first
+	recentTraces []*RenderTrace
+	traceCount   uint64
+)
+
+// startTrace starts the trace of the render of r when it asks for one in debug mode, giving its ID in the response
+// header. The returned func ends it.
+func startTrace(w http.ResponseWriter, r *http.Request, name string) func() {
+	if !render.options.DebugMode || r == nil || len(r.Header.Get(TraceHeader)) == 0 {
+		return func() {}
+	}
+
+	trace := &RenderTrace{
+		ID:       strconv.FormatUint(atomic.AddUint64(&traceCount, 1), 10),
+		Template: name,
+		Start:    time.Now(),
+	}
+	w.Header().Set(TraceHeader, trace.ID)
+
+	tracesMu.Lock()
+	activeTraces[r] = trace
+	tracesMu.Unlock()
+
+	return func() {
+		trace.mu.Lock()
+		trace.Duration = time.Since(trace.Start)
+		trace.mu.Unlock()
+
+		tracesMu.Lock()
+		delete(activeTraces, r)
+		recentTraces = append(recentTraces, trace)
+		if len(recentTraces) > keptTraces {
+			recentTraces = recentTraces[len(recentTraces)-keptTraces:]
+		}
+		tracesMu.Unlock()
+	}
+}
+
+// requestTrace returns the trace of the render of r in progress, nil when it is not traced
+func requestTrace(r *http.Request) *RenderTrace {
+	if r == nil || !render.options.DebugMode {
+		return nil
+	}
+
+	tracesMu.Lock()
+	defer tracesMu.Unlock()
+
+	return activeTraces[r]
+}
+
+// traceEvent adds an event to the trace of the render of r, if it is traced
+func traceEvent(r *http.Request, event TraceEvent) {
+	if trace := requestTrace(r); trace != nil {
+		trace.add(event)
+	}
+}
+
+// TraceHandler serves the last traces as JSON in debug mode, the one of the ID given by the id parameter or all of
+// them, the latest first:
+//
+//	http.Handle("/debug/render-trace", render.TraceHandler())
+//
+//	curl -H "X-Render-Trace: 1" -D - http://localhost:8080/users
+//	curl http://localhost:8080/debug/render-trace?id=1
+func TraceHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !render.options.DebugMode {
+			http.NotFound(w, r)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		tracesMu.Lock()
+		var traces []*RenderTrace
+		for i := len(recentTraces) - 1; i >= 0; i-- {
+			if len(id) == 0 || recentTraces[i].ID == id {
+				traces = append(traces, recentTraces[i])
+			}
+		}
+		tracesMu.Unlock()
+
+		var v interface{} = traces
+		if len(id) > 0 {
+			if len(traces) == 0 {
+				http.NotFound(w, r)
+				return
+			}
+			v = traces[0]
+		}
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			renderError(w, r, err)
+			return
+		}
+		w.Header().Set(ContentType, ContentJSON+prepareCharset(""))
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	})
+}
+
+// instrument makes the templates of t, a clone not executed yet, record when they are entered and the calls of
+// their funcs. The trees are copied, the ones of the shared templates are left untouched.
+func (trace *RenderTrace) instrument(t *template.Template, funcs []template.FuncMap) error {
+	wrapped := template.FuncMap{}
+	for _, m := range funcs {
+		for name, f := range m {
+			wrapped[name] = traceFunc(trace, name, f)
+		}
+	}
+	wrapped[traceEnterFunc] = func(name string) string {
+		trace.add(TraceEvent{Kind: TraceTemplate, Name: name})
+		return ""
+	}
+	t.Funcs(wrapped)
+
+	left, right := render.options.Delimiter.Left, render.options.Delimiter.Right
+	if len(left) == 0 {
+		left = "{{"
+	}
+	if len(right) == 0 {
+		right = "}}"
+	}
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree == nil || tmpl.Tree.Root == nil {
+			continue
+		}
+		enter, err := parse.Parse("enter", left+traceEnterFunc+" "+strconv.Quote(tmpl.Name())+right, left, right,
+			map[string]interface{}{traceEnterFunc: wrapped[traceEnterFunc]})
+		if err != nil {
+			return err
+		}
+
+		tree := tmpl.Tree.Copy()
+		tree.Root.Nodes = append(enter["enter"].Root.Nodes, tree.Root.Nodes...)
+		if _, err := t.AddParseTree(tmpl.Name(), tree); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// traceFunc wraps the template func f to record its calls
+func traceFunc(trace *RenderTrace, name string, f interface{}) interface{} {
+	v := reflect.ValueOf(f)
+	if v.Kind() != reflect.Func {
+		return f
+	}
+
+	return reflect.MakeFunc(v.Type(), func(args []reflect.Value) []reflect.Value {
+		start := time.Now()
+		var results []reflect.Value
+		if v.Type().IsVariadic() {
+			results = v.CallSlice(args)
+		} else {
+			results = v.Call(args)
+		}
+		trace.add(TraceEvent{Kind: TraceFunc, Name: name, Duration: time.Since(start)})
+
+		return results
+	}).Interface()
+}
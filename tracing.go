@@ -0,0 +1,98 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"context"
+	"net/http"
+)
+
+// Attributes of the spans of renders
+const (
+	AttributeFormat   = "render.format"
+	AttributeTemplate = "render.template"
+	AttributeBytes    = "render.bytes"
+	AttributeStatus   = "http.response.status_code"
+)
+
+// Span is a span started by a Tracer
+type Span interface {
+	// SetAttribute sets an attribute of the span, value being a string, an int or an int64
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// Tracer starts a span around each render, the ones Metrics receives events for, from the context of the request
+// given with the call options, so that the span is a child of the one of the request. The span is named after the
+// format, such as "render.html", and has the Attribute attributes. An adapter of OpenTelemetry:
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, render.Span) {
+//		ctx, span := t.Tracer.Start(ctx, name)
+//		return ctx, otelSpan{span}
+//	}
+//
+//	type otelSpan struct{ trace.Span }
+//
+//	func (s otelSpan) SetAttribute(key string, value interface{}) {
+//		switch v := value.(type) {
+//		case string:
+//			s.SetAttributes(attribute.String(key, v))
+//		case int:
+//			s.SetAttributes(attribute.Int(key, v))
+//		case int64:
+//			s.SetAttributes(attribute.Int64(key, v))
+//		}
+//	}
+//
+//	func (s otelSpan) RecordError(err error) {
+//		s.Span.RecordError(err)
+//		s.SetStatus(codes.Error, err.Error())
+//	}
+//
+//	func (s otelSpan) End() { s.Span.End() }
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// startSpan starts the span of a render with Options.Tracer, nil when there is no tracer
+func startSpan(r *http.Request, format Format, template string) Span {
+	tracer := render.options.Tracer
+	if tracer == nil {
+		return nil
+	}
+
+	ctx := context.Background()
+	if r != nil {
+		ctx = r.Context()
+	}
+	_, span := tracer.Start(ctx, "render."+string(format))
+	span.SetAttribute(AttributeFormat, string(format))
+	if len(template) > 0 {
+		span.SetAttribute(AttributeTemplate, template)
+	}
+
+	return span
+}
+
+// endSpan ends the span of a render described by event
+func endSpan(span Span, event RenderEvent) {
+	span.SetAttribute(AttributeBytes, event.Bytes)
+	span.SetAttribute(AttributeStatus, event.Status)
+	if event.Err != nil {
+		span.RecordError(event.Err)
+	}
+	span.End()
+}
@@ -0,0 +1,138 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"fmt"
+	"github.com/ronzxy/go-helper"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template/parse"
+)
+
+// Validate parses the templates and the message catalogs of o, as Init would, and returns all the errors found
+// instead of the first: the ParseErrors of every file, a ParseError for every template called with the template
+// action or content_for which is not defined, or for Options.Layout and Options.Extends when they are not, and the
+// FuncCollisions when Options.StrictFuncs is set. It is meant for a CI check and leaves the templates loaded by Init
+// as they are, but it is not to be called while rendering.
+func Validate(o Options) []error {
+	saved := render
+	defer func() {
+		render = saved
+	}()
+
+	render = renderer{options: prepareOptions(o)}
+	render.buffer = helper.NewBufferPool(render.options.BufferPool)
+
+	t, text, _, _, _, manifest, parseErrors := createTemplate()
+	_, catalogErrors := loadCatalogs()
+
+	var errs []error
+	for _, err := range append(parseErrors, catalogErrors...) {
+		errs = append(errs, err)
+	}
+
+	files := map[string]string{}
+	for _, info := range manifest {
+		files[info.Name] = templatePath(info.File)
+	}
+
+	var trees []*parse.Tree
+	htmlDefined := map[string]bool{}
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil {
+			trees = append(trees, tmpl.Tree)
+			htmlDefined[tmpl.Name()] = true
+		}
+	}
+	textDefined := map[string]bool{}
+	var textTrees []*parse.Tree
+	for _, tmpl := range text.Templates() {
+		if tmpl.Tree != nil {
+			textTrees = append(textTrees, tmpl.Tree)
+			textDefined[tmpl.Name()] = true
+		}
+	}
+
+	for _, err := range undefinedTemplates(trees, htmlDefined, files) {
+		errs = append(errs, err)
+	}
+	for _, err := range undefinedTemplates(textTrees, textDefined, files) {
+		errs = append(errs, err)
+	}
+
+	for _, option := range [][2]string{{"Options.Layout", render.options.Layout}, {"Options.Extends", render.options.Extends}} {
+		if name := option[1]; len(name) > 0 && !htmlDefined[name] {
+			errs = append(errs, &ParseError{File: option[0], Template: name, Message: fmt.Sprintf("template %q is not defined", name)})
+		}
+	}
+
+	if collisions := funcCollisions(render.options, o.FuncFactories); len(collisions) > 0 && render.options.StrictFuncs {
+		errs = append(errs, collisions)
+	}
+
+	return errs
+}
+
+// undefinedTemplates returns a ParseError for every template the trees call which is not defined, files giving
+// the file of the trees by the name they were parsed from
+func undefinedTemplates(trees []*parse.Tree, defined map[string]bool, files map[string]string) []*ParseError {
+	sort.Slice(trees, func(i, j int) bool {
+		return trees[i].Name < trees[j].Name
+	})
+
+	var errs []*ParseError
+	for _, tree := range trees {
+		walkNodes(tree.Root, func(node parse.Node) {
+			name := ""
+			switch n := node.(type) {
+			case *parse.TemplateNode:
+				name = n.Name
+			case *parse.CommandNode:
+				// {{content_for "section" "name"}}
+				if len(n.Args) != 3 {
+					return
+				}
+				if ident, ok := n.Args[0].(*parse.IdentifierNode); !ok || ident.Ident != "content_for" {
+					return
+				}
+				s, ok := n.Args[2].(*parse.StringNode)
+				if !ok {
+					return
+				}
+				name = s.Text
+			default:
+				return
+			}
+			if defined[name] {
+				return
+			}
+
+			e := &ParseError{
+				File:     files[tree.ParseName],
+				Template: tree.ParseName,
+				Message:  fmt.Sprintf("template %q is not defined", name),
+			}
+			// the location is "name:line:column"
+			location, _ := tree.ErrorContext(node)
+			if fields := strings.Split(location, ":"); len(fields) >= 3 {
+				e.Line, _ = strconv.Atoi(fields[len(fields)-2])
+				e.Column, _ = strconv.Atoi(fields[len(fields)-1])
+			}
+			errs = append(errs, e)
+		})
+	}
+
+	return errs
+}
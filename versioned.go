@@ -0,0 +1,151 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"github.com/ronzxy/go-render/negotiate"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultVersionHeader = "API-Version"
+
+// VersionedOptions is a struct for specifying the versions of a Versioned call
+type VersionedOptions struct {
+	// Version answered when the request asks for none. Defaults to the latest one.
+	Default string
+	// Request and response header naming the version. Defaults to "API-Version".
+	Header string
+	// Deprecated versions, with the date they stop being served, zero when unknown. They are answered with the
+	// Deprecation, Sunset and Warning headers.
+	Deprecated map[string]time.Time
+	// Options of the JSON rendering.
+	JSONOptions JSONOptions
+}
+
+// Versioned renders as JSON the payload of the version asked for by the version parameter of the Accept header,
+// such as "application/json; version=2", or by the API-Version header. Versions are matched with or without a "v"
+// prefix. The request is answered with 406 Not Acceptable when it asks for an unknown version.
+//
+//	render.Versioned(w, r, http.StatusOK, map[string]interface{}{"v1": dtoV1, "v2": dtoV2})
+func Versioned(w http.ResponseWriter, r *http.Request, status int, payloads map[string]interface{}, versionedOptions ...VersionedOptions) {
+	var option VersionedOptions
+	if len(versionedOptions) > 0 {
+		option = versionedOptions[0]
+	}
+	header := option.Header
+	if len(header) == 0 {
+		header = defaultVersionHeader
+	}
+
+	addVary(w.Header(), "Accept")
+	addVary(w.Header(), header)
+
+	version := requestedVersion(r, header)
+	if len(version) == 0 {
+		version = option.Default
+		if len(version) == 0 {
+			version = latestVersion(payloads)
+		}
+	}
+
+	key, ok := matchVersion(payloads, version)
+	if !ok {
+		Error(w, http.StatusNotAcceptable, []byte("unknown version "+version), ErrorOptions{Request: r})
+		return
+	}
+
+	w.Header().Set(header, key)
+	if sunset, ok := option.Deprecated[key]; ok {
+		w.Header().Set("Deprecation", "true")
+		if !sunset.IsZero() {
+			w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+		w.Header().Add("Warning", `299 - "Deprecated API version `+key+`"`)
+	}
+
+	jsonOptions := option.JSONOptions
+	if jsonOptions.Request == nil {
+		jsonOptions.Request = r
+	}
+	JSON(w, status, payloads[key], jsonOptions)
+}
+
+// requestedVersion returns the version parameter of the preferred Accept media range having one, or else the
+// version header
+func requestedVersion(r *http.Request, header string) string {
+	if r == nil {
+		return ""
+	}
+
+	for _, spec := range negotiate.Parse(r.Header.Get("Accept")) {
+		if version, ok := spec.Params["version"]; ok && spec.Q > 0 {
+			return version
+		}
+	}
+
+	return strings.TrimSpace(r.Header.Get(header))
+}
+
+// matchVersion returns the key of payloads for version, "2" matching "v2" and the other way around
+func matchVersion(payloads map[string]interface{}, version string) (string, bool) {
+	version = strings.ToLower(version)
+	for key := range payloads {
+		k := strings.ToLower(key)
+		if k == version || strings.TrimPrefix(k, "v") == strings.TrimPrefix(version, "v") {
+			return key, true
+		}
+	}
+
+	return "", false
+}
+
+// latestVersion returns the greatest key of payloads, comparing the numbers of "v1.10" and "v1.9" numerically
+func latestVersion(payloads map[string]interface{}) string {
+	var keys []string
+	for key := range payloads {
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return compareVersions(keys[i], keys[j]) < 0
+	})
+
+	return keys[len(keys)-1]
+}
+
+func compareVersions(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(strings.ToLower(a), "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(strings.ToLower(b), "v"), ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, errX := strconv.Atoi(as[i])
+		y, errY := strconv.Atoi(bs[i])
+		switch {
+		case errX == nil && errY == nil && x != y:
+			if x < y {
+				return -1
+			}
+			return 1
+		case (errX != nil || errY != nil) && as[i] != bs[i]:
+			return strings.Compare(as[i], bs[i])
+		}
+	}
+
+	return len(as) - len(bs)
+}
@@ -0,0 +1,208 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"strings"
+)
+
+// Vite is a struct for specifying the manifest.json of a Vite or webpack build, which the vite_asset,
+// vite_entry_scripts and vite_entry_styles template funcs read
+type Vite struct {
+	// Path of the manifest.json, such as "public/build/.vite/manifest.json". A webpack manifest, mapping the names of
+	// the files to their URLs, is read as well.
+	Manifest string `yaml:"Manifest"`
+	// URL prefix of the built files, such as "/build/". Defaults to "/".
+	Prefix string `yaml:"Prefix"`
+	// URL of the Vite dev server, such as "http://localhost:5173", the funcs link to instead of the built files in
+	// debug mode. Defaults to "", the built files are linked to in debug mode too.
+	DevServer string `yaml:"DevServer"`
+}
+
+// viteChunk is an entry of a Vite manifest
+type viteChunk struct {
+	File    string   `json:"file"`
+	Imports []string `json:"imports"`
+	CSS     []string `json:"css"`
+}
+
+var viteFuncs = template.FuncMap{
+	"vite_asset":         viteAsset,
+	"vite_entry_scripts": viteEntryScripts,
+	"vite_entry_styles":  viteEntryStyles,
+}
+
+// loadViteManifest reads the manifest of Options.Vite, none being read when the dev server is used
+func loadViteManifest() (map[string]viteChunk, ParseErrors) {
+	if len(render.options.Vite.Manifest) == 0 || viteDevServer() {
+		return nil, nil
+	}
+
+	var entries map[string]json.RawMessage
+	buf, err := ioutil.ReadFile(render.options.Vite.Manifest)
+	if err == nil {
+		err = json.Unmarshal(buf, &entries)
+	}
+	if err != nil {
+		return nil, ParseErrors{&ParseError{File: render.options.Vite.Manifest, Message: err.Error()}}
+	}
+
+	manifest := make(map[string]viteChunk, len(entries))
+	for name, entry := range entries {
+		var chunk viteChunk
+		// webpack maps the names to the URLs
+		if err := json.Unmarshal(entry, &chunk.File); err != nil {
+			if err := json.Unmarshal(entry, &chunk); err != nil {
+				return nil, ParseErrors{&ParseError{File: render.options.Vite.Manifest, Message: fmt.Sprintf("%s: %v", name, err)}}
+			}
+		}
+		manifest[name] = chunk
+	}
+
+	return manifest, nil
+}
+
+// viteDevServer tells whether the funcs link to the dev server
+func viteDevServer() bool {
+	return render.options.DebugMode && len(render.options.Vite.DevServer) > 0
+}
+
+// viteURL returns the URL of a built file
+func viteURL(file string) string {
+	// the URLs of a webpack manifest are complete
+	if strings.HasPrefix(file, "/") || strings.Contains(file, "://") {
+		return file
+	}
+
+	prefix := render.options.Vite.Prefix
+	if len(prefix) == 0 {
+		prefix = "/"
+	}
+
+	return strings.TrimSuffix(prefix, "/") + "/" + file
+}
+
+func viteDevURL(name string) string {
+	return strings.TrimSuffix(render.options.Vite.DevServer, "/") + "/" + strings.TrimPrefix(name, "/")
+}
+
+func viteChunkOf(name string) (viteChunk, error) {
+	chunk, ok := loaded().vite[name]
+	if !ok {
+		return chunk, fmt.Errorf("render: %q is not in the Vite manifest", name)
+	}
+
+	return chunk, nil
+}
+
+// viteAsset returns the URL of the built file of the source file name, such as "src/images/logo.png"
+func viteAsset(name string) (string, error) {
+	if viteDevServer() {
+		return viteDevURL(name), nil
+	}
+
+	chunk, err := viteChunkOf(name)
+	if err != nil {
+		return "", err
+	}
+
+	return viteURL(chunk.File), nil
+}
+
+// viteEntryScripts returns the script tag of the entry name, such as "src/main.ts", along with the modulepreload
+// links of the chunks it imports. With the dev server, it returns the script tags of the Vite client and of the
+// source of the entry.
+//
+//	<head>
+//		{{ vite_entry_styles "src/main.ts" }}
+//		{{ vite_entry_scripts "src/main.ts" }}
+//	</head>
+func viteEntryScripts(name string) (template.HTML, error) {
+	var b strings.Builder
+	if viteDevServer() {
+		fmt.Fprintf(&b, `<script type="module" src="%s"></script>`, template.HTMLEscapeString(viteDevURL("@vite/client")))
+		fmt.Fprintf(&b, `<script type="module" src="%s"></script>`, template.HTMLEscapeString(viteDevURL(name)))
+		return template.HTML(b.String()), nil
+	}
+
+	chunk, err := viteChunkOf(name)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(chunk.File, ".js") && !strings.HasSuffix(chunk.File, ".mjs") {
+		return "", nil
+	}
+	fmt.Fprintf(&b, `<script type="module" src="%s"></script>`, template.HTMLEscapeString(viteURL(chunk.File)))
+	for _, imported := range viteImports(name, map[string]bool{name: true}) {
+		fmt.Fprintf(&b, `<link rel="modulepreload" href="%s">`, template.HTMLEscapeString(viteURL(loaded().vite[imported].File)))
+	}
+
+	return template.HTML(b.String()), nil
+}
+
+// viteEntryStyles returns the stylesheet links of the CSS of the entry name and of the chunks it imports, none with
+// the dev server, which injects the styles itself
+func viteEntryStyles(name string) (template.HTML, error) {
+	if viteDevServer() {
+		return "", nil
+	}
+
+	chunk, err := viteChunkOf(name)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	seen := map[string]bool{}
+	link := func(file string) {
+		if !seen[file] {
+			seen[file] = true
+			fmt.Fprintf(&b, `<link rel="stylesheet" href="%s">`, template.HTMLEscapeString(viteURL(file)))
+		}
+	}
+	if strings.HasSuffix(chunk.File, ".css") {
+		link(chunk.File)
+	}
+	for _, file := range chunk.CSS {
+		link(file)
+	}
+	for _, imported := range viteImports(name, map[string]bool{name: true}) {
+		for _, file := range loaded().vite[imported].CSS {
+			link(file)
+		}
+	}
+
+	return template.HTML(b.String()), nil
+}
+
+// viteImports returns the chunks the chunk name imports, directly or not, in depth-first order
+func viteImports(name string, seen map[string]bool) []string {
+	chunks := loaded().vite
+	var imports []string
+	for _, imported := range chunks[name].Imports {
+		if seen[imported] {
+			continue
+		}
+		seen[imported] = true
+		if _, ok := chunks[imported]; ok {
+			imports = append(imports, imported)
+		}
+		imports = append(imports, viteImports(imported, seen)...)
+	}
+
+	return imports
+}
@@ -0,0 +1,89 @@
+/* Copyright 2018 Ron Zhang <ronzxy@mx.aketi.cn>. All rights reserved.
+ *
+ * Licensed under the Apache License, version 2.0 (the "License").
+ * You may not use this work except in compliance with the License, which is
+ * available at www.apache.org/licenses/LICENSE-2.0
+ *
+ * This software is distributed on an "AS IS" basis, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied, as more fully set forth in the License.
+ *
+ * See the NOTICE file distributed with this work for information regarding copyright ownership.
+ */
+
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+)
+
+func xmlDeclaration() []byte {
+	charset := render.options.Charset
+	if len(charset) == 0 {
+		charset = defaultCharset
+	}
+
+	return []byte(`<?xml version="1.0" encoding="` + charset + `"?>` + "\n")
+}
+
+// encodeXML writes v to w, within the root element of option when it has one
+func encodeXML(w io.Writer, v interface{}, option XMLOptions) error {
+	encoder := xml.NewEncoder(w)
+	if render.options.IndentXML {
+		encoder.Indent("", "  ")
+	}
+	if len(option.Root) == 0 {
+		return encoder.Encode(v)
+	}
+
+	start := xml.StartElement{Name: xml.Name{Local: option.Root}}
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	if err := encoder.EncodeToken(start.End()); err != nil {
+		return err
+	}
+
+	return encoder.Flush()
+}
+
+// declareXMLNamespaces adds the xmlns attributes of namespaces to the first element of the document b
+func declareXMLNamespaces(b []byte, namespaces map[string]string) []byte {
+	start := bytes.IndexByte(b, '<')
+	if start < 0 {
+		return b
+	}
+	end := start + 1
+	for end < len(b) && strings.IndexByte(" \t\r\n/>", b[end]) < 0 {
+		end++
+	}
+
+	var prefixes []string
+	for prefix := range namespaces {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	var attrs bytes.Buffer
+	for _, prefix := range prefixes {
+		name := "xmlns"
+		if len(prefix) > 0 {
+			name += ":" + prefix
+		}
+		attrs.WriteString(" " + name + `="`)
+		xml.EscapeText(&attrs, []byte(namespaces[prefix]))
+		attrs.WriteString(`"`)
+	}
+
+	result := make([]byte, 0, len(b)+attrs.Len())
+	result = append(result, b[:end]...)
+	result = append(result, attrs.Bytes()...)
+
+	return append(result, b[end:]...)
+}